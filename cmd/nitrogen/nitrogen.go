@@ -50,6 +50,8 @@ var (
 	memprofile   string
 	outputFile   string
 	noPreamble   bool
+	useCache     bool
+	cacheDir     string
 
 	infoCmd bool
 
@@ -72,6 +74,8 @@ func init() {
 	flag.StringVar(&cpuprofile, "cpuprofile", "", "File to write CPU profile data")
 	flag.StringVar(&memprofile, "memprofile", "", "File to write memory profile data")
 	flag.StringVar(&outputFile, "o", "", "Output file of compiled bytecode")
+	flag.BoolVar(&useCache, "cache", false, "Store compiled scripts in the user cache directory")
+	flag.StringVar(&cacheDir, "cachedir", os.Getenv("NITROGEN_CACHE_DIR"), "Directory to store compiled scripts, implies -cache")
 
 	flag.Var(&extraModulePaths, "M", "Module search paths")
 	flag.Var(&autoloadModules, "al", "Autoload modules")
@@ -118,6 +122,19 @@ func main() {
 		return
 	}
 
+	if useCache && cacheDir == "" {
+		cacheDir = moduleutils.DefaultCacheDir()
+	}
+
+	// Scripts need an extension so a script can't be mistaken for a subcommand
+	if flag.Arg(0) == "cache" {
+		runCacheCmd()
+		return
+	}
+
+	moduleutils.CacheDir = cacheDir
+	moduleutils.CompilerVersion = version
+
 	if disableNibs {
 		moduleutils.WriteCompiledScripts = false
 	}
@@ -146,6 +163,10 @@ func main() {
 	}
 
 	sourceFile := flag.Arg(0)
+	if ext := filepath.Ext(sourceFile); ext != ".ni" && ext != ".nib" {
+		fmt.Println("Scripts must have a .ni or .nib extension")
+		os.Exit(1)
+	}
 
 	env := makeEnv()
 	builtinOs.SetCmdArgs(getScriptArgs(sourceFile))
@@ -324,9 +345,43 @@ func runInfoCmd() {
 	fmt.Printf("Filename: %s\n", fileinfo.Filename)
 	fmt.Printf("Version:  %s\n", bytesToVersionNumber(fileinfo.Version))
 	fmt.Printf("ModTime:  %s\n", fileinfo.ModTime)
+	fmt.Printf("Checksum: %08x\n", fileinfo.Checksum)
 	code.Print("")
 }
 
+func runCacheCmd() {
+	dir := cacheDir
+	if dir == "" {
+		dir = moduleutils.DefaultCacheDir()
+	}
+
+	switch flag.Arg(1) {
+	case "clean":
+		removed, err := moduleutils.CleanCacheDir(dir)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed %d files from %s\n", removed, dir)
+	case "stats":
+		stats, err := moduleutils.CacheDirStats(dir)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Directory: %s\n", dir)
+		fmt.Printf("Files:     %d\n", stats.Files)
+		fmt.Printf("Size:      %d bytes\n", stats.Size)
+		if stats.Files > 0 {
+			fmt.Printf("Oldest:    %s\n", stats.Oldest)
+			fmt.Printf("Newest:    %s\n", stats.Newest)
+		}
+	default:
+		fmt.Println("Usage: nitrogen [-cachedir dir] cache clean|stats")
+		os.Exit(1)
+	}
+}
+
 func bytesToVersionNumber(b []byte) string {
 	ver := ""
 	for _, v := range b {
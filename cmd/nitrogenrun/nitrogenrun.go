@@ -271,6 +271,7 @@ func runInfoCmd() {
 	fmt.Printf("Filename: %s\n", fileinfo.Filename)
 	fmt.Printf("Version:  %s\n", bytesToVersionNumber(fileinfo.Version))
 	fmt.Printf("ModTime:  %s\n", fileinfo.ModTime)
+	fmt.Printf("Checksum: %08x\n", fileinfo.Checksum)
 	code.Print("")
 }
 
@@ -30,6 +30,8 @@ func (s *strSliceFlag) Set(st string) error {
 var (
 	printVersion bool
 	disableNibs  bool
+	useCache     bool
+	cacheDir     string
 
 	extraModulePaths strSliceFlag
 	autoloadModules  strSliceFlag
@@ -44,6 +46,8 @@ var (
 func init() {
 	flag.BoolVar(&disableNibs, "nonibs", false, "Disable creation of .nib files")
 	flag.BoolVar(&printVersion, "version", false, "Print version information")
+	flag.BoolVar(&useCache, "cache", false, "Store compiled scripts in the user cache directory")
+	flag.StringVar(&cacheDir, "cachedir", os.Getenv("NITROGEN_CACHE_DIR"), "Directory to store compiled scripts, implies -cache")
 
 	flag.Var(&extraModulePaths, "M", "Module search paths")
 	flag.Var(&autoloadModules, "al", "Autoload modules")
@@ -83,6 +87,12 @@ func main() {
 		return
 	}
 
+	if useCache && cacheDir == "" {
+		cacheDir = moduleutils.DefaultCacheDir()
+	}
+	moduleutils.CacheDir = cacheDir
+	moduleutils.CompilerVersion = version
+
 	if disableNibs {
 		moduleutils.WriteCompiledScripts = false
 	}
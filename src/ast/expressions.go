@@ -65,7 +65,7 @@ func (i *InfixExpression) String() string {
 }
 
 type CompareExpression struct {
-	Token token.Token // and, or
+	Token token.Token // and, or, ??
 	Left  Expression
 	Right Expression
 }
@@ -114,6 +114,7 @@ type CallExpression struct {
 	Token     token.Token // The '(' token
 	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
+	Optional  bool // Written f?.(), the call is skipped if Function is nil
 }
 
 func (ce *CallExpression) expressionNode()      {}
@@ -125,16 +126,33 @@ func (ce *CallExpression) String() string {
 		args = append(args, a.String())
 	}
 	out.WriteString(ce.Function.String())
+	if ce.Optional {
+		out.WriteString("?.")
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(args, ", "))
 	out.WriteString(")")
 	return out.String()
 }
 
+// NamedArgument is an argument passed to the parameter Name, written name: value.
+type NamedArgument struct {
+	Token token.Token // The name token
+	Name  *Identifier
+	Value Expression
+}
+
+func (na *NamedArgument) expressionNode()      {}
+func (na *NamedArgument) TokenLiteral() string { return na.Token.Literal }
+func (na *NamedArgument) String() string {
+	return na.Name.String() + ": " + na.Value.String()
+}
+
 type IndexExpression struct {
-	Token token.Token // The '[' token
-	Left  Expression
-	Index Expression
+	Token    token.Token // The '[' or '?[' token
+	Left     Expression
+	Index    Expression
+	Optional bool // Written a?[i], the index is skipped if Left is nil
 }
 
 func (i *IndexExpression) expressionNode()      {}
@@ -144,6 +162,9 @@ func (i *IndexExpression) String() string {
 
 	out.WriteByte('(')
 	out.WriteString(i.Left.String())
+	if i.Optional {
+		out.WriteByte('?')
+	}
 	out.WriteByte('[')
 	out.WriteString(i.Index.String())
 	out.WriteString("])")
@@ -151,10 +172,48 @@ func (i *IndexExpression) String() string {
 	return out.String()
 }
 
+// SliceExpression is written left[start:stop:step], any of the bounds may be nil.
+type SliceExpression struct {
+	Token    token.Token // The '[' or '?[' token
+	Left     Expression
+	Start    Expression
+	Stop     Expression
+	Step     Expression
+	Optional bool // Written a?[i:j], the slice is skipped if Left is nil
+}
+
+func (s *SliceExpression) expressionNode()      {}
+func (s *SliceExpression) TokenLiteral() string { return s.Token.Literal }
+func (s *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteByte('(')
+	out.WriteString(s.Left.String())
+	if s.Optional {
+		out.WriteByte('?')
+	}
+	out.WriteByte('[')
+	if s.Start != nil {
+		out.WriteString(s.Start.String())
+	}
+	out.WriteByte(':')
+	if s.Stop != nil {
+		out.WriteString(s.Stop.String())
+	}
+	if s.Step != nil {
+		out.WriteByte(':')
+		out.WriteString(s.Step.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
 type AttributeExpression struct {
-	Token token.Token
-	Left  Expression
-	Index *StringLiteral
+	Token    token.Token
+	Left     Expression
+	Index    *StringLiteral
+	Optional bool // Written a?.b, the attribute is skipped if Left is nil
 }
 
 func (i *AttributeExpression) expressionNode()      {}
@@ -164,6 +223,9 @@ func (i *AttributeExpression) String() string {
 
 	out.WriteByte('(')
 	out.WriteString(i.Left.String())
+	if i.Optional {
+		out.WriteByte('?')
+	}
 	out.WriteByte('.')
 	out.WriteString(i.Index.String())
 	out.WriteByte(')')
@@ -196,3 +258,34 @@ type DoExpression struct {
 func (d *DoExpression) expressionNode()      {}
 func (d *DoExpression) TokenLiteral() string { return d.Token.Literal }
 func (d *DoExpression) String() string       { return d.Statements.String() }
+
+// HasOptionalLink returns if any link of a chain of attribute, index, slice and
+// call expressions is optional, such as a?.b.c.
+func HasOptionalLink(exp Expression) bool {
+	for {
+		switch e := exp.(type) {
+		case *AttributeExpression:
+			if e.Optional {
+				return true
+			}
+			exp = e.Left
+		case *IndexExpression:
+			if e.Optional {
+				return true
+			}
+			exp = e.Left
+		case *SliceExpression:
+			if e.Optional {
+				return true
+			}
+			exp = e.Left
+		case *CallExpression:
+			if e.Optional {
+				return true
+			}
+			exp = e.Function
+		default:
+			return false
+		}
+	}
+}
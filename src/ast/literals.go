@@ -73,6 +73,45 @@ func (s *ByteStringLiteral) expressionNode()      {}
 func (s *ByteStringLiteral) TokenLiteral() string { return s.Token.Literal }
 func (s *ByteStringLiteral) String() string       { return string(s.Value) }
 
+// FormatString is an f"..." string made of literal text and interpolated values.
+type FormatString struct {
+	Token token.Token
+	Parts []Expression // Each part is a *StringLiteral or *FormattedValue
+}
+
+func (f *FormatString) expressionNode()      {}
+func (f *FormatString) TokenLiteral() string { return f.Token.Literal }
+func (f *FormatString) String() string {
+	var out bytes.Buffer
+	out.WriteString(`f"`)
+	for _, part := range f.Parts {
+		if lit, ok := part.(*StringLiteral); ok {
+			s := strings.ReplaceAll(string(lit.Value), "{", "{{")
+			out.WriteString(strings.ReplaceAll(s, "}", "}}"))
+			continue
+		}
+		out.WriteString(part.String())
+	}
+	out.WriteByte('"')
+	return out.String()
+}
+
+// FormattedValue is an expression interpolated into a format string.
+type FormattedValue struct {
+	Token token.Token // The first token of the expression
+	Value Expression
+	Spec  string
+}
+
+func (f *FormattedValue) expressionNode()      {}
+func (f *FormattedValue) TokenLiteral() string { return f.Token.Literal }
+func (f *FormattedValue) String() string {
+	if f.Spec == "" {
+		return "{" + f.Value.String() + "}"
+	}
+	return "{" + f.Value.String() + ":" + f.Spec + "}"
+}
+
 type Boolean struct {
 	Token token.Token
 	Value bool
@@ -89,6 +128,8 @@ type FunctionLiteral struct {
 	FQName     string
 	Native     bool
 	Parameters []*Identifier
+	Defaults   []Expression // Default values of Parameters, nil if a parameter doesn't have one
+	Rest       *Identifier  // Parameter collecting extra arguments, nil if there isn't one
 	Body       *BlockStatement
 }
 
@@ -97,8 +138,15 @@ func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+	for i, p := range fl.Parameters {
+		if fl.Defaults != nil && fl.Defaults[i] != nil {
+			params = append(params, p.String()+" = "+fl.Defaults[i].String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if fl.Rest != nil {
+		params = append(params, "..."+fl.Rest.String())
 	}
 
 	out.WriteString(fl.TokenLiteral())
@@ -155,6 +203,82 @@ func (h *HashLiteral) String() string {
 	return out.String()
 }
 
+// ComprehensionClause is a for clause of a comprehension with the if filters following it.
+type ComprehensionClause struct {
+	Token   token.Token // the 'for' token
+	Key     Pattern     // nil if only values are used
+	Value   Pattern
+	Iter    Expression
+	Filters []Expression
+}
+
+func (c *ComprehensionClause) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for ")
+	if c.Key != nil {
+		out.WriteString(c.Key.String())
+		out.WriteString(", ")
+	}
+	out.WriteString(c.Value.String())
+	out.WriteString(" in ")
+	out.WriteString(c.Iter.String())
+
+	for _, filter := range c.Filters {
+		out.WriteString(" if ")
+		out.WriteString(filter.String())
+	}
+	return out.String()
+}
+
+func writeClauses(out *bytes.Buffer, clauses []*ComprehensionClause) {
+	for _, clause := range clauses {
+		out.WriteByte(' ')
+		out.WriteString(clause.String())
+	}
+}
+
+// ArrayComprehension is written [element for x in iter if filter].
+type ArrayComprehension struct {
+	Token   token.Token // the '[' token
+	Element Expression
+	Clauses []*ComprehensionClause
+}
+
+func (a *ArrayComprehension) expressionNode()      {}
+func (a *ArrayComprehension) TokenLiteral() string { return a.Token.Literal }
+func (a *ArrayComprehension) String() string {
+	var out bytes.Buffer
+
+	out.WriteByte('[')
+	out.WriteString(a.Element.String())
+	writeClauses(&out, a.Clauses)
+	out.WriteByte(']')
+	return out.String()
+}
+
+// MapComprehension is written {key: value for x in iter if filter}.
+type MapComprehension struct {
+	Token   token.Token // the '{' token
+	Key     Expression
+	Value   Expression
+	Clauses []*ComprehensionClause
+}
+
+func (m *MapComprehension) expressionNode()      {}
+func (m *MapComprehension) TokenLiteral() string { return m.Token.Literal }
+func (m *MapComprehension) String() string {
+	var out bytes.Buffer
+
+	out.WriteByte('{')
+	out.WriteString(m.Key.String())
+	out.WriteString(": ")
+	out.WriteString(m.Value.String())
+	writeClauses(&out, m.Clauses)
+	out.WriteByte('}')
+	return out.String()
+}
+
 type ClassLiteral struct {
 	Token   token.Token
 	Name    string
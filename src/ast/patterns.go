@@ -0,0 +1,189 @@
+package ast
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/nitrogen-lang/nitrogen/src/token"
+)
+
+type MatchExpression struct {
+	Token   token.Token // The 'match' token
+	Subject Expression
+	Arms    []*MatchArm
+}
+
+func (m *MatchExpression) expressionNode()      {}
+func (m *MatchExpression) TokenLiteral() string { return m.Token.Literal }
+func (m *MatchExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("match ")
+	out.WriteString(m.Subject.String())
+	out.WriteString(" {")
+	for _, arm := range m.Arms {
+		out.WriteString(arm.String())
+		out.WriteString(", ")
+	}
+	out.WriteByte('}')
+	return out.String()
+}
+
+type MatchArm struct {
+	Token   token.Token // The first token of the pattern
+	Pattern Pattern
+	Guard   Expression // nil if the arm has no guard
+	Body    *BlockStatement
+}
+
+func (a *MatchArm) String() string {
+	var out bytes.Buffer
+	out.WriteString(a.Pattern.String())
+	if a.Guard != nil {
+		out.WriteString(" if ")
+		out.WriteString(a.Guard.String())
+	}
+	out.WriteString(" => {")
+	out.WriteString(a.Body.String())
+	out.WriteByte('}')
+	return out.String()
+}
+
+// Pattern is a node that can appear on the left side of a match arm.
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+// WildcardPattern matches anything, written as _.
+type WildcardPattern struct {
+	Token token.Token
+}
+
+func (w *WildcardPattern) patternNode()         {}
+func (w *WildcardPattern) TokenLiteral() string { return w.Token.Literal }
+func (w *WildcardPattern) String() string       { return "_" }
+
+// ValuePattern matches a value equal to a literal or a dotted name such as Color.RED.
+type ValuePattern struct {
+	Token token.Token
+	Value Expression
+}
+
+func (v *ValuePattern) patternNode()         {}
+func (v *ValuePattern) TokenLiteral() string { return v.Token.Literal }
+func (v *ValuePattern) String() string       { return v.Value.String() }
+
+// RangePattern matches a value between Low and High inclusive. Either end may be nil.
+type RangePattern struct {
+	Token token.Token // The '..' token
+	Low   Expression
+	High  Expression
+}
+
+func (r *RangePattern) patternNode()         {}
+func (r *RangePattern) TokenLiteral() string { return r.Token.Literal }
+func (r *RangePattern) String() string {
+	var out bytes.Buffer
+	if r.Low != nil {
+		out.WriteString(r.Low.String())
+	}
+	out.WriteString("..")
+	if r.High != nil {
+		out.WriteString(r.High.String())
+	}
+	return out.String()
+}
+
+// BindingPattern matches anything and assigns it to Name.
+type BindingPattern struct {
+	Token token.Token
+	Name  *Identifier
+}
+
+func (b *BindingPattern) patternNode()         {}
+func (b *BindingPattern) TokenLiteral() string { return b.Token.Literal }
+func (b *BindingPattern) String() string       { return b.Name.String() }
+
+// AlternativePattern matches if any of its alternatives match.
+type AlternativePattern struct {
+	Token        token.Token
+	Alternatives []Pattern
+}
+
+func (a *AlternativePattern) patternNode()         {}
+func (a *AlternativePattern) TokenLiteral() string { return a.Token.Literal }
+func (a *AlternativePattern) String() string {
+	alts := make([]string, len(a.Alternatives))
+	for i, p := range a.Alternatives {
+		alts[i] = p.String()
+	}
+	return strings.Join(alts, " | ")
+}
+
+// ArrayPattern matches an array element by element. If HasRest is set, the
+// array may have any number of elements between Head and Tail which are
+// collected into Rest unless it's nil.
+type ArrayPattern struct {
+	Token   token.Token // The '[' token
+	Head    []Pattern
+	HasRest bool
+	Rest    *Identifier
+	Tail    []Pattern
+}
+
+func (a *ArrayPattern) patternNode()         {}
+func (a *ArrayPattern) TokenLiteral() string { return a.Token.Literal }
+func (a *ArrayPattern) String() string {
+	elements := []string{}
+	for _, p := range a.Head {
+		elements = append(elements, p.String())
+	}
+	if a.HasRest {
+		if a.Rest != nil {
+			elements = append(elements, "..."+a.Rest.String())
+		} else {
+			elements = append(elements, "...")
+		}
+	}
+	for _, p := range a.Tail {
+		elements = append(elements, p.String())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// MapPattern matches a map containing all of Keys with values matching the
+// pattern at the same index in Values.
+type MapPattern struct {
+	Token  token.Token // The '{' token
+	Keys   []Expression
+	Values []Pattern
+}
+
+func (m *MapPattern) patternNode()         {}
+func (m *MapPattern) TokenLiteral() string { return m.Token.Literal }
+func (m *MapPattern) String() string {
+	pairs := make([]string, len(m.Keys))
+	for i, k := range m.Keys {
+		pairs[i] = k.String() + ": " + m.Values[i].String()
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// ClassPattern matches an instance of Class, or one of its children, with
+// fields matching the pattern at the same index in Patterns.
+type ClassPattern struct {
+	Token    token.Token
+	Class    Expression
+	Fields   []string
+	Patterns []Pattern
+}
+
+func (c *ClassPattern) patternNode()         {}
+func (c *ClassPattern) TokenLiteral() string { return c.Token.Literal }
+func (c *ClassPattern) String() string {
+	fields := make([]string, len(c.Fields))
+	for i, f := range c.Fields {
+		fields[i] = f + ": " + c.Patterns[i].String()
+	}
+	return c.Class.String() + "{" + strings.Join(fields, ", ") + "}"
+}
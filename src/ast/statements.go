@@ -67,6 +67,37 @@ func (d *DefStatement) String() string {
 	return out.String()
 }
 
+// DestructureStatement unpacks Value into the variables named in Pattern. The
+// variables are defined if Define is set, otherwise they're assigned.
+type DestructureStatement struct {
+	Token   token.Token // The let, const or = token
+	Define  bool
+	Const   bool
+	Export  bool
+	Pattern Pattern
+	Value   Expression
+}
+
+func (d *DestructureStatement) statementNode()       {}
+func (d *DestructureStatement) TokenLiteral() string { return d.Token.Literal }
+func (d *DestructureStatement) String() string {
+	var out bytes.Buffer
+
+	if d.Define {
+		if d.Const {
+			out.WriteString("const ")
+		} else {
+			out.WriteString("let ")
+		}
+	}
+	out.WriteString(d.Pattern.String())
+	out.WriteString(" = ")
+	out.WriteString(d.Value.String())
+	out.WriteByte(';')
+
+	return out.String()
+}
+
 type ImportStatement struct {
 	Token token.Token // the token.Import token
 	Path  *StringLiteral
@@ -90,6 +121,45 @@ func (d *DeleteStatement) String() string {
 	return fmt.Sprintf("delete %s;", d.Name)
 }
 
+// DeferStatement is written defer f(args). The function and arguments are evaluated
+// immediately, the call runs when the enclosing function returns.
+type DeferStatement struct {
+	Token token.Token // the 'defer' token
+	Call  *CallExpression
+}
+
+func (d *DeferStatement) statementNode()       {}
+func (d *DeferStatement) TokenLiteral() string { return d.Token.Literal }
+func (d *DeferStatement) String() string {
+	return fmt.Sprintf("defer %s;", d.Call.String())
+}
+
+// WithStatement is written with resource as name { body }. The resource is closed
+// when the body ends, however it's left.
+type WithStatement struct {
+	Token    token.Token // the 'with' token
+	Resource Expression
+	Name     *Identifier // nil if the resource isn't bound to a name
+	Body     *BlockStatement
+}
+
+func (w *WithStatement) statementNode()       {}
+func (w *WithStatement) TokenLiteral() string { return w.Token.Literal }
+func (w *WithStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("with ")
+	out.WriteString(w.Resource.String())
+	if w.Name != nil {
+		out.WriteString(" as ")
+		out.WriteString(w.Name.String())
+	}
+	out.WriteString(" { ")
+	out.WriteString(w.Body.String())
+	out.WriteString(" }")
+	return out.String()
+}
+
 type AssignStatement struct {
 	Token token.Token // the token.DEF token
 	Left  Expression
@@ -170,6 +240,7 @@ func (bs *BlockStatement) String() string {
 
 type LoopStatement struct {
 	Token     token.Token
+	Label     *Identifier // nil if the loop isn't labeled
 	Init      *DefStatement
 	Condition Expression
 	Iter      Node
@@ -185,6 +256,7 @@ func (fl *LoopStatement) TokenLiteral() string {
 }
 func (fl *LoopStatement) String() string {
 	var out bytes.Buffer
+	writeLabel(&out, fl.Label)
 	out.WriteString(fl.TokenLiteral())
 	out.WriteByte(' ')
 	if fl.Init != nil {
@@ -204,8 +276,9 @@ func (fl *LoopStatement) String() string {
 
 type IterLoopStatement struct {
 	Token token.Token
-	Key   *Identifier
-	Value *Identifier
+	Label *Identifier // nil if the loop isn't labeled
+	Key   Pattern     // nil if only values are used
+	Value Pattern
 	Iter  Expression
 	Body  *BlockStatement
 }
@@ -215,6 +288,7 @@ func (fl *IterLoopStatement) TokenLiteral() string { return "for" }
 func (fl *IterLoopStatement) String() string {
 	var out bytes.Buffer
 
+	writeLabel(&out, fl.Label)
 	out.WriteString("for ")
 
 	if fl.Key != nil {
@@ -232,21 +306,40 @@ func (fl *IterLoopStatement) String() string {
 	return out.String()
 }
 
+func writeLabel(out *bytes.Buffer, label *Identifier) {
+	if label != nil {
+		out.WriteString(label.String())
+		out.WriteString(": ")
+	}
+}
+
 type ContinueStatement struct {
 	Token token.Token
+	Label *Identifier // nil for the innermost loop
 }
 
 func (c *ContinueStatement) statementNode()       {}
 func (c *ContinueStatement) TokenLiteral() string { return "continue" }
-func (c *ContinueStatement) String() string       { return "continue" }
+func (c *ContinueStatement) String() string {
+	if c.Label != nil {
+		return "continue " + c.Label.String()
+	}
+	return "continue"
+}
 
 type BreakStatement struct {
 	Token token.Token
+	Label *Identifier // nil for the innermost loop
 }
 
 func (b *BreakStatement) statementNode()       {}
 func (b *BreakStatement) TokenLiteral() string { return "break" }
-func (b *BreakStatement) String() string       { return "break" }
+func (b *BreakStatement) String() string {
+	if b.Label != nil {
+		return "break " + b.Label.String()
+	}
+	return "break"
+}
 
 type PassStatement struct {
 	Token token.Token
@@ -5,14 +5,21 @@ import (
 	// and separation of concerns.
 	_ "github.com/nitrogen-lang/nitrogen/src/builtins/classes"
 	_ "github.com/nitrogen-lang/nitrogen/src/builtins/collections"
+	_ "github.com/nitrogen-lang/nitrogen/src/builtins/crypto"
+	_ "github.com/nitrogen-lang/nitrogen/src/builtins/encoding/base64"
+	_ "github.com/nitrogen-lang/nitrogen/src/builtins/encoding/binary"
+	_ "github.com/nitrogen-lang/nitrogen/src/builtins/encoding/hex"
+	_ "github.com/nitrogen-lang/nitrogen/src/builtins/encoding/json"
 	_ "github.com/nitrogen-lang/nitrogen/src/builtins/errors"
 	_ "github.com/nitrogen-lang/nitrogen/src/builtins/file"
 	_ "github.com/nitrogen-lang/nitrogen/src/builtins/filepath"
 	_ "github.com/nitrogen-lang/nitrogen/src/builtins/http"
+	_ "github.com/nitrogen-lang/nitrogen/src/builtins/http/server"
 	_ "github.com/nitrogen-lang/nitrogen/src/builtins/imports"
 	_ "github.com/nitrogen-lang/nitrogen/src/builtins/io"
 	_ "github.com/nitrogen-lang/nitrogen/src/builtins/opbuf"
 	_ "github.com/nitrogen-lang/nitrogen/src/builtins/os"
+	_ "github.com/nitrogen-lang/nitrogen/src/builtins/regex"
 	_ "github.com/nitrogen-lang/nitrogen/src/builtins/runtime"
 	_ "github.com/nitrogen-lang/nitrogen/src/builtins/string"
 	_ "github.com/nitrogen-lang/nitrogen/src/builtins/time"
@@ -34,6 +34,8 @@ func lenBuiltin(interpreter object.Interpreter, env *object.Environment, args ..
 	switch arg := args[0].(type) {
 	case *object.String:
 		return object.MakeIntObj(int64(len(arg.Value)))
+	case *object.ByteString:
+		return object.MakeIntObj(int64(len(arg.Value)))
 	case *object.Array:
 		return object.MakeIntObj(int64(len(arg.Elements)))
 	case *object.Hash:
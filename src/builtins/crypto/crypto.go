@@ -0,0 +1,349 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"io"
+	"time"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm"
+	"github.com/nitrogen-lang/nitrogen/src/moduleutils"
+)
+
+const (
+	hasherResourceID = "std.crypto.hasher"
+)
+
+var algorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+func init() {
+	vm.RegisterNative("std.crypto.md5", makeHashFunc("md5"))
+	vm.RegisterNative("std.crypto.sha1", makeHashFunc("sha1"))
+	vm.RegisterNative("std.crypto.sha256", makeHashFunc("sha256"))
+	vm.RegisterNative("std.crypto.sha512", makeHashFunc("sha512"))
+	vm.RegisterNative("std.crypto.hmac", hmacHash)
+	vm.RegisterNative("std.crypto.constantTimeEqual", constantTimeEqual)
+	vm.RegisterNative("std.crypto.randomBytes", randomBytes)
+	vm.RegisterNative("std.crypto.uuid4", uuid4)
+	vm.RegisterNative("std.crypto.uuid7", uuid7)
+
+	vm.RegisterNativeMethod("std.crypto.Hasher.init", vmHasherInit, 1)
+	vm.RegisterNativeMethod("std.crypto.Hmac.init", vmHmacInit, 2)
+
+	for _, class := range []string{"Hasher", "Hmac"} {
+		vm.RegisterNativeMethod("std.crypto."+class+".update", vmHasherUpdate, 1)
+		vm.RegisterNativeMethod("std.crypto."+class+".updateFrom", vmHasherUpdateFrom, 1)
+		vm.RegisterNativeMethod("std.crypto."+class+".digest", vmHasherDigest, 0)
+		vm.RegisterNativeMethod("std.crypto."+class+".hexDigest", vmHasherHexDigest, 0)
+		vm.RegisterNativeMethod("std.crypto."+class+".reset", vmHasherReset, 0)
+	}
+}
+
+type hasherResource struct {
+	h hash.Hash
+}
+
+func (h *hasherResource) Inspect() string         { return "Hasher resource" }
+func (h *hasherResource) Type() object.ObjectType { return object.ResourceObj }
+func (h *hasherResource) Dup() object.Object      { return object.NullConst } // Hash state can't be copied
+func (h *hasherResource) ResourceID() string      { return hasherResourceID }
+
+func getAlgorithm(name string, arg object.Object) (func() hash.Hash, *object.Exception) {
+	algo, ok := arg.(*object.String)
+	if !ok {
+		return nil, object.NewException("%s expected a string, got %s", name, arg.Type().String())
+	}
+
+	fn, ok := algorithms[algo.String()]
+	if !ok {
+		return nil, object.NewException("%s: unsupported hash algorithm %s", name, algo.String())
+	}
+	return fn, nil
+}
+
+func getData(name string, arg object.Object) ([]byte, *object.Exception) {
+	switch arg := arg.(type) {
+	case *object.String:
+		return []byte(arg.String()), nil
+	case *object.ByteString:
+		return arg.Value, nil
+	}
+	return nil, object.NewException("%s expected a string or bytestring, got %s", name, arg.Type().String())
+}
+
+func makeHashFunc(algo string) object.BuiltinFunction {
+	newHash := algorithms[algo]
+
+	return func(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+		if ac := moduleutils.CheckArgs(algo, 1, args...); ac != nil {
+			return ac
+		}
+
+		data, exc := getData(algo, args[0])
+		if exc != nil {
+			return exc
+		}
+
+		h := newHash()
+		h.Write(data)
+		return object.MakeStringObj(hex.EncodeToString(h.Sum(nil)))
+	}
+}
+
+func hmacHash(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("hmac", 3, args...); ac != nil {
+		return ac
+	}
+
+	newHash, exc := getAlgorithm("hmac", args[0])
+	if exc != nil {
+		return exc
+	}
+
+	key, exc := getData("hmac", args[1])
+	if exc != nil {
+		return exc
+	}
+
+	data, exc := getData("hmac", args[2])
+	if exc != nil {
+		return exc
+	}
+
+	h := hmac.New(newHash, key)
+	h.Write(data)
+	return object.MakeStringObj(hex.EncodeToString(h.Sum(nil)))
+}
+
+func constantTimeEqual(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("constantTimeEqual", 2, args...); ac != nil {
+		return ac
+	}
+
+	a, exc := getData("constantTimeEqual", args[0])
+	if exc != nil {
+		return exc
+	}
+
+	b, exc := getData("constantTimeEqual", args[1])
+	if exc != nil {
+		return exc
+	}
+
+	return object.NativeBoolToBooleanObj(subtle.ConstantTimeCompare(a, b) == 1)
+}
+
+func randomBytes(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("randomBytes", 1, args...); ac != nil {
+		return ac
+	}
+
+	n, ok := args[0].(*object.Integer)
+	if !ok {
+		return object.NewException("randomBytes expected an int, got %s", args[0].Type().String())
+	}
+	if n.Value < 0 {
+		return object.NewException("randomBytes length can't be negative")
+	}
+
+	buf := make([]byte, n.Value)
+	if _, err := rand.Read(buf); err != nil {
+		return object.NewException("randomBytes: %s", err.Error())
+	}
+	return object.MakeByteStringObjBytes(buf)
+}
+
+func uuid4(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("uuid4", 0, args...); ac != nil {
+		return ac
+	}
+
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return object.NewException("uuid4: %s", err.Error())
+	}
+	return object.MakeStringObj(formatUUID(u, 4))
+}
+
+// uuid7 generates a time ordered UUID as defined in RFC 9562. The first 48 bits are the
+// Unix time in milliseconds, the rest is random.
+func uuid7(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("uuid7", 0, args...); ac != nil {
+		return ac
+	}
+
+	var u [16]byte
+	if _, err := rand.Read(u[6:]); err != nil {
+		return object.NewException("uuid7: %s", err.Error())
+	}
+
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(time.Now().UnixMilli()))
+	copy(u[:6], ts[2:])
+	return object.MakeStringObj(formatUUID(u, 7))
+}
+
+func formatUUID(u [16]byte, version byte) string {
+	u[6] = (u[6] & 0x0f) | version<<4
+	u[8] = (u[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+func getHasher(self *vm.VMInstance) (hash.Hash, *object.Exception) {
+	res, exists := self.Fields.Get("res")
+	if !exists {
+		return nil, object.NewException("Hasher object doesn't contain a resource")
+	}
+
+	h, ok := res.(*hasherResource)
+	if !ok {
+		return nil, object.NewException("Hasher object doesn't contain a hash state")
+	}
+	return h.h, nil
+}
+
+func vmHasherInit(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("Hasher", 1, args...); ac != nil {
+		return ac
+	}
+
+	newHash, exc := getAlgorithm("Hasher", args[0])
+	if exc != nil {
+		return exc
+	}
+
+	self.Fields.SetForce("algorithm", args[0], true)
+	self.Fields.SetForce("res", &hasherResource{h: newHash()}, true)
+	return nil
+}
+
+func vmHmacInit(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("Hmac", 2, args...); ac != nil {
+		return ac
+	}
+
+	newHash, exc := getAlgorithm("Hmac", args[0])
+	if exc != nil {
+		return exc
+	}
+
+	key, exc := getData("Hmac", args[1])
+	if exc != nil {
+		return exc
+	}
+
+	self.Fields.SetForce("algorithm", args[0], true)
+	self.Fields.SetForce("res", &hasherResource{h: hmac.New(newHash, key)}, true)
+	return nil
+}
+
+func vmHasherUpdate(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("update", 1, args...); ac != nil {
+		return ac
+	}
+
+	h, exc := getHasher(self)
+	if exc != nil {
+		return exc
+	}
+
+	data, exc := getData("update", args[0])
+	if exc != nil {
+		return exc
+	}
+
+	h.Write(data)
+	return self
+}
+
+// vmHasherUpdateFrom feeds the hasher everything remaining in a native reader such as a File.
+func vmHasherUpdateFrom(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("updateFrom", 1, args...); ac != nil {
+		return ac
+	}
+
+	h, exc := getHasher(self)
+	if exc != nil {
+		return exc
+	}
+
+	inst, ok := args[0].(*vm.VMInstance)
+	if !ok {
+		return object.NewException("updateFrom expected a File, got %s", args[0].Type().String())
+	}
+
+	res, _ := inst.Fields.Get("res")
+	r, ok := res.(io.Reader)
+	if !ok {
+		return object.NewException("updateFrom expected an open File")
+	}
+
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return object.NewException("updateFrom: %s", err.Error())
+	}
+	return object.MakeIntObj(n)
+}
+
+func vmHasherDigest(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("digest", 0, args...); ac != nil {
+		return ac
+	}
+
+	h, exc := getHasher(self)
+	if exc != nil {
+		return exc
+	}
+	return object.MakeByteStringObjBytes(h.Sum(nil))
+}
+
+func vmHasherHexDigest(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("hexDigest", 0, args...); ac != nil {
+		return ac
+	}
+
+	h, exc := getHasher(self)
+	if exc != nil {
+		return exc
+	}
+	return object.MakeStringObj(hex.EncodeToString(h.Sum(nil)))
+}
+
+func vmHasherReset(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("reset", 0, args...); ac != nil {
+		return ac
+	}
+
+	h, exc := getHasher(self)
+	if exc != nil {
+		return exc
+	}
+
+	h.Reset()
+	return self
+}
@@ -0,0 +1,66 @@
+package base64
+
+import (
+	gobase64 "encoding/base64"
+	"strings"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm"
+	"github.com/nitrogen-lang/nitrogen/src/moduleutils"
+)
+
+func init() {
+	vm.RegisterNative("std.encoding.base64.encode", makeEncodeFunc("encode", gobase64.StdEncoding))
+	vm.RegisterNative("std.encoding.base64.decode", makeDecodeFunc("decode", gobase64.StdEncoding))
+	vm.RegisterNative("std.encoding.base64.urlEncode", makeEncodeFunc("urlEncode", gobase64.RawURLEncoding))
+	vm.RegisterNative("std.encoding.base64.urlDecode", makeDecodeFunc("urlDecode", gobase64.RawURLEncoding))
+}
+
+func getData(name string, arg object.Object) ([]byte, *object.Exception) {
+	switch arg := arg.(type) {
+	case *object.String:
+		return []byte(arg.String()), nil
+	case *object.ByteString:
+		return arg.Value, nil
+	}
+	return nil, object.NewException("%s expected a string or bytestring, got %s", name, arg.Type().String())
+}
+
+func makeEncodeFunc(name string, enc *gobase64.Encoding) object.BuiltinFunction {
+	return func(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+		if ac := moduleutils.CheckArgs(name, 1, args...); ac != nil {
+			return ac
+		}
+
+		data, exc := getData(name, args[0])
+		if exc != nil {
+			return exc
+		}
+		return object.MakeStringObj(enc.EncodeToString(data))
+	}
+}
+
+func makeDecodeFunc(name string, enc *gobase64.Encoding) object.BuiltinFunction {
+	return func(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+		if ac := moduleutils.CheckArgs(name, 1, args...); ac != nil {
+			return ac
+		}
+
+		data, exc := getData(name, args[0])
+		if exc != nil {
+			return exc
+		}
+
+		s := string(data)
+		if enc == gobase64.RawURLEncoding {
+			// Padding is optional in URLs, accept it if present
+			s = strings.TrimRight(s, "=")
+		}
+
+		decoded, err := enc.DecodeString(s)
+		if err != nil {
+			return object.NewException("Invalid base64: %s", err.Error())
+		}
+		return object.MakeByteStringObjBytes(decoded)
+	}
+}
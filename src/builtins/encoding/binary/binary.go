@@ -0,0 +1,255 @@
+package binary
+
+import (
+	gobinary "encoding/binary"
+	"math"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm"
+	"github.com/nitrogen-lang/nitrogen/src/moduleutils"
+)
+
+func init() {
+	vm.RegisterNative("std.encoding.binary.pack", pack)
+	vm.RegisterNative("std.encoding.binary.unpack", unpack)
+	vm.RegisterNative("std.encoding.binary.size", size)
+}
+
+func getFormat(name string, arg object.Object) (*format, *object.Exception) {
+	str, ok := arg.(*object.String)
+	if !ok {
+		return nil, object.NewException("%s expected a format string, got %s", name, arg.Type().String())
+	}
+
+	f, err := parseFormat(str.String())
+	if err != nil {
+		return nil, object.NewException("%s: %s", name, err.Error())
+	}
+	return f, nil
+}
+
+func size(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("size", 1, args...); ac != nil {
+		return ac
+	}
+
+	f, exc := getFormat("size", args[0])
+	if exc != nil {
+		return exc
+	}
+	return object.MakeIntObj(int64(f.size()))
+}
+
+func pack(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("pack", 2, args...); ac != nil {
+		return ac
+	}
+
+	f, exc := getFormat("pack", args[0])
+	if exc != nil {
+		return exc
+	}
+
+	values, ok := args[1].(*object.Array)
+	if !ok {
+		return object.NewException("pack expected an array, got %s", args[1].Type().String())
+	}
+
+	if len(values.Elements) != f.values() {
+		return object.NewException("pack format requires %d values, got %d", f.values(), len(values.Elements))
+	}
+
+	buf := make([]byte, 0, f.size())
+	next := 0
+	for _, field := range f.fields {
+		if field.code == 'x' {
+			buf = append(buf, make([]byte, field.count)...)
+			continue
+		}
+
+		if field.code == 's' {
+			b, ok := toBytes(values.Elements[next])
+			if !ok {
+				return object.NewException("pack value %d expected a string or bytestring, got %s", next, values.Elements[next].Type().String())
+			}
+			padded := make([]byte, field.count)
+			copy(padded, b)
+			buf = append(buf, padded...)
+			next++
+			continue
+		}
+
+		for i := 0; i < field.count; i++ {
+			var exc *object.Exception
+			buf, exc = packValue(buf, f.order, field.code, next, values.Elements[next])
+			if exc != nil {
+				return exc
+			}
+			next++
+		}
+	}
+
+	return object.MakeByteStringObjBytes(buf)
+}
+
+func packValue(buf []byte, order gobinary.AppendByteOrder, code byte, i int, val object.Object) ([]byte, *object.Exception) {
+	switch code {
+	case '?':
+		b, ok := val.(*object.Boolean)
+		if !ok {
+			return nil, object.NewException("pack value %d expected a bool, got %s", i, val.Type().String())
+		}
+		if b.Value {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+
+	case 'f', 'd':
+		var fv float64
+		switch val := val.(type) {
+		case *object.Float:
+			fv = val.Value
+		case *object.Integer:
+			fv = float64(val.Value)
+		default:
+			return nil, object.NewException("pack value %d expected a float, got %s", i, val.Type().String())
+		}
+
+		if code == 'f' {
+			return order.AppendUint32(buf, math.Float32bits(float32(fv))), nil
+		}
+		return order.AppendUint64(buf, math.Float64bits(fv)), nil
+	}
+
+	n, ok := val.(*object.Integer)
+	if !ok {
+		return nil, object.NewException("pack value %d expected an int, got %s", i, val.Type().String())
+	}
+
+	width := codeSizes[code]
+	if !intFits(n.Value, width, isSigned(code)) {
+		return nil, object.NewException("pack value %d out of range for format '%c'", i, code)
+	}
+
+	switch width {
+	case 1:
+		return append(buf, byte(n.Value)), nil
+	case 2:
+		return order.AppendUint16(buf, uint16(n.Value)), nil
+	case 4:
+		return order.AppendUint32(buf, uint32(n.Value)), nil
+	}
+	return order.AppendUint64(buf, uint64(n.Value)), nil
+}
+
+func intFits(n int64, width int, signed bool) bool {
+	if width == 8 {
+		return signed || n >= 0
+	}
+
+	bits := uint(width * 8)
+	if signed {
+		return n >= -(1<<(bits-1)) && n < 1<<(bits-1)
+	}
+	return n >= 0 && n < 1<<bits
+}
+
+func unpack(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckMinArgs("unpack", 2, args...); ac != nil {
+		return ac
+	}
+
+	f, exc := getFormat("unpack", args[0])
+	if exc != nil {
+		return exc
+	}
+
+	data, ok := toBytes(args[1])
+	if !ok {
+		return object.NewException("unpack expected a bytestring, got %s", args[1].Type().String())
+	}
+
+	if len(args) > 2 && args[2].Type() != object.NullObj {
+		offset, ok := args[2].(*object.Integer)
+		if !ok {
+			return object.NewException("unpack expected an int offset, got %s", args[2].Type().String())
+		}
+		if offset.Value < 0 || offset.Value > int64(len(data)) {
+			return object.NewException("unpack offset %d out of range", offset.Value)
+		}
+		data = data[offset.Value:]
+	}
+
+	if len(data) < f.size() {
+		return object.NewException("unpack requires %d bytes, got %d", f.size(), len(data))
+	}
+
+	values := make([]object.Object, 0, f.values())
+	for _, field := range f.fields {
+		switch field.code {
+		case 'x':
+			data = data[field.count:]
+			continue
+		case 's':
+			b := make([]byte, field.count)
+			copy(b, data)
+			values = append(values, object.MakeByteStringObjBytes(b))
+			data = data[field.count:]
+			continue
+		}
+
+		width := codeSizes[field.code]
+		for i := 0; i < field.count; i++ {
+			val, exc := unpackValue(data[:width], f.order, field.code)
+			if exc != nil {
+				return exc
+			}
+			values = append(values, val)
+			data = data[width:]
+		}
+	}
+
+	return &object.Array{Elements: values}
+}
+
+func unpackValue(b []byte, order gobinary.ByteOrder, code byte) (object.Object, *object.Exception) {
+	switch code {
+	case '?':
+		return object.NativeBoolToBooleanObj(b[0] != 0), nil
+	case 'f':
+		return object.MakeFloatObj(float64(math.Float32frombits(order.Uint32(b)))), nil
+	case 'd':
+		return object.MakeFloatObj(math.Float64frombits(order.Uint64(b))), nil
+	case 'b':
+		return object.MakeIntObj(int64(int8(b[0]))), nil
+	case 'B':
+		return object.MakeIntObj(int64(b[0])), nil
+	case 'h':
+		return object.MakeIntObj(int64(int16(order.Uint16(b)))), nil
+	case 'H':
+		return object.MakeIntObj(int64(order.Uint16(b))), nil
+	case 'i', 'l':
+		return object.MakeIntObj(int64(int32(order.Uint32(b)))), nil
+	case 'I', 'L':
+		return object.MakeIntObj(int64(order.Uint32(b))), nil
+	case 'q':
+		return object.MakeIntObj(int64(order.Uint64(b))), nil
+	}
+
+	// 'Q', ints are signed 64 bit so the top bit can't be represented
+	n := order.Uint64(b)
+	if n > math.MaxInt64 {
+		return nil, object.NewException("unpack value %d overflows int", n)
+	}
+	return object.MakeIntObj(int64(n)), nil
+}
+
+func toBytes(obj object.Object) ([]byte, bool) {
+	switch obj := obj.(type) {
+	case *object.ByteString:
+		return obj.Value, true
+	case *object.String:
+		return []byte(obj.String()), true
+	}
+	return nil, false
+}
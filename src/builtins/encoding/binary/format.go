@@ -0,0 +1,123 @@
+package binary
+
+import (
+	gobinary "encoding/binary"
+	"fmt"
+)
+
+// byteOrder combines the read and append interfaces so one value serves pack and unpack.
+type byteOrder interface {
+	gobinary.ByteOrder
+	gobinary.AppendByteOrder
+}
+
+var codeSizes = map[byte]int{
+	'x': 1,
+	'?': 1,
+	'b': 1,
+	'B': 1,
+	'h': 2,
+	'H': 2,
+	'i': 4,
+	'I': 4,
+	'l': 4,
+	'L': 4,
+	'q': 8,
+	'Q': 8,
+	'f': 4,
+	'd': 8,
+	's': 1,
+}
+
+func isSigned(code byte) bool {
+	switch code {
+	case 'b', 'h', 'i', 'l', 'q':
+		return true
+	}
+	return false
+}
+
+type formatField struct {
+	code  byte
+	count int
+}
+
+type format struct {
+	order  byteOrder
+	fields []formatField
+}
+
+// parseFormat parses a struct format string such as "<2HiQ". The first character may set
+// the byte order, native order is used if it doesn't. Each code may be preceded by a repeat
+// count. For 's' the count is the length of the byte string instead.
+func parseFormat(s string) (*format, error) {
+	f := &format{order: gobinary.NativeEndian}
+
+	if len(s) > 0 {
+		switch s[0] {
+		case '<':
+			f.order = gobinary.LittleEndian
+			s = s[1:]
+		case '>', '!':
+			f.order = gobinary.BigEndian
+			s = s[1:]
+		case '=', '@':
+			s = s[1:]
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == ' ' || c == '\t' || c == '\n' {
+			continue
+		}
+
+		count := 1
+		if c >= '0' && c <= '9' {
+			count = 0
+			for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+				count = count*10 + int(s[i]-'0')
+				if count > 1<<24 {
+					return nil, fmt.Errorf("repeat count too large")
+				}
+			}
+			if i == len(s) {
+				return nil, fmt.Errorf("repeat count without format code")
+			}
+			c = s[i]
+		}
+
+		if _, ok := codeSizes[c]; !ok {
+			return nil, fmt.Errorf("invalid format code '%c'", c)
+		}
+		if count > 0 || c == 's' {
+			f.fields = append(f.fields, formatField{code: c, count: count})
+		}
+	}
+
+	return f, nil
+}
+
+// size returns the number of bytes the format packs to.
+func (f *format) size() int {
+	n := 0
+	for _, field := range f.fields {
+		n += codeSizes[field.code] * field.count
+	}
+	return n
+}
+
+// values returns the number of values the format packs or unpacks.
+func (f *format) values() int {
+	n := 0
+	for _, field := range f.fields {
+		switch field.code {
+		case 'x':
+		case 's':
+			n++
+		default:
+			n += field.count
+		}
+	}
+	return n
+}
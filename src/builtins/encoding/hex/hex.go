@@ -0,0 +1,53 @@
+package hex
+
+import (
+	gohex "encoding/hex"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm"
+	"github.com/nitrogen-lang/nitrogen/src/moduleutils"
+)
+
+func init() {
+	vm.RegisterNative("std.encoding.hex.encode", encode)
+	vm.RegisterNative("std.encoding.hex.decode", decode)
+}
+
+func getData(name string, arg object.Object) ([]byte, *object.Exception) {
+	switch arg := arg.(type) {
+	case *object.String:
+		return []byte(arg.String()), nil
+	case *object.ByteString:
+		return arg.Value, nil
+	}
+	return nil, object.NewException("%s expected a string or bytestring, got %s", name, arg.Type().String())
+}
+
+func encode(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("encode", 1, args...); ac != nil {
+		return ac
+	}
+
+	data, exc := getData("encode", args[0])
+	if exc != nil {
+		return exc
+	}
+	return object.MakeStringObj(gohex.EncodeToString(data))
+}
+
+func decode(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("decode", 1, args...); ac != nil {
+		return ac
+	}
+
+	data, exc := getData("decode", args[0])
+	if exc != nil {
+		return exc
+	}
+
+	decoded, err := gohex.DecodeString(string(data))
+	if err != nil {
+		return object.NewException("Invalid hex: %s", err.Error())
+	}
+	return object.MakeByteStringObjBytes(decoded)
+}
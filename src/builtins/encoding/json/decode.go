@@ -0,0 +1,159 @@
+package json
+
+import (
+	"bytes"
+	gojson "encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/moduleutils"
+)
+
+func decode(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("decode", 1, args...); ac != nil {
+		return ac
+	}
+
+	data, ok := toBytes(args[0])
+	if !ok {
+		return object.NewError("decode expected a string, got %s", args[0].Type().String())
+	}
+
+	obj, err := decodeBytes(data, 1)
+	if err != nil {
+		return err
+	}
+	return obj
+}
+
+// decodeLine is used by the Decoder class to decode one line of newline-delimited JSON.
+// The line number is used in error messages.
+func decodeLine(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("decodeLine", 2, args...); ac != nil {
+		return ac
+	}
+
+	data, ok := toBytes(args[0])
+	if !ok {
+		return object.NewError("decodeLine expected a string, got %s", args[0].Type().String())
+	}
+
+	line, ok := args[1].(*object.Integer)
+	if !ok {
+		return object.NewError("decodeLine expected an int, got %s", args[1].Type().String())
+	}
+
+	obj, err := decodeBytes(data, int(line.Value))
+	if err != nil {
+		return err
+	}
+	return obj
+}
+
+func toBytes(obj object.Object) ([]byte, bool) {
+	switch obj := obj.(type) {
+	case *object.String:
+		return []byte(obj.String()), true
+	case *object.ByteString:
+		return obj.Value, true
+	}
+	return nil, false
+}
+
+// decodeBytes parses a single JSON value. firstLine is the line number of the first
+// line in data and is used to report the position of syntax errors.
+func decodeBytes(data []byte, firstLine int) (object.Object, *object.Error) {
+	dec := gojson.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var val interface{}
+	if err := dec.Decode(&val); err != nil {
+		return nil, syntaxError(data, firstLine, err)
+	}
+
+	// Only whitespace may follow the value
+	rest := bytes.TrimLeft(data[dec.InputOffset():], " \t\r\n")
+	if len(rest) > 0 {
+		offset := len(data) - len(rest)
+		return nil, positionError(data, firstLine, offset, "invalid character "+quoteChar(rest)+" after top-level value")
+	}
+
+	return convert(val), nil
+}
+
+func syntaxError(data []byte, firstLine int, err error) *object.Error {
+	var serr *gojson.SyntaxError
+	if errors.As(err, &serr) {
+		// Offset is the number of bytes read before the error, the bad character is the last one read
+		offset := int(serr.Offset) - 1
+		if offset < 0 {
+			offset = 0
+		}
+		return positionError(data, firstLine, offset, serr.Error())
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return positionError(data, firstLine, len(data), "unexpected end of JSON input")
+	}
+	return object.NewError("Invalid JSON: %s", err.Error())
+}
+
+func positionError(data []byte, firstLine, offset int, msg string) *object.Error {
+	if offset > len(data) {
+		offset = len(data)
+	}
+
+	before := data[:offset]
+	line := firstLine + bytes.Count(before, []byte{'\n'})
+	column := utf8.RuneCount(before[bytes.LastIndexByte(before, '\n')+1:]) + 1
+
+	return object.NewError("Invalid JSON at line %d, column %d (offset %d): %s", line, column, offset, msg)
+}
+
+func quoteChar(b []byte) string {
+	r, _ := utf8.DecodeRune(b)
+	return strconv.QuoteRune(r)
+}
+
+func convert(val interface{}) object.Object {
+	switch val := val.(type) {
+	case nil:
+		return object.NullConst
+	case bool:
+		return object.NativeBoolToBooleanObj(val)
+	case string:
+		return object.MakeStringObj(val)
+	case gojson.Number:
+		return convertNumber(val)
+	case []interface{}:
+		arr := make([]object.Object, len(val))
+		for i, v := range val {
+			arr[i] = convert(v)
+		}
+		return &object.Array{Elements: arr}
+	case map[string]interface{}:
+		hash := object.MakeEmptyHash()
+		for k, v := range val {
+			hash.SetKey(k, convert(v))
+		}
+		return hash
+	}
+	return object.NullConst
+}
+
+// convertNumber keeps the distinction between integers and floats. Integers too large
+// for an int are returned as floats.
+func convertNumber(n gojson.Number) object.Object {
+	s := n.String()
+	if !strings.ContainsAny(s, ".eE") {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return object.MakeIntObj(i)
+		}
+	}
+
+	f, _ := strconv.ParseFloat(s, 64)
+	return object.MakeFloatObj(f)
+}
@@ -0,0 +1,239 @@
+package json
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/moduleutils"
+)
+
+// maxDepth limits nesting so self-referencing collections don't recurse forever.
+const maxDepth = 1000
+
+type encoder struct {
+	buf    bytes.Buffer
+	indent string
+	prefix string
+}
+
+func encode(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckMinArgs("encode", 1, args...); ac != nil {
+		return ac
+	}
+
+	e := &encoder{}
+
+	if len(args) > 1 && args[1].Type() != object.NullObj {
+		opts, ok := args[1].(*object.Hash)
+		if !ok {
+			return object.NewError("encode expected options to be a map, got %s", args[1].Type().String())
+		}
+		if err := e.setOptions(opts); err != nil {
+			return err
+		}
+	}
+
+	if err := e.encode(args[0], 0); err != nil {
+		return err
+	}
+	return object.MakeStringObj(e.buf.String())
+}
+
+func (e *encoder) setOptions(opts *object.Hash) *object.Error {
+	if indent := opts.LookupKey("indent"); indent != nil {
+		switch indent := indent.(type) {
+		case *object.String:
+			e.indent = indent.String()
+		case *object.Integer:
+			if indent.Value < 0 {
+				return object.NewError("encode indent can't be negative")
+			}
+			e.indent = strings.Repeat(" ", int(indent.Value))
+		default:
+			return object.NewError("encode expected indent to be a string or int, got %s", indent.Type().String())
+		}
+	}
+
+	if prefix := opts.LookupKey("prefix"); prefix != nil {
+		p, ok := prefix.(*object.String)
+		if !ok {
+			return object.NewError("encode expected prefix to be a string, got %s", prefix.Type().String())
+		}
+		e.prefix = p.String()
+	}
+	return nil
+}
+
+func (e *encoder) pretty() bool { return e.indent != "" || e.prefix != "" }
+
+func (e *encoder) newline(depth int) {
+	if !e.pretty() {
+		return
+	}
+	e.buf.WriteByte('\n')
+	e.buf.WriteString(e.prefix)
+	for i := 0; i < depth; i++ {
+		e.buf.WriteString(e.indent)
+	}
+}
+
+func (e *encoder) encode(obj object.Object, depth int) *object.Error {
+	if depth > maxDepth {
+		return object.NewError("JSON encoding exceeded max depth of %d, the value may contain itself", maxDepth)
+	}
+
+	switch obj := obj.(type) {
+	case *object.Null:
+		e.buf.WriteString("null")
+	case *object.Boolean:
+		e.buf.WriteString(obj.Inspect())
+	case *object.Integer:
+		e.buf.WriteString(strconv.FormatInt(obj.Value, 10))
+	case *object.Float:
+		return e.encodeFloat(obj.Value)
+	case *object.String:
+		e.encodeString(obj.String())
+	case *object.ByteString:
+		e.encodeString(obj.String())
+	case *object.Array:
+		return e.encodeArray(obj, depth)
+	case *object.Hash:
+		return e.encodeHash(obj, depth)
+	default:
+		return object.NewError("Unsupported JSON object type: %s", obj.Type().String())
+	}
+	return nil
+}
+
+// encodeFloat always includes a decimal point or exponent so the value decodes as a float.
+func (e *encoder) encodeFloat(f float64) *object.Error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return object.NewError("Unsupported JSON float value: %s", strconv.FormatFloat(f, 'g', -1, 64))
+	}
+
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+	e.buf.WriteString(s)
+	return nil
+}
+
+const hex = "0123456789abcdef"
+
+func (e *encoder) encodeString(s string) {
+	e.buf.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				e.buf.WriteByte('\\')
+				e.buf.WriteByte(c)
+			case c == '\n':
+				e.buf.WriteString(`\n`)
+			case c == '\r':
+				e.buf.WriteString(`\r`)
+			case c == '\t':
+				e.buf.WriteString(`\t`)
+			case c < 0x20:
+				e.buf.WriteString(`\u00`)
+				e.buf.WriteByte(hex[c>>4])
+				e.buf.WriteByte(hex[c&0xF])
+			default:
+				e.buf.WriteByte(c)
+			}
+			i++
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			e.buf.WriteString(`\ufffd`)
+		case r == '\u2028' || r == '\u2029':
+			// Valid JSON but not valid JavaScript
+			e.buf.WriteString(`\u202`)
+			e.buf.WriteByte(hex[r&0xF])
+		default:
+			e.buf.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	e.buf.WriteByte('"')
+}
+
+func (e *encoder) encodeArray(arr *object.Array, depth int) *object.Error {
+	if len(arr.Elements) == 0 {
+		e.buf.WriteString("[]")
+		return nil
+	}
+
+	e.buf.WriteByte('[')
+	for i, el := range arr.Elements {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		e.newline(depth + 1)
+		if err := e.encode(el, depth+1); err != nil {
+			return err
+		}
+	}
+	e.newline(depth)
+	e.buf.WriteByte(']')
+	return nil
+}
+
+type hashEntry struct {
+	key string
+	val object.Object
+}
+
+// encodeHash writes keys in sorted order so the output is stable.
+func (e *encoder) encodeHash(hash *object.Hash, depth int) *object.Error {
+	if len(hash.Pairs) == 0 {
+		e.buf.WriteString("{}")
+		return nil
+	}
+
+	entries := make([]hashEntry, 0, len(hash.Pairs))
+	for _, pair := range hash.Pairs {
+		var key string
+		switch k := pair.Key.(type) {
+		case *object.String:
+			key = k.String()
+		case *object.ByteString:
+			key = k.String()
+		case *object.Integer:
+			key = strconv.FormatInt(k.Value, 10)
+		default:
+			return object.NewError("Unsupported JSON object key type: %s", pair.Key.Type().String())
+		}
+		entries = append(entries, hashEntry{key: key, val: pair.Value})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+	e.buf.WriteByte('{')
+	for i, entry := range entries {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		e.newline(depth + 1)
+		e.encodeString(entry.key)
+		e.buf.WriteByte(':')
+		if e.pretty() {
+			e.buf.WriteByte(' ')
+		}
+		if err := e.encode(entry.val, depth+1); err != nil {
+			return err
+		}
+	}
+	e.newline(depth)
+	e.buf.WriteByte('}')
+	return nil
+}
@@ -0,0 +1,11 @@
+package json
+
+import (
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm"
+)
+
+func init() {
+	vm.RegisterNative("std.encoding.json.decode.decode", decode)
+	vm.RegisterNative("std.encoding.json.decode.decodeLine", decodeLine)
+	vm.RegisterNative("std.encoding.json.encode.encode", encode)
+}
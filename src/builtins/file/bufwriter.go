@@ -0,0 +1,138 @@
+package file
+
+import (
+	"bufio"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm"
+	"github.com/nitrogen-lang/nitrogen/src/moduleutils"
+)
+
+const (
+	bufWriterResourceID = "std.file.bufwriter"
+)
+
+type bufWriterResource struct {
+	w    *bufio.Writer
+	file *fileResource
+}
+
+func (b *bufWriterResource) Inspect() string         { return "BufferedWriter resource" }
+func (b *bufWriterResource) Type() object.ObjectType { return object.ResourceObj }
+func (b *bufWriterResource) Dup() object.Object      { return object.NullConst } // Duplicating a buffer would write data twice
+func (b *bufWriterResource) ResourceID() string      { return bufWriterResourceID }
+
+func getBufWriter(name string, self *vm.VMInstance) (*bufWriterResource, *object.Exception) {
+	res, exists := self.Fields.Get("res")
+	if !exists {
+		return nil, object.NewException("BufferedWriter object doesn't contain a resource")
+	}
+
+	w, ok := res.(*bufWriterResource)
+	if !ok {
+		return nil, object.NewException("%s expected a buffered writer resource, got %s", name, res.Type().String())
+	}
+	return w, nil
+}
+
+func vmBufWriterInit(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckMinArgs("BufferedWriter", 1, args...); ac != nil {
+		return ac
+	}
+
+	inst, ok := args[0].(*vm.VMInstance)
+	if !ok {
+		return object.NewException("BufferedWriter expected a File, got %s", args[0].Type().String())
+	}
+
+	file, exc := getFile("BufferedWriter", inst)
+	if exc != nil {
+		return exc
+	}
+
+	size := 4096
+	if len(args) > 1 && args[1].Type() != object.NullObj {
+		s, ok := args[1].(*object.Integer)
+		if !ok {
+			return object.NewException("BufferedWriter expected an int size, got %s", args[1].Type().String())
+		}
+		if s.Value <= 0 {
+			return object.NewException("BufferedWriter size must be positive")
+		}
+		size = int(s.Value)
+	}
+
+	self.Fields.SetForce("file", inst, true)
+	self.Fields.SetForce("res", &bufWriterResource{w: bufio.NewWriterSize(file, size), file: file}, true)
+	return nil
+}
+
+func vmBufWriterWrite(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("write", 1, args...); ac != nil {
+		return ac
+	}
+
+	w, exc := getBufWriter("write", self)
+	if exc != nil {
+		return exc
+	}
+
+	var written int
+	var err error
+	switch arg := args[0].(type) {
+	case *object.String:
+		written, err = w.w.WriteString(arg.String())
+	case *object.ByteString:
+		written, err = w.w.Write(arg.Value)
+	default:
+		return object.NewException("write expected a string, got %s", args[0].Type().String())
+	}
+
+	if err != nil {
+		return object.NewException("Error writing file %s", err.Error())
+	}
+	return object.MakeIntObj(int64(written))
+}
+
+func vmBufWriterFlush(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("flush", 0, args...); ac != nil {
+		return ac
+	}
+
+	w, exc := getBufWriter("flush", self)
+	if exc != nil {
+		return exc
+	}
+
+	if err := w.w.Flush(); err != nil {
+		return object.NewException("Error writing file %s", err.Error())
+	}
+	return object.NullConst
+}
+
+// vmBufWriterClose flushes the buffer and closes the underlying file.
+func vmBufWriterClose(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("close", 0, args...); ac != nil {
+		return ac
+	}
+
+	w, exc := getBufWriter("close", self)
+	if exc != nil {
+		return exc
+	}
+
+	err := w.w.Flush()
+	w.file.file.Close()
+
+	self.Fields.SetForce("res", object.NullConst, true)
+	if f, _ := self.Fields.Get("file"); f != nil {
+		if inst, ok := f.(*vm.VMInstance); ok {
+			inst.Fields.SetForce("res", object.NullConst, true)
+		}
+	}
+
+	if err != nil {
+		return object.NewException("Error writing file %s", err.Error())
+	}
+	return object.NullConst
+}
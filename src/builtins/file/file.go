@@ -2,6 +2,7 @@ package file
 
 import (
 	"bufio"
+	"io"
 	"io/ioutil"
 	"os"
 
@@ -22,14 +23,25 @@ func init() {
 	vm.RegisterNative("std.file.dirlist", directoryList)
 	vm.RegisterNative("std.file.isdir", isDirectory)
 
-	vm.RegisterNativeMethod("std.file.File.init", vmFileOpenFile, 1)
+	vm.RegisterNativeMethod("std.file.File.init", vmFileOpenFile, 3)
 	vm.RegisterNativeMethod("std.file.File.close", vmFileCloseFile, 0)
 	vm.RegisterNativeMethod("std.file.File.write", vmFileWriteFile, 1)
+	vm.RegisterNativeMethod("std.file.File.read", vmFileRead, 1)
 	vm.RegisterNativeMethod("std.file.File.readAll", vmFileReadFullFile, 0)
 	vm.RegisterNativeMethod("std.file.File.readLine", vmFileReadLine, 0)
 	vm.RegisterNativeMethod("std.file.File.readChar", vmFileReadChar, 0)
+	vm.RegisterNativeMethod("std.file.File.seek", vmFileSeek, 2)
+	vm.RegisterNativeMethod("std.file.File.tell", vmFileTell, 0)
+	vm.RegisterNativeMethod("std.file.File.truncate", vmFileTruncate, 1)
+	vm.RegisterNativeMethod("std.file.File.sync", vmFileSync, 0)
+	vm.RegisterNativeMethod("std.file.File.stat", vmFileStat, 0)
 	vm.RegisterNativeMethod("std.file.File.remove", vmFileDeleteFile, 0)
 	vm.RegisterNativeMethod("std.file.File.rename", vmFileRenameFile, 1)
+
+	vm.RegisterNativeMethod("std.file.BufferedWriter.init", vmBufWriterInit, 2)
+	vm.RegisterNativeMethod("std.file.BufferedWriter.write", vmBufWriterWrite, 1)
+	vm.RegisterNativeMethod("std.file.BufferedWriter.flush", vmBufWriterFlush, 0)
+	vm.RegisterNativeMethod("std.file.BufferedWriter.close", vmBufWriterClose, 0)
 }
 
 type fileResource struct {
@@ -43,6 +55,55 @@ func (f *fileResource) Type() object.ObjectType { return object.ResourceObj }
 func (f *fileResource) Dup() object.Object      { return object.NullConst } // Duplicating a file resource isn't allowed
 func (f *fileResource) ResourceID() string      { return fileResourceID }
 
+// Read allows other native modules to stream from a file. It reads through the buffered
+// reader so it stays consistent with readLine and readChar.
+func (f *fileResource) Read(p []byte) (int, error) { return f.reader.Read(p) }
+
+// Write writes at the logical file position. Data that was read ahead into the
+// buffer is discarded first so reads and writes can be mixed.
+func (f *fileResource) Write(p []byte) (int, error) {
+	if buffered := f.reader.Buffered(); buffered > 0 {
+		if _, err := f.file.Seek(int64(-buffered), io.SeekCurrent); err != nil {
+			return 0, err
+		}
+		f.reader.Reset(f.file)
+	}
+	return f.file.Write(p)
+}
+
+func (f *fileResource) seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekCurrent {
+		offset -= int64(f.reader.Buffered())
+	}
+
+	pos, err := f.file.Seek(offset, whence)
+	if err != nil {
+		return 0, err
+	}
+	f.reader.Reset(f.file)
+	return pos, nil
+}
+
+// tell returns the logical file position accounting for data read ahead into the buffer.
+func (f *fileResource) tell() (int64, error) {
+	pos, err := f.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	return pos - int64(f.reader.Buffered()), nil
+}
+
+func fileInfoToHash(info os.FileInfo) *object.Hash {
+	h := object.MakeEmptyHash()
+	h.SetKey("name", object.MakeStringObj(info.Name()))
+	h.SetKey("size", object.MakeIntObj(info.Size()))
+	h.SetKey("mode", object.MakeIntObj(int64(info.Mode().Perm())))
+	h.SetKey("isDir", object.NativeBoolToBooleanObj(info.IsDir()))
+	h.SetKey("isSymlink", object.NativeBoolToBooleanObj(info.Mode()&os.ModeSymlink != 0))
+	h.SetKey("modTime", object.MakeIntObj(info.ModTime().Unix()))
+	return h
+}
+
 var modes = map[string]int{
 	"r":  os.O_RDONLY,
 	"r+": os.O_RDWR,
@@ -50,6 +111,8 @@ var modes = map[string]int{
 	"w+": os.O_RDWR | os.O_TRUNC | os.O_CREATE,
 	"a":  os.O_APPEND | os.O_WRONLY | os.O_CREATE,
 	"a+": os.O_APPEND | os.O_RDWR | os.O_CREATE,
+	"x":  os.O_WRONLY | os.O_CREATE | os.O_EXCL,
+	"x+": os.O_RDWR | os.O_CREATE | os.O_EXCL,
 }
 
 func readFullFile(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
@@ -11,8 +11,78 @@ import (
 	"github.com/nitrogen-lang/nitrogen/src/moduleutils"
 )
 
+// Mode flags that can be combined with | instead of a mode string. These must match
+// the constants in file.ni.
+const (
+	flagRead = 1 << iota
+	flagWrite
+	flagAppend
+	flagCreate
+	flagTruncate
+	flagExclusive
+)
+
+const defaultPerm = 0644
+
+func getFile(name string, self *vm.VMInstance) (*fileResource, *object.Exception) {
+	res, exists := self.Fields.Get("res")
+	if !exists {
+		return nil, object.NewException("File object doesn't contain a resource")
+	}
+
+	file, ok := res.(*fileResource)
+	if !ok {
+		return nil, object.NewException("%s expected a file resource, got %s", name, res.Type().String())
+	}
+	return file, nil
+}
+
+func getFileMode(mode object.Object) (int, *object.Exception) {
+	switch mode := mode.(type) {
+	case *object.String:
+		fileMode, ok := modes[mode.String()]
+		if !ok {
+			return 0, object.NewException("Invalid file mode %s", mode.String())
+		}
+		return fileMode, nil
+
+	case *object.Integer:
+		flags := mode.Value
+		if flags&^(flagRead|flagWrite|flagAppend|flagCreate|flagTruncate|flagExclusive) != 0 {
+			return 0, object.NewException("Invalid file mode flags %d", flags)
+		}
+
+		var fileMode int
+		write := flags&(flagWrite|flagAppend) != 0
+		switch {
+		case write && flags&flagRead != 0:
+			fileMode = os.O_RDWR
+		case write:
+			fileMode = os.O_WRONLY
+		default:
+			fileMode = os.O_RDONLY
+		}
+
+		if flags&flagAppend != 0 {
+			fileMode |= os.O_APPEND
+		}
+		if flags&flagCreate != 0 {
+			fileMode |= os.O_CREATE
+		}
+		if flags&flagTruncate != 0 {
+			fileMode |= os.O_TRUNC
+		}
+		if flags&flagExclusive != 0 {
+			fileMode |= os.O_CREATE | os.O_EXCL
+		}
+		return fileMode, nil
+	}
+
+	return 0, object.NewException("openFile expected a string or int mode, got %s", mode.Type().String())
+}
+
 func vmFileOpenFile(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
-	if ac := moduleutils.CheckArgs("openFile", 2, args...); ac != nil {
+	if ac := moduleutils.CheckMinArgs("openFile", 2, args...); ac != nil {
 		return ac
 	}
 
@@ -21,17 +91,21 @@ func vmFileOpenFile(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *ob
 		return object.NewException("openFile expected a string, got %s", args[0].Type().String())
 	}
 
-	mode, ok := args[1].(*object.String)
-	if !ok {
-		return object.NewException("openFile expected a string, got %s", args[0].Type().String())
+	fileMode, exc := getFileMode(args[1])
+	if exc != nil {
+		return exc
 	}
 
-	fileMode, ok := modes[mode.String()]
-	if !ok {
-		return object.NewException("Invalid file mode %s", mode.String())
+	perm := int64(defaultPerm)
+	if len(args) > 2 && args[2].Type() != object.NullObj {
+		p, ok := args[2].(*object.Integer)
+		if !ok {
+			return object.NewException("openFile expected an int permission, got %s", args[2].Type().String())
+		}
+		perm = p.Value
 	}
 
-	file, err := os.OpenFile(filepath.String(), fileMode, 0644)
+	file, err := os.OpenFile(filepath.String(), fileMode, os.FileMode(perm)&os.ModePerm)
 	if err != nil {
 		return object.NewException("Error opening file %s", err.Error())
 	}
@@ -51,14 +125,9 @@ func vmFileCloseFile(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *o
 		return ac
 	}
 
-	res, exists := self.Fields.Get("res")
-	if !exists {
-		return object.NewException("File object doesn't contain a resource")
-	}
-
-	file, ok := res.(*fileResource)
-	if !ok {
-		return object.NewException("closeFile expected a file resource, got %s", res.Type().String())
+	file, exc := getFile("closeFile", self)
+	if exc != nil {
+		return exc
 	}
 
 	file.file.Close()
@@ -72,22 +141,22 @@ func vmFileWriteFile(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *o
 		return ac
 	}
 
-	res, exists := self.Fields.Get("res")
-	if !exists {
-		return object.NewException("File object doesn't contain a resource")
+	file, exc := getFile("writeFile", self)
+	if exc != nil {
+		return exc
 	}
 
-	file, ok := res.(*fileResource)
-	if !ok {
-		return object.NewException("writeFile expected a file resource, got %s", res.Type().String())
-	}
-
-	str, ok := args[0].(*object.String)
-	if !ok {
+	var data []byte
+	switch arg := args[0].(type) {
+	case *object.String:
+		data = []byte(arg.String())
+	case *object.ByteString:
+		data = arg.Value
+	default:
 		return object.NewException("writeFile expected a string, got %s", args[0].Type().String())
 	}
 
-	written, err := file.file.WriteString(str.String())
+	written, err := file.Write(data)
 	if err != nil {
 		fmt.Fprintln(interpreter.GetStderr(), err)
 	}
@@ -100,17 +169,12 @@ func vmFileReadFullFile(interpreter *vm.VirtualMachine, self *vm.VMInstance, env
 		return ac
 	}
 
-	res, exists := self.Fields.Get("res")
-	if !exists {
-		return object.NewException("File object doesn't contain a resource")
-	}
-
-	file, ok := res.(*fileResource)
-	if !ok {
-		return object.NewException("writeFile expected a file resource, got %s", res.Type().String())
+	file, exc := getFile("readFullFile", self)
+	if exc != nil {
+		return exc
 	}
 
-	bytes, err := io.ReadAll(file.file)
+	bytes, err := io.ReadAll(file.reader)
 	if err != nil {
 		return object.NewException("Error reading file %s", err.Error())
 	}
@@ -118,26 +182,56 @@ func vmFileReadFullFile(interpreter *vm.VirtualMachine, self *vm.VMInstance, env
 	return object.MakeStringObj(string(bytes))
 }
 
-func vmFileReadLine(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
-	if ac := moduleutils.CheckArgs("readLine", 0, args...); ac != nil {
+func vmFileRead(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("read", 1, args...); ac != nil {
 		return ac
 	}
 
-	res, exists := self.Fields.Get("res")
-	if !exists {
-		return object.NewException("File object doesn't contain a resource")
+	file, exc := getFile("read", self)
+	if exc != nil {
+		return exc
 	}
 
-	file, ok := res.(*fileResource)
+	n, ok := args[0].(*object.Integer)
 	if !ok {
-		return object.NewException("writeFile expected a file resource, got %s", res.Type().String())
+		return object.NewException("read expected an int, got %s", args[0].Type().String())
+	}
+	if n.Value < 0 {
+		return object.NewException("read length can't be negative")
+	}
+
+	buf := make([]byte, n.Value)
+	read, err := io.ReadFull(file.reader, buf)
+	if err != nil {
+		if err == io.EOF {
+			if n.Value == 0 {
+				return object.MakeByteStringObjBytes(buf)
+			}
+			return object.NullConst
+		}
+		if err != io.ErrUnexpectedEOF {
+			return object.NewException("%s", err.Error())
+		}
+	}
+
+	return object.MakeByteStringObjBytes(buf[:read])
+}
+
+func vmFileReadLine(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("readLine", 0, args...); ac != nil {
+		return ac
+	}
+
+	file, exc := getFile("readLine", self)
+	if exc != nil {
+		return exc
 	}
 
 	line, err := file.reader.ReadString('\n')
 	if err != nil {
 		if err == io.EOF {
 			if line != "" {
-				object.MakeStringObj(line)
+				return object.MakeStringObj(line)
 			}
 			return object.NullConst
 		}
@@ -152,14 +246,9 @@ func vmFileReadChar(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *ob
 		return ac
 	}
 
-	res, exists := self.Fields.Get("res")
-	if !exists {
-		return object.NewException("File object doesn't contain a resource")
-	}
-
-	file, ok := res.(*fileResource)
-	if !ok {
-		return object.NewException("writeFile expected a file resource, got %s", res.Type().String())
+	file, exc := getFile("readChar", self)
+	if exc != nil {
+		return exc
 	}
 
 	r, _, err := file.reader.ReadRune()
@@ -176,19 +265,119 @@ func vmFileReadChar(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *ob
 	return object.MakeStringObjRunes([]rune{r})
 }
 
-func vmFileDeleteFile(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
-	if ac := moduleutils.CheckArgs("deleteFile", 0, args...); ac != nil {
+func vmFileSeek(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckMinArgs("seek", 1, args...); ac != nil {
 		return ac
 	}
 
-	res, exists := self.Fields.Get("res")
-	if !exists {
-		return object.NewException("File object doesn't contain a resource")
+	file, exc := getFile("seek", self)
+	if exc != nil {
+		return exc
 	}
 
-	file, ok := res.(*fileResource)
+	offset, ok := args[0].(*object.Integer)
+	if !ok {
+		return object.NewException("seek expected an int offset, got %s", args[0].Type().String())
+	}
+
+	whence := int64(io.SeekStart)
+	if len(args) > 1 && args[1].Type() != object.NullObj {
+		w, ok := args[1].(*object.Integer)
+		if !ok {
+			return object.NewException("seek expected an int whence, got %s", args[1].Type().String())
+		}
+		whence = w.Value
+	}
+	if whence < io.SeekStart || whence > io.SeekEnd {
+		return object.NewException("seek invalid whence %d", whence)
+	}
+
+	pos, err := file.seek(offset.Value, int(whence))
+	if err != nil {
+		return object.NewException("Error seeking file %s", err.Error())
+	}
+	return object.MakeIntObj(pos)
+}
+
+func vmFileTell(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("tell", 0, args...); ac != nil {
+		return ac
+	}
+
+	file, exc := getFile("tell", self)
+	if exc != nil {
+		return exc
+	}
+
+	pos, err := file.tell()
+	if err != nil {
+		return object.NewException("Error getting file position %s", err.Error())
+	}
+	return object.MakeIntObj(pos)
+}
+
+func vmFileTruncate(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("truncate", 1, args...); ac != nil {
+		return ac
+	}
+
+	file, exc := getFile("truncate", self)
+	if exc != nil {
+		return exc
+	}
+
+	size, ok := args[0].(*object.Integer)
 	if !ok {
-		return object.NewException("writeFile expected a file resource, got %s", res.Type().String())
+		return object.NewException("truncate expected an int, got %s", args[0].Type().String())
+	}
+
+	if err := file.file.Truncate(size.Value); err != nil {
+		return object.NewException("Error truncating file %s", err.Error())
+	}
+	return object.NullConst
+}
+
+func vmFileSync(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("sync", 0, args...); ac != nil {
+		return ac
+	}
+
+	file, exc := getFile("sync", self)
+	if exc != nil {
+		return exc
+	}
+
+	if err := file.file.Sync(); err != nil {
+		return object.NewException("Error syncing file %s", err.Error())
+	}
+	return object.NullConst
+}
+
+func vmFileStat(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("stat", 0, args...); ac != nil {
+		return ac
+	}
+
+	file, exc := getFile("stat", self)
+	if exc != nil {
+		return exc
+	}
+
+	info, err := file.file.Stat()
+	if err != nil {
+		return object.NewException("Error getting file info %s", err.Error())
+	}
+	return fileInfoToHash(info)
+}
+
+func vmFileDeleteFile(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("deleteFile", 0, args...); ac != nil {
+		return ac
+	}
+
+	file, exc := getFile("deleteFile", self)
+	if exc != nil {
+		return exc
 	}
 
 	filepath := file.file.Name()
@@ -212,14 +401,9 @@ func vmFileRenameFile(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *
 	}
 
 	// Get resource
-	res, exists := self.Fields.Get("res")
-	if !exists {
-		return object.NewException("File object doesn't contain a resource")
-	}
-
-	file, ok := res.(*fileResource)
-	if !ok {
-		return object.NewException("writeFile expected a file resource, got %s", res.Type().String())
+	file, exc := getFile("renameFile", self)
+	if exc != nil {
+		return exc
 	}
 
 	// Get old and new names
@@ -0,0 +1,361 @@
+package file
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm"
+	"github.com/nitrogen-lang/nitrogen/src/moduleutils"
+)
+
+const defaultDirPerm = 0755
+
+func init() {
+	vm.RegisterNative("std.file.mkdir", makeDirectory)
+	vm.RegisterNative("std.file.mkdirAll", makeDirectoryAll)
+	vm.RegisterNative("std.file.removeAll", removeAll)
+	vm.RegisterNative("std.file.stat", statFile)
+	vm.RegisterNative("std.file.chmod", chmodFile)
+	vm.RegisterNative("std.file.symlink", symlinkFile)
+	vm.RegisterNative("std.file.readlink", readlinkFile)
+	vm.RegisterNative("std.file.walk", walkDirectory)
+	vm.RegisterNative("std.file.glob", globFiles)
+	vm.RegisterNative("std.file.copy", copyPath)
+	vm.RegisterNative("std.file.tempDir", tempDirectory)
+	vm.RegisterNative("std.file.tempFile", tempFile)
+}
+
+func getStringArgs(name string, args []object.Object) ([]string, *object.Exception) {
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		s, ok := arg.(*object.String)
+		if !ok {
+			return nil, object.NewException("%s expected a string, got %s", name, arg.Type().String())
+		}
+		strs[i] = s.String()
+	}
+	return strs, nil
+}
+
+// getPerm returns the optional permission argument at index i.
+func getPerm(name string, args []object.Object, i int, def os.FileMode) (os.FileMode, *object.Exception) {
+	if len(args) <= i || args[i].Type() == object.NullObj {
+		return def, nil
+	}
+
+	perm, ok := args[i].(*object.Integer)
+	if !ok {
+		return 0, object.NewException("%s expected an int permission, got %s", name, args[i].Type().String())
+	}
+	return os.FileMode(perm.Value) & os.ModePerm, nil
+}
+
+// getOptionalString returns the optional string argument at index i.
+func getOptionalString(name string, args []object.Object, i int) (string, *object.Exception) {
+	if len(args) <= i || args[i].Type() == object.NullObj {
+		return "", nil
+	}
+
+	s, ok := args[i].(*object.String)
+	if !ok {
+		return "", object.NewException("%s expected a string, got %s", name, args[i].Type().String())
+	}
+	return s.String(), nil
+}
+
+func makeDirectory(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	return mkdir("mkdir", os.Mkdir, args)
+}
+
+func makeDirectoryAll(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	return mkdir("mkdirAll", os.MkdirAll, args)
+}
+
+func mkdir(name string, fn func(string, os.FileMode) error, args []object.Object) object.Object {
+	if ac := moduleutils.CheckMinArgs(name, 1, args...); ac != nil {
+		return ac
+	}
+
+	path, exc := getStringArgs(name, args[:1])
+	if exc != nil {
+		return exc
+	}
+
+	perm, exc := getPerm(name, args, 1, defaultDirPerm)
+	if exc != nil {
+		return exc
+	}
+
+	if err := fn(path[0], perm); err != nil {
+		return object.NewError("Error creating directory %s", err.Error())
+	}
+	return object.NullConst
+}
+
+func removeAll(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("removeAll", 1, args...); ac != nil {
+		return ac
+	}
+
+	path, exc := getStringArgs("removeAll", args)
+	if exc != nil {
+		return exc
+	}
+
+	if err := os.RemoveAll(path[0]); err != nil {
+		return object.NewError("Error removing %s", err.Error())
+	}
+	return object.NullConst
+}
+
+func statFile(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("stat", 1, args...); ac != nil {
+		return ac
+	}
+
+	path, exc := getStringArgs("stat", args)
+	if exc != nil {
+		return exc
+	}
+
+	info, err := os.Lstat(path[0])
+	if err != nil {
+		return object.NewError("Error getting file info %s", err.Error())
+	}
+	return fileInfoToHash(info)
+}
+
+func chmodFile(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("chmod", 2, args...); ac != nil {
+		return ac
+	}
+
+	path, exc := getStringArgs("chmod", args[:1])
+	if exc != nil {
+		return exc
+	}
+
+	perm, exc := getPerm("chmod", args, 1, 0)
+	if exc != nil {
+		return exc
+	}
+
+	if err := os.Chmod(path[0], perm); err != nil {
+		return object.NewError("Error changing file mode %s", err.Error())
+	}
+	return object.NullConst
+}
+
+func symlinkFile(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("symlink", 2, args...); ac != nil {
+		return ac
+	}
+
+	paths, exc := getStringArgs("symlink", args)
+	if exc != nil {
+		return exc
+	}
+
+	if err := os.Symlink(paths[0], paths[1]); err != nil {
+		return object.NewError("Error creating symlink %s", err.Error())
+	}
+	return object.NullConst
+}
+
+func readlinkFile(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("readlink", 1, args...); ac != nil {
+		return ac
+	}
+
+	path, exc := getStringArgs("readlink", args)
+	if exc != nil {
+		return exc
+	}
+
+	target, err := os.Readlink(path[0])
+	if err != nil {
+		return object.NewError("Error reading symlink %s", err.Error())
+	}
+	return object.MakeStringObj(target)
+}
+
+// walkDirectory calls a function for every file and directory under the root, in lexical
+// order. The function can return false to skip a directory, or an error to stop walking.
+func walkDirectory(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("walk", 2, args...); ac != nil {
+		return ac
+	}
+
+	root, exc := getStringArgs("walk", args[:1])
+	if exc != nil {
+		return exc
+	}
+
+	machine := interpreter.(*vm.VirtualMachine)
+	fn := args[1]
+	var ret object.Object = object.NullConst
+
+	err := filepath.WalkDir(root[0], func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		res := machine.Call(fn, object.MakeStringObj(path), fileInfoToHash(info))
+		switch res := res.(type) {
+		case *object.Exception, *object.Error:
+			ret = res
+			return filepath.SkipAll
+		case *object.Boolean:
+			if !res.Value && d.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return object.NewError("Error walking directory %s", err.Error())
+	}
+	return ret
+}
+
+func globFiles(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("glob", 1, args...); ac != nil {
+		return ac
+	}
+
+	pattern, exc := getStringArgs("glob", args)
+	if exc != nil {
+		return exc
+	}
+
+	matches, err := filepath.Glob(pattern[0])
+	if err != nil {
+		return object.NewError("Invalid glob pattern %s", err.Error())
+	}
+	return object.MakeStringArray(matches)
+}
+
+// copyPath copies a file, or a directory recursively. Permissions are preserved and
+// symlinks are recreated rather than followed.
+func copyPath(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("copy", 2, args...); ac != nil {
+		return ac
+	}
+
+	paths, exc := getStringArgs("copy", args)
+	if exc != nil {
+		return exc
+	}
+	src, dst := paths[0], paths[1]
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return object.NewError("Error copying %s", err.Error())
+	}
+
+	if !info.IsDir() {
+		err = copyFile(src, dst, info.Mode().Perm())
+	} else {
+		err = filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(src, path)
+			if err != nil {
+				return err
+			}
+			target := filepath.Join(dst, rel)
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case d.IsDir():
+				return os.MkdirAll(target, info.Mode().Perm())
+			case info.Mode()&os.ModeSymlink != 0:
+				link, err := os.Readlink(path)
+				if err != nil {
+					return err
+				}
+				return os.Symlink(link, target)
+			}
+			return copyFile(path, target, info.Mode().Perm())
+		})
+	}
+
+	if err != nil {
+		return object.NewError("Error copying %s", err.Error())
+	}
+	return object.NullConst
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func tempDirectory(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	dir, pattern, exc := getTempArgs("tempDir", args)
+	if exc != nil {
+		return exc
+	}
+
+	path, err := os.MkdirTemp(dir, pattern)
+	if err != nil {
+		return object.NewError("Error creating temporary directory %s", err.Error())
+	}
+	return object.MakeStringObj(path)
+}
+
+func tempFile(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	dir, pattern, exc := getTempArgs("tempFile", args)
+	if exc != nil {
+		return exc
+	}
+
+	file, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return object.NewError("Error creating temporary file %s", err.Error())
+	}
+	file.Close()
+	return object.MakeStringObj(file.Name())
+}
+
+func getTempArgs(name string, args []object.Object) (string, string, *object.Exception) {
+	dir, exc := getOptionalString(name, args, 0)
+	if exc != nil {
+		return "", "", exc
+	}
+
+	pattern, exc := getOptionalString(name, args, 1)
+	if exc != nil {
+		return "", "", exc
+	}
+	return dir, pattern, nil
+}
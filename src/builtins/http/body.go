@@ -0,0 +1,152 @@
+package string
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm"
+	"github.com/nitrogen-lang/nitrogen/src/moduleutils"
+)
+
+const bodyResourceID = "std.http.body"
+
+func init() {
+	vm.RegisterNativeMethod("std.http.ResponseBody.read", vmBodyRead, 1)
+	vm.RegisterNativeMethod("std.http.ResponseBody.readLine", vmBodyReadLine, 0)
+	vm.RegisterNativeMethod("std.http.ResponseBody.readAll", vmBodyReadAll, 0)
+	vm.RegisterNativeMethod("std.http.ResponseBody.close", vmBodyClose, 0)
+}
+
+// bodyResource is the body of a streamed response.
+type bodyResource struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+	bytes  bool
+}
+
+// closeAtEOF closes the body once it's been read to the end so the connection is
+// released even if close is never called. Later reads see an empty body.
+func (b *bodyResource) closeAtEOF() {
+	b.body.Close()
+	b.reader.Reset(bytes.NewReader(nil))
+}
+
+func (b *bodyResource) Inspect() string         { return "HTTP response body resource" }
+func (b *bodyResource) Type() object.ObjectType { return object.ResourceObj }
+func (b *bodyResource) Dup() object.Object      { return b }
+func (b *bodyResource) ResourceID() string      { return bodyResourceID }
+
+func getBody(name string, self *vm.VMInstance) (*bodyResource, *object.Exception) {
+	res, exists := self.Fields.Get("res")
+	if !exists {
+		return nil, object.NewException("ResponseBody object doesn't contain a resource")
+	}
+
+	body, ok := res.(*bodyResource)
+	if !ok {
+		return nil, object.NewException("%s expected a response body resource, got %s", name, res.Type().String())
+	}
+	return body, nil
+}
+
+func vmBodyRead(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("read", 1, args...); ac != nil {
+		return ac
+	}
+
+	body, exc := getBody("read", self)
+	if exc != nil {
+		return exc
+	}
+
+	n, ok := args[0].(*object.Integer)
+	if !ok {
+		return object.NewException("read expected an int, got %s", args[0].Type().String())
+	}
+	if n.Value < 0 {
+		return object.NewException("read length can't be negative")
+	}
+
+	buf := make([]byte, n.Value)
+	read, err := io.ReadFull(body.reader, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		body.closeAtEOF()
+	}
+	if err != nil {
+		if err == io.EOF {
+			if n.Value == 0 {
+				return object.MakeByteStringObjBytes(buf)
+			}
+			return object.NullConst
+		}
+		if err != io.ErrUnexpectedEOF {
+			return object.NewException("Error reading response %s", err.Error())
+		}
+	}
+
+	return object.MakeByteStringObjBytes(buf[:read])
+}
+
+func vmBodyReadLine(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("readLine", 0, args...); ac != nil {
+		return ac
+	}
+
+	body, exc := getBody("readLine", self)
+	if exc != nil {
+		return exc
+	}
+
+	line, err := body.reader.ReadString('\n')
+	if err != nil {
+		if err == io.EOF {
+			body.closeAtEOF()
+			if line != "" {
+				return object.MakeStringObj(line)
+			}
+			return object.NullConst
+		}
+		return object.NewException("Error reading response %s", err.Error())
+	}
+
+	return object.MakeStringObj(line[:len(line)-1])
+}
+
+func vmBodyReadAll(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("readAll", 0, args...); ac != nil {
+		return ac
+	}
+
+	body, exc := getBody("readAll", self)
+	if exc != nil {
+		return exc
+	}
+
+	data, err := io.ReadAll(body.reader)
+	if err != nil {
+		return object.NewException("Error reading response %s", err.Error())
+	}
+	body.closeAtEOF()
+
+	if body.bytes {
+		return object.MakeByteStringObjBytes(data)
+	}
+	return object.MakeStringObj(string(data))
+}
+
+func vmBodyClose(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("close", 0, args...); ac != nil {
+		return ac
+	}
+
+	body, exc := getBody("close", self)
+	if exc != nil {
+		return exc
+	}
+
+	body.body.Close()
+	self.Fields.SetForce("res", object.NullConst, true)
+	return object.NullConst
+}
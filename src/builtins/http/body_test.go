@@ -0,0 +1,66 @@
+package string
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm"
+)
+
+type closeCounter struct {
+	io.Reader
+	closed int
+}
+
+func (c *closeCounter) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestBodyClosedAtEOF(t *testing.T) {
+	tests := []struct {
+		name string
+		read func(self *vm.VMInstance) object.Object
+	}{
+		{
+			name: "read",
+			read: func(self *vm.VMInstance) object.Object {
+				vmBodyRead(nil, self, nil, object.MakeIntObj(100))
+				return vmBodyRead(nil, self, nil, object.MakeIntObj(1))
+			},
+		},
+		{
+			name: "readLine",
+			read: func(self *vm.VMInstance) object.Object {
+				for vmBodyReadLine(nil, self, nil) != object.NullConst {
+				}
+				return vmBodyReadLine(nil, self, nil)
+			},
+		},
+		{
+			name: "readAll",
+			read: func(self *vm.VMInstance) object.Object {
+				vmBodyReadAll(nil, self, nil)
+				return vmBodyRead(nil, self, nil, object.MakeIntObj(1))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body := &closeCounter{Reader: strings.NewReader("line 1\nline 2\n")}
+			self := &vm.VMInstance{Fields: object.NewEnvironment()}
+			self.Fields.SetForce("res", &bodyResource{body: body, reader: bufio.NewReader(body)}, true)
+
+			if ret := test.read(self); ret != object.NullConst {
+				t.Fatalf("Expected nil after the end of the body, got %s", ret.Inspect())
+			}
+			if body.closed == 0 {
+				t.Fatal("Body wasn't closed after reading to the end")
+			}
+		})
+	}
+}
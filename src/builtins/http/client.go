@@ -0,0 +1,118 @@
+package string
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm"
+	"github.com/nitrogen-lang/nitrogen/src/moduleutils"
+)
+
+const clientResourceID = "std.http.client"
+
+func init() {
+	vm.RegisterNativeMethod("std.http.Client.init", vmClientInit, 1)
+	vm.RegisterNativeMethod("std.http.Client.doReq", vmClientDoReq, 4)
+	vm.RegisterNativeMethod("std.http.Client.cookies", vmClientCookies, 1)
+}
+
+// clientResource keeps the default options and cookies of a Client between requests.
+type clientResource struct {
+	options *requestOptions
+	jar     http.CookieJar
+}
+
+func (c *clientResource) Inspect() string         { return "HTTP client resource" }
+func (c *clientResource) Type() object.ObjectType { return object.ResourceObj }
+func (c *clientResource) Dup() object.Object      { return c }
+func (c *clientResource) ResourceID() string      { return clientResourceID }
+
+func getClient(name string, self *vm.VMInstance) (*clientResource, *object.Exception) {
+	res, exists := self.Fields.Get("res")
+	if !exists {
+		return nil, object.NewException("Client object doesn't contain a resource")
+	}
+
+	client, ok := res.(*clientResource)
+	if !ok {
+		return nil, object.NewException("%s expected a client resource, got %s", name, res.Type().String())
+	}
+	return client, nil
+}
+
+func vmClientInit(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	var optionsObj *object.Hash
+	if len(args) > 0 && args[0].Type() != object.NullObj {
+		hash, ok := args[0].(*object.Hash)
+		if !ok {
+			return object.NewException("Client expected options to be a map, got %s", args[0].Type().String())
+		}
+		optionsObj = hash
+	}
+
+	options, exc := parseOptions(defaultOptions(), optionsObj)
+	if exc != nil {
+		return exc
+	}
+
+	client := &clientResource{options: options}
+
+	useCookies := true
+	if optionsObj != nil {
+		if cookies := optionsObj.LookupKey("cookies"); cookies != nil {
+			cookiesBool, ok := cookies.(*object.Boolean)
+			if !ok {
+				return object.NewException("cookies option must be a boolean")
+			}
+			useCookies = cookiesBool.Value
+		}
+	}
+
+	if useCookies {
+		// cookiejar.New never returns an error
+		client.jar, _ = cookiejar.New(nil)
+	}
+
+	self.Fields.SetForce("res", client, true)
+	return nil
+}
+
+func vmClientDoReq(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	client, exc := getClient("req", self)
+	if exc != nil {
+		return exc
+	}
+
+	return makeRequest(client.jar, client.options, args...)
+}
+
+func vmClientCookies(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("cookies", 1, args...); ac != nil {
+		return ac
+	}
+
+	client, exc := getClient("cookies", self)
+	if exc != nil {
+		return exc
+	}
+
+	urlStr, ok := args[0].(*object.String)
+	if !ok {
+		return object.NewException("cookies expected a string, got %s", args[0].Type().String())
+	}
+
+	u, err := url.Parse(urlStr.String())
+	if err != nil {
+		return object.NewException("Invalid URL %s", err.Error())
+	}
+
+	cookies := object.MakeEmptyHash()
+	if client.jar != nil {
+		for _, cookie := range client.jar.Cookies(u) {
+			cookies.SetKey(cookie.Name, object.MakeStringObj(cookie.Value))
+		}
+	}
+	return cookies
+}
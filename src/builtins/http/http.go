@@ -1,30 +1,210 @@
 package string
 
 import (
+	"bufio"
+	"bytes"
 	"crypto/tls"
+	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
 	"github.com/nitrogen-lang/nitrogen/src/elemental/vm"
 	"github.com/nitrogen-lang/nitrogen/src/moduleutils"
 )
 
+const defaultMaxRedirects = 10
+
 func init() {
-	vm.RegisterNative("std.http.req", doReq)
+	vm.RegisterNative("std.http.doReq", doReq)
 	vm.RegisterNative("std.http.canonicalHeaderKey", canonicalHeaderKey)
 }
 
+// requestOptions are the parsed HTTPOptions of a request.
+type requestOptions struct {
+	headers         http.Header
+	timeout         time.Duration
+	followRedirects bool
+	maxRedirects    int
+	tlsVerify       bool
+	basicAuth       []string
+	bytesResponse   bool
+	stream          bool
+	multipart       *object.Hash
+}
+
+func defaultOptions() *requestOptions {
+	return &requestOptions{
+		headers:         http.Header{},
+		followRedirects: true,
+		maxRedirects:    defaultMaxRedirects,
+		tlsVerify:       true,
+	}
+}
+
+// parseOptions applies an HTTPOptions map on top of base. base isn't modified.
+func parseOptions(base *requestOptions, options *object.Hash) (*requestOptions, *object.Exception) {
+	opts := *base
+	opts.headers = base.headers.Clone()
+	if options == nil {
+		return &opts, nil
+	}
+
+	if headers := options.LookupKey("headers"); headers != nil {
+		headersMap, ok := headers.(*object.Hash)
+		if !ok {
+			return nil, object.NewException("headers option must be a map")
+		}
+
+		for _, pair := range headersMap.Pairs {
+			if pair.Key.Type() != object.StringObj {
+				continue
+			}
+			if pair.Value.Type() != object.StringObj {
+				continue
+			}
+			opts.headers.Set(pair.Key.(*object.String).String(), pair.Value.(*object.String).String())
+		}
+	}
+
+	if tlsVerify := options.LookupKey("tls_verify"); tlsVerify != nil {
+		verifyBool, ok := tlsVerify.(*object.Boolean)
+		if !ok {
+			return nil, object.NewException("tls_verify option must be a boolean")
+		}
+		opts.tlsVerify = verifyBool.Value
+	}
+
+	if timeout := options.LookupKey("timeout"); timeout != nil {
+		// A time.Duration instance or int nanoseconds
+		if inst, ok := timeout.(*vm.VMInstance); ok {
+			timeout, _ = inst.Fields.Get("ns")
+		}
+
+		ns, ok := timeout.(*object.Integer)
+		if !ok || ns.Value < 0 {
+			return nil, object.NewException("timeout option must be a Duration or positive int")
+		}
+		opts.timeout = time.Duration(ns.Value)
+	}
+
+	if follow := options.LookupKey("follow_redirects"); follow != nil {
+		followBool, ok := follow.(*object.Boolean)
+		if !ok {
+			return nil, object.NewException("follow_redirects option must be a boolean")
+		}
+		opts.followRedirects = followBool.Value
+	}
+
+	if max := options.LookupKey("max_redirects"); max != nil {
+		maxInt, ok := max.(*object.Integer)
+		if !ok || maxInt.Value < 0 {
+			return nil, object.NewException("max_redirects option must be a positive int")
+		}
+		opts.maxRedirects = int(maxInt.Value)
+	}
+
+	if auth := options.LookupKey("basic_auth"); auth != nil {
+		authArr, ok := auth.(*object.Array)
+		if !ok || len(authArr.Elements) != 2 {
+			return nil, object.NewException("basic_auth option must be an array of username and password")
+		}
+
+		user, ok1 := authArr.Elements[0].(*object.String)
+		pass, ok2 := authArr.Elements[1].(*object.String)
+		if !ok1 || !ok2 {
+			return nil, object.NewException("basic_auth username and password must be strings")
+		}
+		opts.basicAuth = []string{user.String(), pass.String()}
+	}
+
+	if respType := options.LookupKey("response_type"); respType != nil {
+		respTypeStr, ok := respType.(*object.String)
+		if !ok {
+			return nil, object.NewException("response_type option must be a string")
+		}
+
+		switch respTypeStr.String() {
+		case "string":
+			opts.bytesResponse = false
+		case "bytes":
+			opts.bytesResponse = true
+		default:
+			return nil, object.NewException("response_type option must be \"string\" or \"bytes\"")
+		}
+	}
+
+	if stream := options.LookupKey("stream"); stream != nil {
+		streamBool, ok := stream.(*object.Boolean)
+		if !ok {
+			return nil, object.NewException("stream option must be a boolean")
+		}
+		opts.stream = streamBool.Value
+	}
+
+	if form := options.LookupKey("multipart"); form != nil {
+		formMap, ok := form.(*object.Hash)
+		if !ok {
+			return nil, object.NewException("multipart option must be a map")
+		}
+		opts.multipart = formMap
+	}
+
+	return &opts, nil
+}
+
+func (o *requestOptions) checkRedirect(req *http.Request, via []*http.Request) error {
+	if !o.followRedirects {
+		return http.ErrUseLastResponse
+	}
+	if len(via) > o.maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", o.maxRedirects)
+	}
+	return nil
+}
+
+var (
+	insecureTransport     *http.Transport
+	insecureTransportOnce sync.Once
+)
+
+// transport returns a shared transport so connections can be reused between requests.
+func transport(tlsVerify bool) http.RoundTripper {
+	if tlsVerify {
+		return http.DefaultTransport
+	}
+
+	insecureTransportOnce.Do(func() {
+		insecureTransport = http.DefaultTransport.(*http.Transport).Clone()
+		insecureTransport.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: true,
+		}
+	})
+	return insecureTransport
+}
+
 func doReq(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
-	if ac := moduleutils.CheckMinArgs("http.doReq", 2, args...); ac != nil {
+	return makeRequest(nil, defaultOptions(), args...)
+}
+
+// makeRequest sends a request with the arguments method, url, data, and options. Options
+// are applied on top of base. jar may be nil to not use cookies.
+func makeRequest(jar http.CookieJar, base *requestOptions, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckMinArgs("http.req", 2, args...); ac != nil {
 		return ac
 	}
 
 	// Argument 1 - HTTP method
 	methodObj, ok := args[0].(*object.String)
 	if !ok {
-		return object.NewException("http.doReq expected first argument to be a string, got %s", args[0].Type().String())
+		return object.NewException("http.req expected first argument to be a string, got %s", args[0].Type().String())
 	}
 	method := strings.ToUpper(methodObj.String())
 	if method == "" {
@@ -34,22 +214,12 @@ func doReq(interpreter object.Interpreter, env *object.Environment, args ...obje
 	// Argument 2 - URL
 	urlObj, ok := args[1].(*object.String)
 	if !ok {
-		return object.NewException("http.doReq expected second argument to be a string, got %s", args[1].Type().String())
+		return object.NewException("http.req expected second argument to be a string, got %s", args[1].Type().String())
 	}
 
 	url := strings.TrimSpace(urlObj.String())
 	if url == "" {
-		return object.NewException("http.doReq expected a non-empty string")
-	}
-
-	// Argument 3 - Data payload
-	data := ""
-	if len(args) >= 3 && args[2] != object.NullConst {
-		dataObj, ok := args[2].(*object.String)
-		if !ok {
-			return object.NewException("http.doReq expected third argument to be a string, got %s", args[2].Type().String())
-		}
-		data = dataObj.String()
+		return object.NewException("http.req expected a non-empty string")
 	}
 
 	// Argument 4 - Request options
@@ -57,68 +227,192 @@ func doReq(interpreter object.Interpreter, env *object.Environment, args ...obje
 	if len(args) >= 4 && args[3] != object.NullConst {
 		dataObj, ok := args[3].(*object.Hash)
 		if !ok {
-			return object.NewException("http.post expected fourth argument to be a map, got %s", args[3].Type().String())
+			return object.NewException("http.req expected fourth argument to be a map, got %s", args[3].Type().String())
 		}
 		optionsObj = dataObj
 	}
 
-	client := &http.Client{}
+	opts, exc := parseOptions(base, optionsObj)
+	if exc != nil {
+		return exc
+	}
 
-	req, err := http.NewRequest(method, url, strings.NewReader(data))
+	// Argument 3 - Data payload
+	var body io.Reader = http.NoBody
+	contentType := ""
+	if len(args) >= 3 && args[2] != object.NullConst {
+		switch data := args[2].(type) {
+		case *object.String:
+			if data.String() != "" {
+				body = strings.NewReader(data.String())
+				contentType = "application/x-www-form-urlencoded"
+			}
+		case *object.ByteString:
+			body = bytes.NewReader(data.Value)
+			contentType = "application/octet-stream"
+		default:
+			return object.NewException("http.req expected third argument to be a string, got %s", args[2].Type().String())
+		}
+	}
+
+	if opts.multipart != nil {
+		if body != http.NoBody {
+			return object.NewException("http.req can't send data with the multipart option")
+		}
+
+		form, formType, err := encodeMultipart(opts.multipart)
+		if err != nil {
+			return object.NewException("error encoding multipart form: %s", err.Error())
+		}
+		body = form
+		opts.headers.Set("Content-Type", formType)
+	}
+
+	req, err := http.NewRequest(method, url, body)
 	if err != nil {
 		return object.NewException("error making HTTP request: %s", err.Error())
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	if optionsObj != nil {
-		headers := optionsObj.LookupKey("headers")
-		if headers != nil {
-			headersMap, ok := headers.(*object.Hash)
-			if !ok {
-				return object.NewException("headers option must be a map")
-			}
+	req.Header = opts.headers
+	if contentType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if opts.basicAuth != nil {
+		req.SetBasicAuth(opts.basicAuth[0], opts.basicAuth[1])
+	}
 
-			for _, pair := range headersMap.Pairs {
-				if pair.Key.Type() != object.StringObj {
-					continue
-				}
-				if pair.Value.Type() != object.StringObj {
-					continue
-				}
-				req.Header.Set(pair.Key.(*object.String).String(), pair.Value.(*object.String).String())
-			}
+	client := &http.Client{
+		Transport:     transport(opts.tlsVerify),
+		CheckRedirect: opts.checkRedirect,
+		Jar:           jar,
+		Timeout:       opts.timeout,
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return object.NewException("error making HTTP request: %s", err.Error())
+	}
+
+	if opts.stream {
+		return buildReturnValue(resp, &bodyResource{
+			body:   resp.Body,
+			reader: bufio.NewReader(resp.Body),
+			bytes:  opts.bytesResponse,
+		})
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return object.NewException("error reading HTTP response: %s", err.Error())
+	}
+
+	if opts.bytesResponse {
+		return buildReturnValue(resp, object.MakeByteStringObjBytes(data))
+	}
+	return buildReturnValue(resp, object.MakeStringObj(string(data)))
+}
+
+// encodeMultipart encodes a map of form fields. String values are sent as fields and
+// maps as files with the keys filename, content or path, and content_type.
+func encodeMultipart(form *object.Hash) (io.Reader, string, error) {
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+
+	// Sort the fields so requests are reproducible
+	names := make([]string, 0, len(form.Pairs))
+	for _, pair := range form.Pairs {
+		name, ok := pair.Key.(*object.String)
+		if !ok {
+			return nil, "", fmt.Errorf("field names must be strings")
 		}
+		names = append(names, name.String())
+	}
+	sort.Strings(names)
 
-		tlsVerify := optionsObj.LookupKey("tls_verify")
-		if tlsVerify != nil {
-			verifyBool, ok := tlsVerify.(*object.Boolean)
-			if !ok {
-				return object.NewException("tls_verify option must be a boolean")
+	for _, name := range names {
+		switch field := form.LookupKey(name).(type) {
+		case *object.String:
+			if err := w.WriteField(name, field.String()); err != nil {
+				return nil, "", err
 			}
-
-			if !verifyBool.Value {
-				client.Transport = &http.Transport{
-					TLSClientConfig: &tls.Config{
-						InsecureSkipVerify: true,
-					},
-				}
+		case *object.ByteString:
+			if err := w.WriteField(name, string(field.Value)); err != nil {
+				return nil, "", err
 			}
+		case *object.Hash:
+			if err := writeMultipartFile(w, name, field); err != nil {
+				return nil, "", err
+			}
+		default:
+			return nil, "", fmt.Errorf("field %s must be a string or map", name)
 		}
 	}
 
-	resp, err := client.Do(req)
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf, w.FormDataContentType(), nil
+}
 
+func writeMultipartFile(w *multipart.Writer, name string, file *object.Hash) error {
+	filename, ok := file.LookupKey("filename").(*object.String)
+	if !ok {
+		return fmt.Errorf("file %s must have a string filename", name)
+	}
+
+	contentType := "application/octet-stream"
+	if ct := file.LookupKey("content_type"); ct != nil {
+		ctStr, ok := ct.(*object.String)
+		if !ok {
+			return fmt.Errorf("file %s content_type must be a string", name)
+		}
+		contentType = ctStr.String()
+	}
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(name), escapeQuotes(filename.String())))
+	h.Set("Content-Type", contentType)
+
+	part, err := w.CreatePart(h)
 	if err != nil {
-		return object.NewException("error making HTTP request: %s", err.Error())
+		return err
+	}
+
+	switch content := file.LookupKey("content").(type) {
+	case *object.String:
+		_, err = io.WriteString(part, content.String())
+		return err
+	case *object.ByteString:
+		_, err = part.Write(content.Value)
+		return err
+	case nil:
+	default:
+		return fmt.Errorf("file %s content must be a string", name)
 	}
-	defer resp.Body.Close()
 
-	return buildReturnValue(resp)
+	path, ok := file.LookupKey("path").(*object.String)
+	if !ok {
+		return fmt.Errorf("file %s must have a content or path", name)
+	}
+
+	f, err := os.Open(path.String())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(part, f)
+	return err
 }
 
-func buildReturnValue(resp *http.Response) object.Object {
-	body, _ := io.ReadAll(resp.Body)
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
 
+func buildReturnValue(resp *http.Response, body object.Object) object.Object {
 	headers := make(map[string]string, len(resp.Header))
 
 	for name, values := range resp.Header {
@@ -126,12 +420,13 @@ func buildReturnValue(resp *http.Response) object.Object {
 	}
 
 	hash := &object.Hash{
-		Pairs: make(map[object.HashKey]object.HashPair, 2),
+		Pairs: make(map[object.HashKey]object.HashPair, 4),
 	}
 
-	hash.SetKey("body", object.MakeStringObj(string(body)))
+	hash.SetKey("body", body)
 	hash.SetKey("headers", object.StringMapToHash(headers))
 	hash.SetKey("status_code", object.MakeIntObj(int64(resp.StatusCode)))
+	hash.SetKey("url", object.MakeStringObj(resp.Request.URL.String()))
 
 	return hash
 }
@@ -0,0 +1,262 @@
+package string_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nitrogen-lang/nitrogen/src/moduleutils"
+	"github.com/nitrogen-lang/nitrogen/src/nitrogen"
+)
+
+func init() {
+	moduleutils.WriteCompiledScripts = false
+}
+
+func newTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %q %s", r.Method, r.Header.Get("Content-Type"), body)
+	})
+
+	mux.HandleFunc("/bytes", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte{0, 1, 255})
+	})
+
+	mux.HandleFunc("/auth", func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("welcome"))
+	})
+
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+	})
+
+	mux.HandleFunc("/redirect/{n}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("n") == "0" {
+			w.Write([]byte("done"))
+			return
+		}
+		var n int
+		fmt.Sscan(r.PathValue("n"), &n)
+		http.Redirect(w, r, fmt.Sprintf("/redirect/%d", n-1), http.StatusFound)
+	})
+
+	mux.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		data, _ := io.ReadAll(file)
+		fmt.Fprintf(w, "%s %s %s %s", r.FormValue("name"), header.Filename, header.Header.Get("Content-Type"), data)
+	})
+
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc123", Path: "/"})
+	})
+
+	mux.HandleFunc("/session", func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie("session")
+		if err != nil {
+			w.Write([]byte("none"))
+			return
+		}
+		w.Write([]byte(c.Value))
+	})
+
+	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(w, "line %d\n", i)
+			w.(http.Flusher).Flush()
+		}
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestHTTPClient(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		script   string
+		expected interface{}
+		err      string
+	}{
+		{
+			name:     "no content type without data",
+			script:   `return http.get(base + "/echo").body`,
+			expected: `GET "" `,
+		},
+		{
+			name:     "string data",
+			script:   `return http.req("POST", base + "/echo", "a=1").body`,
+			expected: `POST "application/x-www-form-urlencoded" a=1`,
+		},
+		{
+			name:     "byte string data",
+			script:   `return http.req("PUT", base + "/echo", b"\x00\x01").body`,
+			expected: "PUT \"application/octet-stream\" \x00\x01",
+		},
+		{
+			name:     "content type header overrides default",
+			script:   `return http.post(base + "/echo", b"data", {"headers": {"Content-Type": "text/plain"}}).body`,
+			expected: `POST "text/plain" data`,
+		},
+		{
+			name:     "json data",
+			script:   `return http.post(base + "/echo", {"a": 1}).body`,
+			expected: `POST "application/json" {"a":1}`,
+		},
+		{
+			name:     "byte string response",
+			script:   `return http.get(base + "/bytes", {"response_type": "bytes"}).body`,
+			expected: []byte{0, 1, 255},
+		},
+		{
+			name:     "basic auth",
+			script:   `return http.get(base + "/auth", {"basic_auth": ["admin", "secret"]}).body`,
+			expected: "welcome",
+		},
+		{
+			name:     "basic auth missing",
+			script:   `return http.get(base + "/auth").status_code`,
+			expected: int64(401),
+		},
+		{
+			name:   "timeout",
+			script: `return http.get(base + "/slow", {"timeout": 50000000})`,
+			err:    "Client.Timeout exceeded",
+		},
+		{
+			name: "timeout duration",
+			script: `import "std/time"
+return http.get(base + "/slow", {"timeout": new time.Duration(50 * time.MILLISECOND)})`,
+			err: "Client.Timeout exceeded",
+		},
+		{
+			name:     "follow redirects",
+			script:   `const resp = http.get(base + "/redirect/3"); return [resp.body, resp.url]`,
+			expected: []interface{}{"done", srv.URL + "/redirect/0"},
+		},
+		{
+			name:     "don't follow redirects",
+			script:   `const resp = http.get(base + "/redirect/3", {"follow_redirects": false}); return [resp.status_code, resp.headers.Location]`,
+			expected: []interface{}{int64(302), "/redirect/2"},
+		},
+		{
+			name:   "max redirects",
+			script: `return http.get(base + "/redirect/3", {"max_redirects": 2})`,
+			err:    "stopped after 2 redirects",
+		},
+		{
+			name: "multipart upload",
+			script: `return http.post(base + "/upload", nil, {"multipart": {
+    "name": "report",
+    "file": {"filename": "data.csv", "content": b"a,b", "content_type": "text/csv"},
+}}).body`,
+			expected: "report data.csv text/csv a,b",
+		},
+		{
+			name:   "multipart with data",
+			script: `return http.post(base + "/upload", "data", {"multipart": {"name": "report"}})`,
+			err:    "can't send data with the multipart option",
+		},
+		{
+			name: "client cookies",
+			script: `const c = new http.Client()
+const before = c.get(base + "/session").body
+c.get(base + "/login")
+return [before, c.get(base + "/session").body, c.cookies(base).session, http.get(base + "/session").body]`,
+			expected: []interface{}{"none", "abc123", "abc123", "none"},
+		},
+		{
+			name: "client without cookies",
+			script: `const c = new http.Client({"cookies": false})
+c.get(base + "/login")
+return c.get(base + "/session").body`,
+			expected: "none",
+		},
+		{
+			name: "client default options",
+			script: `const c = new http.Client({"basic_auth": ["admin", "secret"], "headers": {"Content-Type": "text/plain"}})
+return [c.get(base + "/auth").body, c.post(base + "/echo", "hi").body, c.get(base + "/auth", {"basic_auth": ["admin", "wrong"]}).status_code]`,
+			expected: []interface{}{"welcome", `POST "text/plain" hi`, int64(401)},
+		},
+		{
+			name: "json data",
+			script: `const c = new http.Client()
+return [c.post(base + "/echo", {"a": 1}).body, http.put(base + "/echo", [1]).body, isError(c.patch(base + "/echo", fn() {}))]`,
+			expected: []interface{}{`POST "application/json" {"a":1}`, `PUT "application/json" [1]`, true},
+		},
+		{
+			name: "stream response",
+			script: `const resp = http.get(base + "/stream", {"stream": true})
+let lines = []
+for i, line in resp.body { lines = push(lines, line) }
+resp.body.close()
+return lines`,
+			expected: []interface{}{"line 1", "line 2", "line 3"},
+		},
+		{
+			name: "stream read",
+			script: `const resp = http.get(base + "/stream", {"stream": true})
+const start = resp.body.read(4)
+const remaining = resp.body.readAll()
+const end = resp.body.read(1)
+resp.body.close()
+return [start, remaining, end]`,
+			expected: []interface{}{[]byte("line"), " 1\nline 2\nline 3\n", nil},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := nitrogen.NewRuntime(&nitrogen.Options{
+				SearchPaths: []string{"../../../nitrogen"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Set("base", srv.URL); err != nil {
+				t.Fatal(err)
+			}
+
+			ret, err := r.Eval("import \"std/http\"\n" + test.script)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("Expected error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := r.ToGo(ret); !reflect.DeepEqual(got, test.expected) {
+				t.Fatalf("Wrong return value. Expected %#v, got %#v", test.expected, got)
+			}
+		})
+	}
+}
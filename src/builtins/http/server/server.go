@@ -0,0 +1,523 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm"
+	"github.com/nitrogen-lang/nitrogen/src/moduleutils"
+)
+
+const (
+	serverResourceID = "std.http.server"
+
+	defaultAddr            = "127.0.0.1:8080"
+	defaultWorkers         = 5
+	defaultWorkerTimeout   = 10 * time.Second
+	defaultShutdownTimeout = 5 * time.Second
+	defaultMaxBodySize     = 10 << 20
+)
+
+var wildcardRegex = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+func init() {
+	vm.RegisterNativeMethod("std.http.server.Server.init", vmServerInit, 1)
+	vm.RegisterNativeMethod("std.http.server.Server.route", vmServerRoute, 3)
+	vm.RegisterNativeMethod("std.http.server.Server.static", vmServerStatic, 2)
+	vm.RegisterNativeMethod("std.http.server.Server.start", vmServerStart, 0)
+	vm.RegisterNativeMethod("std.http.server.Server.listen", vmServerListen, 0)
+	vm.RegisterNativeMethod("std.http.server.Server.shutdown", vmServerShutdown, 0)
+}
+
+type serverResource struct {
+	mux             *http.ServeMux
+	addr            string
+	workers         int
+	workerTimeout   time.Duration
+	shutdownTimeout time.Duration
+	maxBodySize     int64
+
+	// Set when the server is started
+	owner    *vm.VirtualMachine
+	pool     chan *vm.VirtualMachine
+	srv      *http.Server
+	done     chan struct{}
+	serveErr error
+	stopOnce sync.Once
+}
+
+func (s *serverResource) Inspect() string         { return "HTTP server resource" }
+func (s *serverResource) Type() object.ObjectType { return object.ResourceObj }
+func (s *serverResource) Dup() object.Object      { return object.NullConst } // A listening server can't be duplicated
+func (s *serverResource) ResourceID() string      { return serverResourceID }
+
+func getServer(name string, self *vm.VMInstance) (*serverResource, *object.Exception) {
+	res, exists := self.Fields.Get("res")
+	if !exists {
+		return nil, object.NewException("Server object doesn't contain a resource")
+	}
+
+	s, ok := res.(*serverResource)
+	if !ok {
+		return nil, object.NewException("%s expected a server resource, got %s", name, res.Type().String())
+	}
+	return s, nil
+}
+
+// getDuration accepts a time.Duration instance or an int number of nanoseconds.
+func getDuration(name string, arg object.Object) (time.Duration, *object.Exception) {
+	if inst, ok := arg.(*vm.VMInstance); ok {
+		arg, _ = inst.Fields.Get("ns")
+	}
+
+	n, ok := arg.(*object.Integer)
+	if !ok {
+		return 0, object.NewException("Server expected %s to be a Duration or int", name)
+	}
+	return time.Duration(n.Value), nil
+}
+
+func getPositiveInt(name string, arg object.Object) (int64, *object.Exception) {
+	n, ok := arg.(*object.Integer)
+	if !ok || n.Value <= 0 {
+		return 0, object.NewException("Server expected %s to be a positive int", name)
+	}
+	return n.Value, nil
+}
+
+func vmServerInit(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	s := &serverResource{
+		mux:             http.NewServeMux(),
+		addr:            defaultAddr,
+		workers:         defaultWorkers,
+		workerTimeout:   defaultWorkerTimeout,
+		shutdownTimeout: defaultShutdownTimeout,
+		maxBodySize:     defaultMaxBodySize,
+	}
+
+	if len(args) > 0 && args[0].Type() != object.NullObj {
+		options, ok := args[0].(*object.Hash)
+		if !ok {
+			return object.NewException("Server expected options to be a map, got %s", args[0].Type().String())
+		}
+		if exc := s.setOptions(options); exc != nil {
+			return exc
+		}
+	}
+
+	self.Fields.SetForce("addr", object.MakeStringObj(s.addr), true)
+	self.Fields.SetForce("res", s, true)
+	return nil
+}
+
+func (s *serverResource) setOptions(options *object.Hash) *object.Exception {
+	var exc *object.Exception
+
+	if addr := options.LookupKey("addr"); addr != nil {
+		str, ok := addr.(*object.String)
+		if !ok {
+			return object.NewException("Server expected addr to be a string, got %s", addr.Type().String())
+		}
+		s.addr = str.String()
+	}
+
+	if workers := options.LookupKey("workers"); workers != nil {
+		n, exc := getPositiveInt("workers", workers)
+		if exc != nil {
+			return exc
+		}
+		s.workers = int(n)
+	}
+
+	if max := options.LookupKey("maxBodySize"); max != nil {
+		if s.maxBodySize, exc = getPositiveInt("maxBodySize", max); exc != nil {
+			return exc
+		}
+	}
+
+	if timeout := options.LookupKey("workerTimeout"); timeout != nil {
+		if s.workerTimeout, exc = getDuration("workerTimeout", timeout); exc != nil {
+			return exc
+		}
+	}
+
+	if timeout := options.LookupKey("shutdownTimeout"); timeout != nil {
+		if s.shutdownTimeout, exc = getDuration("shutdownTimeout", timeout); exc != nil {
+			return exc
+		}
+	}
+	return nil
+}
+
+// register adds a handler to the mux. ServeMux panics on invalid or conflicting
+// patterns, those are converted to exceptions.
+func (s *serverResource) register(pattern string, handler http.Handler) (exc *object.Exception) {
+	defer func() {
+		if r := recover(); r != nil {
+			exc = object.NewException("Invalid route: %v", r)
+		}
+	}()
+
+	s.mux.Handle(pattern, handler)
+	return nil
+}
+
+func vmServerRoute(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("route", 3, args...); ac != nil {
+		return ac
+	}
+
+	s, exc := getServer("route", self)
+	if exc != nil {
+		return exc
+	}
+
+	method, ok := args[0].(*object.String)
+	if !ok {
+		return object.NewException("route expected a string method, got %s", args[0].Type().String())
+	}
+
+	path, ok := args[1].(*object.String)
+	if !ok {
+		return object.NewException("route expected a string pattern, got %s", args[1].Type().String())
+	}
+
+	switch args[2].(type) {
+	case *vm.VMFunction, *vm.BoundMethod, *object.Builtin:
+	default:
+		return object.NewException("route expected a function, got %s", args[2].Type().String())
+	}
+
+	pattern := path.String()
+	if m := strings.ToUpper(method.String()); m != "" && m != "*" {
+		pattern = m + " " + pattern
+	}
+
+	var params []string
+	for _, match := range wildcardRegex.FindAllStringSubmatch(path.String(), -1) {
+		params = append(params, match[1])
+	}
+
+	if exc := s.register(pattern, s.handler(args[2], params)); exc != nil {
+		return exc
+	}
+	return object.NullConst
+}
+
+func vmServerStatic(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("static", 2, args...); ac != nil {
+		return ac
+	}
+
+	s, exc := getServer("static", self)
+	if exc != nil {
+		return exc
+	}
+
+	prefix, ok := args[0].(*object.String)
+	if !ok {
+		return object.NewException("static expected a string prefix, got %s", args[0].Type().String())
+	}
+
+	dir, ok := args[1].(*object.String)
+	if !ok {
+		return object.NewException("static expected a string directory, got %s", args[1].Type().String())
+	}
+
+	if !strings.HasSuffix(prefix.String(), "/") {
+		return object.NewException("static prefix must end with a slash")
+	}
+
+	handler := http.StripPrefix(prefix.String(), http.FileServer(http.Dir(dir.String())))
+	if exc := s.register(prefix.String(), handler); exc != nil {
+		return exc
+	}
+	return object.NullConst
+}
+
+func (s *serverResource) start(machine *vm.VirtualMachine) error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	// Use the bound address in case the port was 0
+	s.addr = ln.Addr().String()
+	s.owner = machine
+	s.pool = make(chan *vm.VirtualMachine, s.workers)
+	for i := 0; i < s.workers; i++ {
+		s.pool <- machine.Fork()
+	}
+
+	s.srv = &http.Server{Handler: s.mux}
+	s.done = make(chan struct{})
+
+	go func() {
+		err := s.srv.Serve(ln)
+		if !errors.Is(err, http.ErrServerClosed) {
+			s.serveErr = err
+		}
+		close(s.done)
+	}()
+
+	return nil
+}
+
+// stop gracefully shuts down the server, waiting for active requests to finish
+// until the shutdown timeout.
+func (s *serverResource) stop() error {
+	var err error
+	s.stopOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+		defer cancel()
+		err = s.srv.Shutdown(ctx)
+	})
+	<-s.done
+	return err
+}
+
+func vmServerStart(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("start", 0, args...); ac != nil {
+		return ac
+	}
+
+	s, exc := getServer("start", self)
+	if exc != nil {
+		return exc
+	}
+
+	if s.srv != nil {
+		return object.NewException("Server already started")
+	}
+
+	if err := s.start(interpreter); err != nil {
+		return object.NewError("Error starting server %s", err.Error())
+	}
+
+	addr := object.MakeStringObj(s.addr)
+	self.Fields.SetForce("addr", addr, true)
+	return addr
+}
+
+func vmServerListen(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("listen", 0, args...); ac != nil {
+		return ac
+	}
+
+	s, exc := getServer("listen", self)
+	if exc != nil {
+		return exc
+	}
+
+	if s.srv == nil {
+		if err := s.start(interpreter); err != nil {
+			return object.NewError("Error starting server %s", err.Error())
+		}
+		self.Fields.SetForce("addr", object.MakeStringObj(s.addr), true)
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	select {
+	case <-sigs:
+		if err := s.stop(); err != nil {
+			return object.NewError("Error shutting down server %s", err.Error())
+		}
+	case <-s.done:
+	}
+
+	if s.serveErr != nil {
+		return object.NewError("Error serving %s", s.serveErr.Error())
+	}
+	return object.NullConst
+}
+
+func vmServerShutdown(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("shutdown", 0, args...); ac != nil {
+		return ac
+	}
+
+	s, exc := getServer("shutdown", self)
+	if exc != nil {
+		return exc
+	}
+
+	if s.srv == nil {
+		return object.NullConst
+	}
+
+	// A handler can't wait for the shutdown since it waits for the handler to finish
+	if interpreter != s.owner {
+		go s.stop()
+		return object.NullConst
+	}
+
+	if err := s.stop(); err != nil {
+		return object.NewError("Error shutting down server %s", err.Error())
+	}
+	return object.NullConst
+}
+
+func (s *serverResource) handler(fn object.Object, params []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		timer := time.NewTimer(s.workerTimeout)
+		var machine *vm.VirtualMachine
+		select {
+		case machine = <-s.pool:
+			timer.Stop()
+		case <-timer.C:
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		defer func() {
+			if rec := recover(); rec != nil {
+				fmt.Fprintf(s.owner.GetStderr(), "http server: panic handling %s %s: %v\n", r.Method, r.URL.Path, rec)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				// The VM may be left in a bad state so replace it
+				machine = s.owner.Fork()
+			}
+			s.pool <- machine
+		}()
+
+		req, err := makeRequest(w, r, params, s.maxBodySize)
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		resp := machine.Call(fn, req)
+		if err := writeResponse(w, resp); err != nil {
+			fmt.Fprintf(s.owner.GetStderr(), "http server: error handling %s %s: %s\n", r.Method, r.URL.Path, err.Error())
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+	}
+}
+
+func makeRequest(w http.ResponseWriter, r *http.Request, params []string, maxBodySize int64) (*object.Hash, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		return nil, err
+	}
+
+	query := object.MakeEmptyHash()
+	for key, values := range r.URL.Query() {
+		query.SetKey(key, object.MakeStringObj(values[0]))
+	}
+
+	headers := make(map[string]string, len(r.Header))
+	for name, values := range r.Header {
+		headers[name] = strings.Join(values, ", ")
+	}
+
+	paramHash := object.MakeEmptyHash()
+	for _, name := range params {
+		paramHash.SetKey(name, object.MakeStringObj(r.PathValue(name)))
+	}
+
+	req := object.MakeEmptyHash()
+	req.SetKey("method", object.MakeStringObj(r.Method))
+	req.SetKey("path", object.MakeStringObj(r.URL.Path))
+	req.SetKey("rawQuery", object.MakeStringObj(r.URL.RawQuery))
+	req.SetKey("query", query)
+	req.SetKey("params", paramHash)
+	req.SetKey("headers", object.StringMapToHash(headers))
+	req.SetKey("body", object.MakeStringObj(string(body)))
+	req.SetKey("host", object.MakeStringObj(r.Host))
+	req.SetKey("remoteAddr", object.MakeStringObj(r.RemoteAddr))
+	return req, nil
+}
+
+func writeResponse(w http.ResponseWriter, resp object.Object) error {
+	switch resp := resp.(type) {
+	case *object.Null:
+		w.WriteHeader(http.StatusNoContent)
+	case *object.String:
+		w.Write([]byte(resp.String()))
+	case *object.ByteString:
+		w.Write(resp.Value)
+	case *object.Hash:
+		return writeHashResponse(w, resp)
+	case *object.Exception:
+		return errors.New(resp.Message)
+	case *object.Error:
+		return errors.New(resp.Message)
+	default:
+		return fmt.Errorf("handler returned unsupported type %s", resp.Type().String())
+	}
+	return nil
+}
+
+func writeHashResponse(w http.ResponseWriter, resp *object.Hash) error {
+	status := http.StatusOK
+	if s := resp.LookupKey("status"); s != nil {
+		n, ok := s.(*object.Integer)
+		if !ok || n.Value < 100 || n.Value > 999 {
+			return fmt.Errorf("response status must be an int between 100 and 999")
+		}
+		status = int(n.Value)
+	}
+
+	var body []byte
+	switch b := resp.LookupKey("body").(type) {
+	case nil, *object.Null:
+	case *object.String:
+		body = []byte(b.String())
+	case *object.ByteString:
+		body = b.Value
+	default:
+		return fmt.Errorf("response body must be a string, got %s", b.Type().String())
+	}
+
+	if h := resp.LookupKey("headers"); h != nil {
+		headers, ok := h.(*object.Hash)
+		if !ok {
+			return fmt.Errorf("response headers must be a map, got %s", h.Type().String())
+		}
+
+		for _, pair := range headers.Pairs {
+			name, ok := pair.Key.(*object.String)
+			if !ok {
+				return fmt.Errorf("response header names must be strings")
+			}
+
+			switch v := pair.Value.(type) {
+			case *object.String:
+				w.Header().Set(name.String(), v.String())
+			case *object.Array:
+				for _, el := range v.Elements {
+					s, ok := el.(*object.String)
+					if !ok {
+						return fmt.Errorf("response header values must be strings")
+					}
+					w.Header().Add(name.String(), s.String())
+				}
+			default:
+				return fmt.Errorf("response header values must be strings")
+			}
+		}
+	}
+
+	w.WriteHeader(status)
+	w.Write(body)
+	return nil
+}
@@ -0,0 +1,715 @@
+package os
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm"
+	"github.com/nitrogen-lang/nitrogen/src/moduleutils"
+)
+
+const (
+	processResourceID = "std.os.process"
+
+	// drainTimeout limits how long wait reads output left in a pipe after the process
+	// exits. It only matters if a process the command started still holds the pipe open.
+	drainTimeout = 100 * time.Millisecond
+)
+
+var signals = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGPIPE": syscall.SIGPIPE,
+	"SIGTERM": syscall.SIGTERM,
+}
+
+func init() {
+	vm.RegisterNativeMethod("std.os.Process.init", vmProcessInit, 3)
+	vm.RegisterNativeMethod("std.os.Process.write", vmProcessWrite, 1)
+	vm.RegisterNativeMethod("std.os.Process.closeStdin", vmProcessCloseStdin, 0)
+	vm.RegisterNativeMethod("std.os.Process.read", makeStreamRead("stdout"), 1)
+	vm.RegisterNativeMethod("std.os.Process.readLine", makeStreamReadLine("stdout"), 0)
+	vm.RegisterNativeMethod("std.os.Process.readAll", makeStreamReadAll("stdout"), 0)
+	vm.RegisterNativeMethod("std.os.Process.readErr", makeStreamRead("stderr"), 1)
+	vm.RegisterNativeMethod("std.os.Process.readErrLine", makeStreamReadLine("stderr"), 0)
+	vm.RegisterNativeMethod("std.os.Process.readErrAll", makeStreamReadAll("stderr"), 0)
+	vm.RegisterNativeMethod("std.os.Process.closeStdout", makeStreamClose("stdout"), 0)
+	vm.RegisterNativeMethod("std.os.Process.closeStderr", makeStreamClose("stderr"), 0)
+	vm.RegisterNativeMethod("std.os.Process.output", makeOutput("stdout"), 0)
+	vm.RegisterNativeMethod("std.os.Process.errOutput", makeOutput("stderr"), 0)
+	vm.RegisterNativeMethod("std.os.Process.wait", vmProcessWait, 1)
+	vm.RegisterNativeMethod("std.os.Process.kill", vmProcessKill, 1)
+	vm.RegisterNativeMethod("std.os.Process.running", vmProcessRunning, 0)
+	vm.RegisterNativeMethod("std.os.Process.exitCode", vmProcessExitCode, 0)
+	vm.RegisterNativeMethod("std.os.Process.signal", vmProcessSignal, 0)
+}
+
+// lockedBuffer collects captured output. It's written by the exec copy goroutine
+// while the script may read it.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// processStream is the read end of a stdout or stderr pipe. The file is nil once the
+// pipe is closed or connected to another process.
+type processStream struct {
+	file      *os.File
+	reader    *bufio.Reader
+	connected bool
+	closed    bool
+}
+
+// release closes the read end of the pipe. If drain is true, output left in the pipe
+// is kept in memory so it can still be read.
+func (s *processStream) release(drain bool) {
+	if s.file == nil {
+		return
+	}
+
+	if drain {
+		s.file.SetReadDeadline(time.Now().Add(drainTimeout))
+		data, _ := io.ReadAll(s.reader)
+		s.reader = bufio.NewReader(bytes.NewReader(data))
+	} else {
+		s.closed = true
+	}
+	s.file.Close()
+	s.file = nil
+}
+
+type processResource struct {
+	cmd     *exec.Cmd
+	stdin   *os.File
+	streams map[string]*processStream
+	output  map[string]*lockedBuffer
+	done    chan struct{}
+}
+
+func (p *processResource) Inspect() string         { return "Process resource" }
+func (p *processResource) Type() object.ObjectType { return object.ResourceObj }
+func (p *processResource) Dup() object.Object      { return object.NullConst } // A process can't be duplicated
+func (p *processResource) ResourceID() string      { return processResourceID }
+
+// closePipes closes stdin and the stdout and stderr read ends. Unread output is kept
+// if drain is true.
+func (p *processResource) closePipes(drain bool) {
+	if p.stdin != nil {
+		p.stdin.Close()
+		p.stdin = nil
+	}
+	for _, s := range p.streams {
+		s.release(drain)
+	}
+}
+
+func (p *processResource) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func getProcess(name string, self *vm.VMInstance) (*processResource, *object.Exception) {
+	res, exists := self.Fields.Get("res")
+	if !exists {
+		return nil, object.NewException("Process object doesn't contain a resource")
+	}
+
+	p, ok := res.(*processResource)
+	if !ok {
+		return nil, object.NewException("%s expected a process resource, got %s", name, res.Type().String())
+	}
+	return p, nil
+}
+
+func getStringArray(name string, arg object.Object) ([]string, *object.Exception) {
+	arr, ok := arg.(*object.Array)
+	if !ok {
+		return nil, object.NewException("%s expected an array, got %s", name, arg.Type().String())
+	}
+
+	strs := make([]string, len(arr.Elements))
+	for i, element := range arr.Elements {
+		s, ok := element.(*object.String)
+		if !ok {
+			return nil, object.NewException("%s arguments must be a string %s", name, element.Inspect())
+		}
+		strs[i] = s.String()
+	}
+	return strs, nil
+}
+
+func vmProcessInit(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckMinArgs("Process", 1, args...); ac != nil {
+		return ac
+	}
+
+	cmdName, ok := args[0].(*object.String)
+	if !ok {
+		return object.NewException("Process expected a string, got %s", args[0].Type().String())
+	}
+
+	var cmdArgs []string
+	if len(args) > 1 && args[1].Type() != object.NullObj {
+		var exc *object.Exception
+		cmdArgs, exc = getStringArray("Process", args[1])
+		if exc != nil {
+			return exc
+		}
+	}
+
+	options := object.MakeEmptyHash()
+	if len(args) > 2 && args[2].Type() != object.NullObj {
+		options, ok = args[2].(*object.Hash)
+		if !ok {
+			return object.NewException("Process expected options to be a map, got %s", args[2].Type().String())
+		}
+	}
+
+	p := &processResource{
+		cmd:     exec.Command(cmdName.String(), cmdArgs...),
+		streams: make(map[string]*processStream),
+		output:  make(map[string]*lockedBuffer),
+		done:    make(chan struct{}),
+	}
+
+	if exc := setProcessOptions(p.cmd, options); exc != nil {
+		return exc
+	}
+
+	// Child ends of pipes are closed once the process has started, the parent ends
+	// only if it fails to start
+	var childFiles []*os.File
+	started := false
+	defer func() {
+		for _, f := range childFiles {
+			f.Close()
+		}
+		if !started {
+			for _, s := range p.streams {
+				s.file.Close()
+			}
+			if p.stdin != nil {
+				p.stdin.Close()
+			}
+		}
+	}()
+
+	stdin := options.LookupKey("stdin")
+	if stdin == nil {
+		stdin = object.MakeStringObj("pipe")
+	}
+
+	switch stdin := stdin.(type) {
+	case *vm.VMInstance:
+		// Pipeline, read from the stdout of another process
+		src, exc := getProcess("Process", stdin)
+		if exc != nil {
+			return exc
+		}
+		stream := src.streams["stdout"]
+		if stream == nil || stream.connected {
+			return object.NewException("Process stdin process must have stdout set to \"pipe\"")
+		}
+		if stream.file == nil {
+			return object.NewException("Process stdin process stdout is closed")
+		}
+		p.cmd.Stdin = stream.file
+		childFiles = append(childFiles, stream.file)
+		stream.file = nil
+		stream.connected = true
+	case *object.String:
+		switch stdin.String() {
+		case "pipe":
+			r, w, err := os.Pipe()
+			if err != nil {
+				return object.NewException("Error creating pipe %s", err.Error())
+			}
+			p.cmd.Stdin = r
+			p.stdin = w
+			childFiles = append(childFiles, r)
+		case "inherit":
+			// Passing the file lets the process use it directly instead of exec
+			// copying from it in a goroutine that outlives the process
+			p.cmd.Stdin = os.Stdin
+		case "null":
+		default:
+			return object.NewException("Invalid stdin mode %s", stdin.String())
+		}
+	default:
+		return object.NewException("Process expected stdin to be a string or Process, got %s", stdin.Type().String())
+	}
+
+	for _, name := range []string{"stdout", "stderr"} {
+		mode := "capture"
+		if m := options.LookupKey(name); m != nil {
+			s, ok := m.(*object.String)
+			if !ok {
+				return object.NewException("Process expected %s to be a string, got %s", name, m.Type().String())
+			}
+			mode = s.String()
+		}
+
+		var w io.Writer
+		switch mode {
+		case "capture":
+			buf := &lockedBuffer{}
+			p.output[name] = buf
+			w = buf
+		case "pipe":
+			r, pw, err := os.Pipe()
+			if err != nil {
+				return object.NewException("Error creating pipe %s", err.Error())
+			}
+			p.streams[name] = &processStream{file: r, reader: bufio.NewReader(r)}
+			childFiles = append(childFiles, pw)
+			w = pw
+		case "inherit":
+			if name == "stdout" {
+				w = interpreter.GetStdout()
+			} else {
+				w = interpreter.GetStderr()
+			}
+		case "null":
+		default:
+			return object.NewException("Invalid %s mode %s", name, mode)
+		}
+
+		if name == "stdout" {
+			p.cmd.Stdout = w
+		} else {
+			p.cmd.Stderr = w
+		}
+	}
+
+	if err := p.cmd.Start(); err != nil {
+		return object.NewException("Error starting process %s", err.Error())
+	}
+	started = true
+
+	go func() {
+		p.cmd.Wait()
+		close(p.done)
+	}()
+
+	self.Fields.SetForce("cmd", cmdName, true)
+	if len(args) > 1 && args[1].Type() != object.NullObj {
+		self.Fields.SetForce("args", args[1], true)
+	}
+	self.Fields.SetForce("pid", object.MakeIntObj(int64(p.cmd.Process.Pid)), true)
+	self.Fields.SetForce("res", p, true)
+	return nil
+}
+
+func setProcessOptions(cmd *exec.Cmd, options *object.Hash) *object.Exception {
+	if cwd := options.LookupKey("cwd"); cwd != nil {
+		s, ok := cwd.(*object.String)
+		if !ok {
+			return object.NewException("Process expected cwd to be a string, got %s", cwd.Type().String())
+		}
+		cmd.Dir = s.String()
+	}
+
+	clearEnv := false
+	if c := options.LookupKey("clearEnv"); c != nil {
+		b, ok := c.(*object.Boolean)
+		if !ok {
+			return object.NewException("Process expected clearEnv to be a bool, got %s", c.Type().String())
+		}
+		clearEnv = b.Value
+	}
+
+	var environ []string
+	if !clearEnv {
+		environ = os.Environ()
+	}
+
+	if e := options.LookupKey("env"); e != nil {
+		h, ok := e.(*object.Hash)
+		if !ok {
+			return object.NewException("Process expected env to be a map, got %s", e.Type().String())
+		}
+
+		// Sorted so the environment is deterministic
+		vars := make([]string, 0, len(h.Pairs))
+		for _, pair := range h.Pairs {
+			k, ok := pair.Key.(*object.String)
+			if !ok {
+				return object.NewException("Process env keys must be strings, got %s", pair.Key.Type().String())
+			}
+			v, ok := pair.Value.(*object.String)
+			if !ok {
+				return object.NewException("Process env values must be strings, got %s", pair.Value.Type().String())
+			}
+			vars = append(vars, k.String()+"="+v.String())
+		}
+		sort.Strings(vars)
+		environ = append(environ, vars...)
+	}
+
+	if clearEnv && environ == nil {
+		environ = []string{}
+	}
+	cmd.Env = environ
+	return nil
+}
+
+func vmProcessWrite(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("write", 1, args...); ac != nil {
+		return ac
+	}
+
+	p, exc := getProcess("write", self)
+	if exc != nil {
+		return exc
+	}
+
+	if p.stdin == nil {
+		return object.NewException("Process stdin isn't a pipe")
+	}
+
+	var data []byte
+	switch arg := args[0].(type) {
+	case *object.String:
+		data = []byte(arg.String())
+	case *object.ByteString:
+		data = arg.Value
+	default:
+		return object.NewException("write expected a string, got %s", args[0].Type().String())
+	}
+
+	n, err := p.stdin.Write(data)
+	if err != nil {
+		return object.NewException("Error writing to process %s", err.Error())
+	}
+	return object.MakeIntObj(int64(n))
+}
+
+func vmProcessCloseStdin(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("closeStdin", 0, args...); ac != nil {
+		return ac
+	}
+
+	p, exc := getProcess("closeStdin", self)
+	if exc != nil {
+		return exc
+	}
+
+	if p.stdin != nil {
+		p.stdin.Close()
+		p.stdin = nil
+	}
+	return object.NullConst
+}
+
+func getStream(fnName, name string, self *vm.VMInstance) (*processStream, *object.Exception) {
+	p, exc := getProcess(fnName, self)
+	if exc != nil {
+		return nil, exc
+	}
+
+	stream := p.streams[name]
+	if stream == nil {
+		return nil, object.NewException("Process %s isn't a pipe", name)
+	}
+	if stream.connected {
+		return nil, object.NewException("Process %s is connected to another process", name)
+	}
+	if stream.closed {
+		return nil, object.NewException("Process %s is closed", name)
+	}
+	return stream, nil
+}
+
+func makeStreamClose(name string) vm.BuiltinMethodFunction {
+	fnName := "closeStdout"
+	if name == "stderr" {
+		fnName = "closeStderr"
+	}
+
+	return func(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+		if ac := moduleutils.CheckArgs(fnName, 0, args...); ac != nil {
+			return ac
+		}
+
+		p, exc := getProcess(fnName, self)
+		if exc != nil {
+			return exc
+		}
+
+		stream := p.streams[name]
+		if stream == nil {
+			return object.NewException("Process %s isn't a pipe", name)
+		}
+		if stream.connected {
+			return object.NewException("Process %s is connected to another process", name)
+		}
+
+		stream.release(false)
+		stream.closed = true
+		return object.NullConst
+	}
+}
+
+func makeStreamRead(name string) vm.BuiltinMethodFunction {
+	return func(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+		if ac := moduleutils.CheckArgs("read", 1, args...); ac != nil {
+			return ac
+		}
+
+		stream, exc := getStream("read", name, self)
+		if exc != nil {
+			return exc
+		}
+
+		n, ok := args[0].(*object.Integer)
+		if !ok {
+			return object.NewException("read expected an int, got %s", args[0].Type().String())
+		}
+		if n.Value <= 0 {
+			return object.NewException("read length must be positive")
+		}
+
+		// Return what's available rather than waiting for n bytes
+		buf := make([]byte, n.Value)
+		read, err := stream.reader.Read(buf)
+		if err != nil {
+			if err == io.EOF {
+				return object.NullConst
+			}
+			return object.NewException("%s", err.Error())
+		}
+		return object.MakeByteStringObjBytes(buf[:read])
+	}
+}
+
+func makeStreamReadLine(name string) vm.BuiltinMethodFunction {
+	return func(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+		if ac := moduleutils.CheckArgs("readLine", 0, args...); ac != nil {
+			return ac
+		}
+
+		stream, exc := getStream("readLine", name, self)
+		if exc != nil {
+			return exc
+		}
+
+		line, err := stream.reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				if line != "" {
+					return object.MakeStringObj(line)
+				}
+				return object.NullConst
+			}
+			return object.NewException("%s", err.Error())
+		}
+		return object.MakeStringObj(line[:len(line)-1])
+	}
+}
+
+func makeStreamReadAll(name string) vm.BuiltinMethodFunction {
+	return func(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+		if ac := moduleutils.CheckArgs("readAll", 0, args...); ac != nil {
+			return ac
+		}
+
+		stream, exc := getStream("readAll", name, self)
+		if exc != nil {
+			return exc
+		}
+
+		data, err := io.ReadAll(stream.reader)
+		if err != nil {
+			return object.NewException("%s", err.Error())
+		}
+		return object.MakeStringObj(string(data))
+	}
+}
+
+func makeOutput(name string) vm.BuiltinMethodFunction {
+	return func(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+		if ac := moduleutils.CheckArgs("output", 0, args...); ac != nil {
+			return ac
+		}
+
+		p, exc := getProcess("output", self)
+		if exc != nil {
+			return exc
+		}
+
+		buf := p.output[name]
+		if buf == nil {
+			return object.NewException("Process %s isn't captured", name)
+		}
+		return object.MakeStringObj(buf.String())
+	}
+}
+
+// getTimeout returns the optional timeout as either an int number of nanoseconds
+// or a time.Duration instance. A negative duration means no timeout.
+func getTimeout(arg object.Object) (time.Duration, *object.Exception) {
+	if inst, ok := arg.(*vm.VMInstance); ok {
+		arg, _ = inst.Fields.Get("ns")
+	}
+
+	switch arg := arg.(type) {
+	case nil, *object.Null:
+		return -1, nil
+	case *object.Integer:
+		return time.Duration(arg.Value), nil
+	}
+	return 0, object.NewException("wait expected a Duration or int, got %s", arg.Type().String())
+}
+
+func vmProcessWait(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	p, exc := getProcess("wait", self)
+	if exc != nil {
+		return exc
+	}
+
+	timeout := time.Duration(-1)
+	if len(args) > 0 {
+		timeout, exc = getTimeout(args[0])
+		if exc != nil {
+			return exc
+		}
+	}
+
+	// Close stdin so processes reading it until EOF can finish
+	if p.stdin != nil {
+		p.stdin.Close()
+		p.stdin = nil
+	}
+
+	if timeout < 0 {
+		<-p.done
+	} else {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case <-p.done:
+		case <-timer.C:
+			return object.NullConst
+		}
+	}
+
+	// Output still in the pipes can be read after the process exits
+	p.closePipes(true)
+	return object.MakeIntObj(int64(p.cmd.ProcessState.ExitCode()))
+}
+
+func vmProcessKill(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	p, exc := getProcess("kill", self)
+	if exc != nil {
+		return exc
+	}
+
+	sig := syscall.SIGKILL
+	if len(args) > 0 && args[0].Type() != object.NullObj {
+		switch arg := args[0].(type) {
+		case *object.String:
+			s, ok := signals[arg.String()]
+			if !ok {
+				return object.NewException("kill unknown signal %s", arg.String())
+			}
+			sig = s
+		case *object.Integer:
+			sig = syscall.Signal(arg.Value)
+		default:
+			return object.NewException("kill expected a string or int, got %s", args[0].Type().String())
+		}
+	}
+
+	p.closePipes(false)
+	if p.exited() {
+		return object.NullConst
+	}
+
+	if err := p.cmd.Process.Signal(sig); err != nil {
+		return object.NewError("Error signaling process %s", err.Error())
+	}
+	return object.NullConst
+}
+
+func vmProcessRunning(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("running", 0, args...); ac != nil {
+		return ac
+	}
+
+	p, exc := getProcess("running", self)
+	if exc != nil {
+		return exc
+	}
+	return object.NativeBoolToBooleanObj(!p.exited())
+}
+
+func vmProcessExitCode(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("exitCode", 0, args...); ac != nil {
+		return ac
+	}
+
+	p, exc := getProcess("exitCode", self)
+	if exc != nil {
+		return exc
+	}
+
+	if !p.exited() {
+		return object.NullConst
+	}
+	return object.MakeIntObj(int64(p.cmd.ProcessState.ExitCode()))
+}
+
+func vmProcessSignal(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("signal", 0, args...); ac != nil {
+		return ac
+	}
+
+	p, exc := getProcess("signal", self)
+	if exc != nil {
+		return exc
+	}
+
+	if !p.exited() {
+		return object.NullConst
+	}
+
+	status, ok := p.cmd.ProcessState.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return object.NullConst
+	}
+
+	sig := status.Signal()
+	for name, s := range signals {
+		if s == sig {
+			return object.MakeStringObj(name)
+		}
+	}
+	return object.MakeIntObj(int64(sig))
+}
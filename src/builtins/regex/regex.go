@@ -0,0 +1,319 @@
+package regex
+
+import (
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm"
+	"github.com/nitrogen-lang/nitrogen/src/moduleutils"
+)
+
+const (
+	regexResourceID = "std.regex"
+)
+
+func init() {
+	vm.RegisterNative("std.regex.quote", quoteRegex)
+
+	vm.RegisterNativeMethod("std.regex.Regex.init", vmRegexInit, 1)
+	vm.RegisterNativeMethod("std.regex.Regex.match", vmRegexMatch, 1)
+	vm.RegisterNativeMethod("std.regex.Regex.find", vmRegexFind, 1)
+	vm.RegisterNativeMethod("std.regex.Regex.findAll", vmRegexFindAll, 2)
+	vm.RegisterNativeMethod("std.regex.Regex.replace", vmRegexReplace, 2)
+	vm.RegisterNativeMethod("std.regex.Regex.split", vmRegexSplit, 2)
+	vm.RegisterNativeMethod("std.regex.Regex.groupNames", vmRegexGroupNames, 0)
+}
+
+type regexResource struct {
+	re *regexp.Regexp
+}
+
+func (r *regexResource) Inspect() string         { return "Regex resource" }
+func (r *regexResource) Type() object.ObjectType { return object.ResourceObj }
+func (r *regexResource) Dup() object.Object      { return r } // Compiled patterns are immutable and safe to share
+func (r *regexResource) ResourceID() string      { return regexResourceID }
+
+func quoteRegex(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("quote", 1, args...); ac != nil {
+		return ac
+	}
+
+	s, ok := args[0].(*object.String)
+	if !ok {
+		return object.NewException("quote expected a string, got %s", args[0].Type().String())
+	}
+
+	return object.MakeStringObj(regexp.QuoteMeta(s.String()))
+}
+
+func getRegex(self *vm.VMInstance) (*regexp.Regexp, *object.Exception) {
+	res, exists := self.Fields.Get("res")
+	if !exists {
+		return nil, object.NewException("Regex object doesn't contain a resource")
+	}
+
+	r, ok := res.(*regexResource)
+	if !ok {
+		return nil, object.NewException("Regex object doesn't contain a compiled pattern")
+	}
+	return r.re, nil
+}
+
+func getSubject(name string, arg object.Object) (string, *object.Exception) {
+	switch arg := arg.(type) {
+	case *object.String:
+		return arg.String(), nil
+	case *object.ByteString:
+		return arg.String(), nil
+	}
+	return "", object.NewException("%s expected a string, got %s", name, arg.Type().String())
+}
+
+// getLimit returns the optional match limit argument at index i. A missing or nil
+// argument means no limit.
+func getLimit(name string, args []object.Object, i int) (int, *object.Exception) {
+	if len(args) <= i || args[i].Type() == object.NullObj {
+		return -1, nil
+	}
+
+	n, ok := args[i].(*object.Integer)
+	if !ok {
+		return 0, object.NewException("%s expected an int, got %s", name, args[i].Type().String())
+	}
+	return int(n.Value), nil
+}
+
+func vmRegexInit(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("Regex", 1, args...); ac != nil {
+		return ac
+	}
+
+	pattern, ok := args[0].(*object.String)
+	if !ok {
+		return object.NewException("Regex expected a string, got %s", args[0].Type().String())
+	}
+
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return object.NewException("Invalid regex: %s", err.Error())
+	}
+
+	self.Fields.SetForce("pattern", pattern, true)
+	self.Fields.SetForce("res", &regexResource{re: re}, true)
+	return nil
+}
+
+func vmRegexMatch(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("match", 1, args...); ac != nil {
+		return ac
+	}
+
+	re, exc := getRegex(self)
+	if exc != nil {
+		return exc
+	}
+
+	s, exc := getSubject("match", args[0])
+	if exc != nil {
+		return exc
+	}
+
+	return object.NativeBoolToBooleanObj(re.MatchString(s))
+}
+
+func vmRegexFind(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("find", 1, args...); ac != nil {
+		return ac
+	}
+
+	re, exc := getRegex(self)
+	if exc != nil {
+		return exc
+	}
+
+	s, exc := getSubject("find", args[0])
+	if exc != nil {
+		return exc
+	}
+
+	loc := re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return object.NullConst
+	}
+
+	return makeMatch(re, s, loc, &runeIndexer{s: s})
+}
+
+func vmRegexFindAll(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckMinArgs("findAll", 1, args...); ac != nil {
+		return ac
+	}
+
+	re, exc := getRegex(self)
+	if exc != nil {
+		return exc
+	}
+
+	s, exc := getSubject("findAll", args[0])
+	if exc != nil {
+		return exc
+	}
+
+	n, exc := getLimit("findAll", args, 1)
+	if exc != nil {
+		return exc
+	}
+
+	locs := re.FindAllStringSubmatchIndex(s, n)
+	idx := &runeIndexer{s: s}
+	matches := make([]object.Object, len(locs))
+	for i, loc := range locs {
+		matches[i] = makeMatch(re, s, loc, idx)
+	}
+
+	return &object.Array{Elements: matches}
+}
+
+func vmRegexReplace(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("replace", 2, args...); ac != nil {
+		return ac
+	}
+
+	re, exc := getRegex(self)
+	if exc != nil {
+		return exc
+	}
+
+	s, exc := getSubject("replace", args[0])
+	if exc != nil {
+		return exc
+	}
+
+	switch repl := args[1].(type) {
+	case *object.String:
+		return object.MakeStringObj(re.ReplaceAllString(s, repl.String()))
+	case *vm.VMFunction, *vm.BoundMethod, *object.Builtin:
+		return replaceFunc(interpreter, re, s, repl)
+	}
+
+	return object.NewException("replace expected a string or function, got %s", args[1].Type().String())
+}
+
+// replaceFunc replaces each match with the result of calling fn with the match map.
+func replaceFunc(machine *vm.VirtualMachine, re *regexp.Regexp, s string, fn object.Object) object.Object {
+	locs := re.FindAllStringSubmatchIndex(s, -1)
+	if locs == nil {
+		return object.MakeStringObj(s)
+	}
+
+	idx := &runeIndexer{s: s}
+	out := make([]byte, 0, len(s))
+	last := 0
+
+	for _, loc := range locs {
+		ret := machine.Call(fn, makeMatch(re, s, loc, idx))
+		if object.ObjectIs(ret, object.ExceptionObj) {
+			return ret
+		}
+
+		str, ok := ret.(*object.String)
+		if !ok {
+			return object.NewException("replace function must return a string, got %s", ret.Type().String())
+		}
+
+		out = append(out, s[last:loc[0]]...)
+		out = append(out, str.String()...)
+		last = loc[1]
+	}
+	out = append(out, s[last:]...)
+
+	return object.MakeStringObj(string(out))
+}
+
+func vmRegexSplit(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckMinArgs("split", 1, args...); ac != nil {
+		return ac
+	}
+
+	re, exc := getRegex(self)
+	if exc != nil {
+		return exc
+	}
+
+	s, exc := getSubject("split", args[0])
+	if exc != nil {
+		return exc
+	}
+
+	n, exc := getLimit("split", args, 1)
+	if exc != nil {
+		return exc
+	}
+
+	return object.MakeStringArray(re.Split(s, n))
+}
+
+func vmRegexGroupNames(interpreter *vm.VirtualMachine, self *vm.VMInstance, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("groupNames", 0, args...); ac != nil {
+		return ac
+	}
+
+	re, exc := getRegex(self)
+	if exc != nil {
+		return exc
+	}
+
+	names := make([]string, 0, re.NumSubexp())
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return object.MakeStringArray(names)
+}
+
+// makeMatch converts a submatch index slice into a match map. Positions are
+// character offsets to be consistent with string indexing.
+func makeMatch(re *regexp.Regexp, s string, loc []int, idx *runeIndexer) *object.Hash {
+	names := re.SubexpNames()
+	groups := make([]object.Object, len(loc)/2)
+	named := object.MakeEmptyHash()
+
+	for i := range groups {
+		var group object.Object = object.NullConst
+		if loc[2*i] >= 0 {
+			group = object.MakeStringObj(s[loc[2*i]:loc[2*i+1]])
+		}
+		groups[i] = group
+
+		if names[i] != "" {
+			named.SetKey(names[i], group)
+		}
+	}
+
+	m := object.MakeEmptyHash()
+	m.SetKey("text", groups[0])
+	m.SetKey("start", object.MakeIntObj(int64(idx.index(loc[0]))))
+	m.SetKey("end", object.MakeIntObj(int64(idx.index(loc[1]))))
+	m.SetKey("groups", &object.Array{Elements: groups})
+	m.SetKey("named", named)
+	return m
+}
+
+// runeIndexer converts byte offsets to character offsets. Offsets are expected to be
+// mostly increasing so counting can continue from the last offset.
+type runeIndexer struct {
+	s        string
+	lastByte int
+	lastRune int
+}
+
+func (r *runeIndexer) index(b int) int {
+	if b < r.lastByte {
+		r.lastByte, r.lastRune = 0, 0
+	}
+	r.lastRune += utf8.RuneCountInString(r.s[r.lastByte:b])
+	r.lastByte = b
+	return r.lastRune
+}
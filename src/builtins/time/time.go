@@ -2,16 +2,98 @@ package time
 
 import (
 	"time"
+	_ "time/tzdata" // Time zones work even if the system doesn't have a tz database
 
 	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
 	"github.com/nitrogen-lang/nitrogen/src/elemental/vm"
 	"github.com/nitrogen-lang/nitrogen/src/moduleutils"
 )
 
+const (
+	timeResourceID = "std.time"
+)
+
+// start is used as the base of the monotonic clock
+var start = time.Now()
+
 func init() {
 	vm.RegisterNative("std.time.now", timeNowS)
 	vm.RegisterNative("std.time.now_ms", timeNowMs)
 	vm.RegisterNative("std.time.now_ns", timeNowNs)
+	vm.RegisterNative("std.time.monotonic", timeMonotonic)
+	vm.RegisterNative("std.time.sleepNs", timeSleep)
+
+	vm.RegisterNative("std.time.timeCurrent", timeCurrent)
+	vm.RegisterNative("std.time.timeUnix", timeUnix)
+	vm.RegisterNative("std.time.timeDate", timeDate)
+	vm.RegisterNative("std.time.timeParse", timeParse)
+	vm.RegisterNative("std.time.timeParseISO", timeParseISO)
+	vm.RegisterNative("std.time.timeField", timeField)
+	vm.RegisterNative("std.time.timeFormat", timeFormat)
+	vm.RegisterNative("std.time.timeAdd", timeAdd)
+	vm.RegisterNative("std.time.timeAddDate", timeAddDate)
+	vm.RegisterNative("std.time.timeSub", timeSub)
+	vm.RegisterNative("std.time.timeCompare", timeCompare)
+	vm.RegisterNative("std.time.timeTruncate", timeTruncate)
+	vm.RegisterNative("std.time.timeRound", timeRound)
+	vm.RegisterNative("std.time.timeIn", timeIn)
+	vm.RegisterNative("std.time.timeSince", timeSince)
+
+	vm.RegisterNative("std.time.durationParse", durationParse)
+	vm.RegisterNative("std.time.durationFormat", durationFormat)
+	vm.RegisterNative("std.time.durationTruncate", durationTruncate)
+	vm.RegisterNative("std.time.durationRound", durationRound)
+	vm.RegisterNative("std.time.toNs", toNs)
+}
+
+type timeResource struct {
+	t time.Time
+}
+
+func (t *timeResource) Inspect() string         { return "Time resource" }
+func (t *timeResource) Type() object.ObjectType { return object.ResourceObj }
+func (t *timeResource) Dup() object.Object      { return t } // Times are immutable
+func (t *timeResource) ResourceID() string      { return timeResourceID }
+
+func getTime(name string, arg object.Object) (time.Time, *object.Exception) {
+	res, ok := arg.(*timeResource)
+	if !ok {
+		return time.Time{}, object.NewException("%s expected a Time, got %s", name, arg.Type().String())
+	}
+	return res.t, nil
+}
+
+func getInt(name string, arg object.Object) (int64, *object.Exception) {
+	i, ok := arg.(*object.Integer)
+	if !ok {
+		return 0, object.NewException("%s expected an int, got %s", name, arg.Type().String())
+	}
+	return i.Value, nil
+}
+
+func getString(name string, arg object.Object) (string, *object.Exception) {
+	s, ok := arg.(*object.String)
+	if !ok {
+		return "", object.NewException("%s expected a string, got %s", name, arg.Type().String())
+	}
+	return s.String(), nil
+}
+
+func getLocation(name string, arg object.Object) (*time.Location, *object.Exception) {
+	zone, exc := getString(name, arg)
+	if exc != nil {
+		return nil, exc
+	}
+
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, object.NewException("Unknown time zone %s", zone)
+	}
+	return loc, nil
+}
+
+func makeTime(t time.Time) object.Object {
+	return &timeResource{t: t}
 }
 
 func timeNowS(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
@@ -37,3 +119,412 @@ func timeNowNs(interpreter object.Interpreter, env *object.Environment, args ...
 
 	return object.MakeIntObj(time.Now().UnixNano())
 }
+
+func timeMonotonic(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("monotonic", 0, args...); ac != nil {
+		return ac
+	}
+
+	return object.MakeIntObj(int64(time.Since(start)))
+}
+
+func timeSleep(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("sleep", 1, args...); ac != nil {
+		return ac
+	}
+
+	ns, exc := getInt("sleep", args[0])
+	if exc != nil {
+		return exc
+	}
+
+	time.Sleep(time.Duration(ns))
+	return object.NullConst
+}
+
+func timeCurrent(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("current", 0, args...); ac != nil {
+		return ac
+	}
+
+	return makeTime(time.Now())
+}
+
+func timeUnix(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("unix", 2, args...); ac != nil {
+		return ac
+	}
+
+	sec, exc := getInt("unix", args[0])
+	if exc != nil {
+		return exc
+	}
+	nsec, exc := getInt("unix", args[1])
+	if exc != nil {
+		return exc
+	}
+
+	return makeTime(time.Unix(sec, nsec))
+}
+
+func timeDate(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("date", 8, args...); ac != nil {
+		return ac
+	}
+
+	var parts [7]int
+	for i := range parts {
+		v, exc := getInt("date", args[i])
+		if exc != nil {
+			return exc
+		}
+		parts[i] = int(v)
+	}
+
+	loc, exc := getLocation("date", args[7])
+	if exc != nil {
+		return exc
+	}
+
+	return makeTime(time.Date(parts[0], time.Month(parts[1]), parts[2], parts[3], parts[4], parts[5], parts[6], loc))
+}
+
+func timeParse(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("parse", 3, args...); ac != nil {
+		return ac
+	}
+
+	layout, exc := getString("parse", args[0])
+	if exc != nil {
+		return exc
+	}
+	value, exc := getString("parse", args[1])
+	if exc != nil {
+		return exc
+	}
+	loc, exc := getLocation("parse", args[2])
+	if exc != nil {
+		return exc
+	}
+
+	t, err := time.ParseInLocation(layout, value, loc)
+	if err != nil {
+		return object.NewException("%s", err.Error())
+	}
+	return makeTime(t)
+}
+
+// isoLayouts are tried in order by parseISO. Times without an offset are UTC.
+var isoLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+func timeParseISO(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("parseISO", 1, args...); ac != nil {
+		return ac
+	}
+
+	value, exc := getString("parseISO", args[0])
+	if exc != nil {
+		return exc
+	}
+
+	for _, layout := range isoLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return makeTime(t)
+		}
+	}
+	return object.NewException("parseISO: %q is not an ISO-8601 time", value)
+}
+
+func timeField(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("timeField", 2, args...); ac != nil {
+		return ac
+	}
+
+	t, exc := getTime("timeField", args[0])
+	if exc != nil {
+		return exc
+	}
+	field, exc := getString("timeField", args[1])
+	if exc != nil {
+		return exc
+	}
+
+	var v int64
+	switch field {
+	case "year":
+		v = int64(t.Year())
+	case "month":
+		v = int64(t.Month())
+	case "day":
+		v = int64(t.Day())
+	case "hour":
+		v = int64(t.Hour())
+	case "minute":
+		v = int64(t.Minute())
+	case "second":
+		v = int64(t.Second())
+	case "nanosecond":
+		v = int64(t.Nanosecond())
+	case "weekday":
+		v = int64(t.Weekday())
+	case "yearDay":
+		v = int64(t.YearDay())
+	case "unix":
+		v = t.Unix()
+	case "unixMilli":
+		v = t.UnixMilli()
+	case "unixNano":
+		v = t.UnixNano()
+	case "offset":
+		_, offset := t.Zone()
+		v = int64(offset)
+	case "zone":
+		return object.MakeStringObj(t.Location().String())
+	default:
+		return object.NewException("Unknown time field %s", field)
+	}
+	return object.MakeIntObj(v)
+}
+
+func timeFormat(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("format", 2, args...); ac != nil {
+		return ac
+	}
+
+	t, exc := getTime("format", args[0])
+	if exc != nil {
+		return exc
+	}
+	layout, exc := getString("format", args[1])
+	if exc != nil {
+		return exc
+	}
+
+	return object.MakeStringObj(t.Format(layout))
+}
+
+func timeAdd(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("add", 2, args...); ac != nil {
+		return ac
+	}
+
+	t, exc := getTime("add", args[0])
+	if exc != nil {
+		return exc
+	}
+	ns, exc := getInt("add", args[1])
+	if exc != nil {
+		return exc
+	}
+
+	return makeTime(t.Add(time.Duration(ns)))
+}
+
+func timeAddDate(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("addDate", 4, args...); ac != nil {
+		return ac
+	}
+
+	t, exc := getTime("addDate", args[0])
+	if exc != nil {
+		return exc
+	}
+
+	var parts [3]int
+	for i := range parts {
+		v, exc := getInt("addDate", args[i+1])
+		if exc != nil {
+			return exc
+		}
+		parts[i] = int(v)
+	}
+
+	return makeTime(t.AddDate(parts[0], parts[1], parts[2]))
+}
+
+func timeSub(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("sub", 2, args...); ac != nil {
+		return ac
+	}
+
+	t, exc := getTime("sub", args[0])
+	if exc != nil {
+		return exc
+	}
+	u, exc := getTime("sub", args[1])
+	if exc != nil {
+		return exc
+	}
+
+	return object.MakeIntObj(int64(t.Sub(u)))
+}
+
+func timeCompare(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("compare", 2, args...); ac != nil {
+		return ac
+	}
+
+	t, exc := getTime("compare", args[0])
+	if exc != nil {
+		return exc
+	}
+	u, exc := getTime("compare", args[1])
+	if exc != nil {
+		return exc
+	}
+
+	return object.MakeIntObj(int64(t.Compare(u)))
+}
+
+func timeTruncate(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("truncate", 2, args...); ac != nil {
+		return ac
+	}
+
+	t, exc := getTime("truncate", args[0])
+	if exc != nil {
+		return exc
+	}
+	ns, exc := getInt("truncate", args[1])
+	if exc != nil {
+		return exc
+	}
+
+	return makeTime(t.Truncate(time.Duration(ns)))
+}
+
+func timeRound(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("round", 2, args...); ac != nil {
+		return ac
+	}
+
+	t, exc := getTime("round", args[0])
+	if exc != nil {
+		return exc
+	}
+	ns, exc := getInt("round", args[1])
+	if exc != nil {
+		return exc
+	}
+
+	return makeTime(t.Round(time.Duration(ns)))
+}
+
+func timeIn(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("in", 2, args...); ac != nil {
+		return ac
+	}
+
+	t, exc := getTime("in", args[0])
+	if exc != nil {
+		return exc
+	}
+	loc, exc := getLocation("in", args[1])
+	if exc != nil {
+		return exc
+	}
+
+	return makeTime(t.In(loc))
+}
+
+// timeSince uses the monotonic clock reading if the time has one, ex. it was created by current().
+func timeSince(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("since", 1, args...); ac != nil {
+		return ac
+	}
+
+	t, exc := getTime("since", args[0])
+	if exc != nil {
+		return exc
+	}
+
+	return object.MakeIntObj(int64(time.Since(t)))
+}
+
+func durationParse(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("parseDuration", 1, args...); ac != nil {
+		return ac
+	}
+
+	s, exc := getString("parseDuration", args[0])
+	if exc != nil {
+		return exc
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return object.NewException("%s", err.Error())
+	}
+	return object.MakeIntObj(int64(d))
+}
+
+func durationFormat(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("toString", 1, args...); ac != nil {
+		return ac
+	}
+
+	ns, exc := getInt("toString", args[0])
+	if exc != nil {
+		return exc
+	}
+
+	return object.MakeStringObj(time.Duration(ns).String())
+}
+
+func durationTruncate(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("truncate", 2, args...); ac != nil {
+		return ac
+	}
+
+	ns, exc := getInt("truncate", args[0])
+	if exc != nil {
+		return exc
+	}
+	m, exc := getInt("truncate", args[1])
+	if exc != nil {
+		return exc
+	}
+
+	return object.MakeIntObj(int64(time.Duration(ns).Truncate(time.Duration(m))))
+}
+
+func durationRound(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("round", 2, args...); ac != nil {
+		return ac
+	}
+
+	ns, exc := getInt("round", args[0])
+	if exc != nil {
+		return exc
+	}
+	m, exc := getInt("round", args[1])
+	if exc != nil {
+		return exc
+	}
+
+	return object.MakeIntObj(int64(time.Duration(ns).Round(time.Duration(m))))
+}
+
+func toNs(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+	if ac := moduleutils.CheckArgs("Duration", 1, args...); ac != nil {
+		return ac
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		return arg
+	case *vm.VMInstance:
+		for class := arg.Class; class != nil; class = class.Parent {
+			if class.Name == "std.time.Duration" {
+				ns, _ := arg.Fields.Get("ns")
+				return ns
+			}
+		}
+	}
+	return object.NewException("Duration expected a Duration or int, got %s", args[0].Type().String())
+}
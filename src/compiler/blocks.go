@@ -25,7 +25,7 @@ func compileClassLiteral(ccb *compile.CodeBlockCompiler, class *ast.ClassLiteral
 		Code:      compile.NewInstSet(),
 		Filename:  ccb.Filename,
 		Name:      ccb.Name,
-		InLoop:    ccb.InLoop,
+		Loops:     ccb.Loops,
 		Linenum:   ccb.Linenum,
 	}
 
@@ -38,17 +38,16 @@ func compileClassLiteral(ccb *compile.CodeBlockCompiler, class *ast.ClassLiteral
 	code := ccb2.Code
 	assembledCode, lineOffsets := code.Assemble(ccb2)
 	props := &compile.CodeBlock{
-		Name:         fmt.Sprintf("%s.__init", class.Name),
-		Filename:     ccb.Filename,
-		LocalCount:   len(ccb2.Locals.Table),
-		Code:         assembledCode,
-		Constants:    ccb2.Constants.Table,
-		Names:        ccb2.Names.Table,
-		Locals:       ccb2.Locals.Table,
-		MaxStackSize: calculateStackSize(code),
-		MaxBlockSize: calculateBlockSize(code),
-		LineOffsets:  lineOffsets,
+		Name:        fmt.Sprintf("%s.__init", class.Name),
+		Filename:    ccb.Filename,
+		LocalCount:  len(ccb2.Locals.Table),
+		Code:        assembledCode,
+		Constants:   ccb2.Constants.Table,
+		Names:       ccb2.Names.Table,
+		Locals:      ccb2.Locals.Table,
+		LineOffsets: lineOffsets,
 	}
+	setMaxSizes(props, code)
 
 	ccb.Linenum = ccb2.Linenum
 	ccb.Code.AddInst(opcode.LoadConst, ccb.Linenum, ccb.Constants.IndexOf(props))
@@ -94,13 +93,16 @@ func compileFunction(ccb *compile.CodeBlockCompiler, fn *ast.FunctionLiteral, in
 			Code:      compile.NewInstSet(),
 			Filename:  ccb.Filename,
 			Name:      ccb.Name,
-			InLoop:    ccb.InLoop,
+			Loops:     ccb.Loops,
 			Linenum:   ccb.Linenum,
 		}
 
 		for _, p := range fn.Parameters {
 			ccb2.Locals.IndexOf(p.Value)
 		}
+		if fn.Rest != nil {
+			ccb2.Locals.IndexOf(fn.Rest.Value)
+		}
 		ccb2.Locals.IndexOf("arguments") // `arguments` holds any remaining arguments from a function call
 		if inClass {
 			ccb2.Locals.IndexOf("this")
@@ -130,19 +132,19 @@ func compileFunction(ccb *compile.CodeBlockCompiler, fn *ast.FunctionLiteral, in
 		}
 
 		code := ccb2.Code
+		markTailCalls(code)
 		assembledCode, lineOffsets := code.Assemble(ccb2)
 		body = &compile.CodeBlock{
-			Name:         ccb.Name + "." + fn.FQName,
-			Filename:     ccb.Filename,
-			LocalCount:   len(ccb2.Locals.Table),
-			Code:         assembledCode,
-			Constants:    ccb2.Constants.Table,
-			Names:        ccb2.Names.Table,
-			Locals:       ccb2.Locals.Table,
-			MaxStackSize: calculateStackSize(code),
-			MaxBlockSize: calculateBlockSize(code),
-			LineOffsets:  lineOffsets,
+			Name:        ccb.Name + "." + fn.FQName,
+			Filename:    ccb.Filename,
+			LocalCount:  len(ccb2.Locals.Table),
+			Code:        assembledCode,
+			Constants:   ccb2.Constants.Table,
+			Names:       ccb2.Names.Table,
+			Locals:      ccb2.Locals.Table,
+			LineOffsets: lineOffsets,
 		}
+		setMaxSizes(body, code)
 		ccb.Linenum = ccb2.Linenum
 	}
 
@@ -150,10 +152,21 @@ func compileFunction(ccb *compile.CodeBlockCompiler, fn *ast.FunctionLiteral, in
 
 	ccb.Code.AddInst(opcode.LoadConst, ccb.Linenum, ccb.Constants.IndexOf(body))
 
-	for _, p := range fn.Parameters {
+	// Parameters are an array of names. A parameter with a default value is a
+	// [name, value] pair, the rest parameter is prefixed with "...".
+	for i, p := range fn.Parameters {
 		ccb.Code.AddInst(opcode.LoadConst, ccb.Linenum, ccb.Constants.IndexOf(object.MakeStringObj(p.Value)))
+		if fn.Defaults != nil && fn.Defaults[i] != nil {
+			compileMain(ccb, fn.Defaults[i])
+			ccb.Code.AddInst(opcode.MakeArray, ccb.Linenum, 2)
+		}
+	}
+	params := len(fn.Parameters)
+	if fn.Rest != nil {
+		ccb.Code.AddInst(opcode.LoadConst, ccb.Linenum, ccb.Constants.IndexOf(object.MakeStringObj("..."+fn.Rest.Value)))
+		params++
 	}
-	ccb.Code.AddInst(opcode.MakeArray, ccb.Linenum, uint16(len(fn.Parameters)))
+	ccb.Code.AddInst(opcode.MakeArray, ccb.Linenum, uint16(params))
 
 	ccb.Code.AddInst(opcode.LoadConst, ccb.Linenum, ccb.Constants.IndexOf(object.MakeStringObj(fn.Name)))
 
@@ -184,10 +197,10 @@ func compileIfStatement(ccb *compile.CodeBlockCompiler, ifs *ast.IfExpression) {
 	ccb.Code.AddLabeledArgs(opcode.JumpAbsolute, ccb.Linenum, afterIfStmt)
 	ccb.Code.AddLabel(falseBrnLbl, ccb.Linenum)
 	compileMain(ccb, ifs.Alternative)
-	ccb.Code.AddLabel(afterIfStmt, ccb.Linenum)
 	if !falseNoNil {
 		compileLoadNull(ccb)
 	}
+	ccb.Code.AddLabel(afterIfStmt, ccb.Linenum)
 }
 
 func compileIfStatementNoElse(ccb *compile.CodeBlockCompiler, ifs *ast.IfExpression) {
@@ -220,9 +233,12 @@ func compileCompareExpression(ccb *compile.CodeBlockCompiler, cmp *ast.CompareEx
 
 	afterCompareLabel := randomLabel("cmp_")
 
-	if cmp.Token.Type == token.LAnd {
+	switch cmp.Token.Type {
+	case token.LAnd:
 		ccb.Code.AddLabeledArgs(opcode.JumpIfFalseOrPop, ccb.Linenum, afterCompareLabel)
-	} else {
+	case token.NilCoalesce:
+		ccb.Code.AddLabeledArgs(opcode.JumpNotNilOrPop, ccb.Linenum, afterCompareLabel)
+	default:
 		ccb.Code.AddLabeledArgs(opcode.JumpIfTrueOrPop, ccb.Linenum, afterCompareLabel)
 	}
 
@@ -271,7 +287,7 @@ func compileLoop(ccb *compile.CodeBlockCompiler, loop *ast.LoopStatement) {
 		Code:      compile.NewInstSet(),
 		Filename:  ccb.Filename,
 		Name:      ccb.Name,
-		InLoop:    true,
+		Loops:     enterLoop(ccb, loop.Label),
 		Linenum:   ccb.Linenum,
 	}
 
@@ -285,9 +301,7 @@ func compileLoop(ccb *compile.CodeBlockCompiler, loop *ast.LoopStatement) {
 	}
 
 	// This copies the local variables into the outer compile block for table indexing
-	for _, n := range bodyCCB.Locals.Table[len(ccb.Locals.Table):] {
-		ccb.Locals.IndexOf(n)
-	}
+	mergeLocals(ccb, bodyCCB.Locals)
 
 	// Prepare for iteration code
 	iterCCB := &compile.CodeBlockCompiler{
@@ -303,11 +317,12 @@ func compileLoop(ccb *compile.CodeBlockCompiler, loop *ast.LoopStatement) {
 	// Compile iteration
 	compileMain(iterCCB, loop.Iter)
 	ccb.Linenum = iterCCB.Linenum
+	if _, ok := loop.Iter.(ast.Expression); ok {
+		iterCCB.Code.AddInst(opcode.Pop, ccb.Linenum)
+	}
 
 	// Again, copy over the locals for indexing
-	for _, n := range iterCCB.Locals.Table[len(ccb.Locals.Table):] {
-		ccb.Locals.IndexOf(n)
-	}
+	mergeLocals(ccb, iterCCB.Locals)
 
 	ccb.Code.AddLabeledArgs(opcode.StartLoop, ccb.Linenum, endBlockLbl, iterBlockLbl)
 
@@ -336,7 +351,7 @@ func compileInfiniteLoop(ccb *compile.CodeBlockCompiler, loop *ast.LoopStatement
 		Code:      compile.NewInstSet(),
 		Filename:  ccb.Filename,
 		Name:      ccb.Name,
-		InLoop:    true,
+		Loops:     enterLoop(ccb, loop.Label),
 		Linenum:   ccb.Linenum,
 	}
 	compileMain(bodyCCB, loop.Body)
@@ -348,9 +363,7 @@ func compileInfiniteLoop(ccb *compile.CodeBlockCompiler, loop *ast.LoopStatement
 	}
 
 	// This copies the local variables into the outer compile block for table indexing
-	for _, n := range bodyCCB.Locals.Table[len(ccb.Locals.Table):] {
-		ccb.Locals.IndexOf(n)
-	}
+	mergeLocals(ccb, bodyCCB.Locals)
 	ccb.Code.Merge(bodyCCB.Code)
 
 	ccb.Code.AddLabel(iterBlockLbl, ccb.Linenum)
@@ -385,7 +398,7 @@ func compileWhileLoop(ccb *compile.CodeBlockCompiler, loop *ast.LoopStatement) {
 		Code:      compile.NewInstSet(),
 		Filename:  ccb.Filename,
 		Name:      ccb.Name,
-		InLoop:    true,
+		Loops:     enterLoop(ccb, loop.Label),
 		Linenum:   ccb.Linenum,
 	}
 
@@ -399,9 +412,7 @@ func compileWhileLoop(ccb *compile.CodeBlockCompiler, loop *ast.LoopStatement) {
 	}
 
 	// This copies the local variables into the outer compile block for table indexing
-	for _, n := range bodyCCB.Locals.Table[len(ccb.Locals.Table):] {
-		ccb.Locals.IndexOf(n)
-	}
+	mergeLocals(ccb, bodyCCB.Locals)
 
 	ccb.Code.AddLabeledArgs(opcode.StartLoop, ccb.Linenum, endBlockLbl, iterBlockLbl)
 
@@ -415,13 +426,60 @@ func compileWhileLoop(ccb *compile.CodeBlockCompiler, loop *ast.LoopStatement) {
 	ccb.Code.AddInst(opcode.EndBlock, ccb.Linenum)
 }
 
+// enterLoop returns the loops enclosing the body of a loop with label.
+func enterLoop(ccb *compile.CodeBlockCompiler, label *ast.Identifier) []string {
+	name := ""
+	if label != nil {
+		name = label.Value
+		if loopDepth(ccb.Loops, name) >= 0 {
+			panic(fmt.Sprintf("loop label %s is already in use", name))
+		}
+	}
+
+	loops := make([]string, len(ccb.Loops), len(ccb.Loops)+1)
+	copy(loops, ccb.Loops)
+	return append(loops, name)
+}
+
+// loopDepth returns how many loops are inside the loop with label, 0 is the
+// innermost loop. It returns -1 if there's no loop with that label.
+func loopDepth(loops []string, label string) int {
+	for i := len(loops) - 1; i >= 0; i-- {
+		if loops[i] == label {
+			return len(loops) - 1 - i
+		}
+	}
+	return -1
+}
+
 func compileIterLoop(ccb *compile.CodeBlockCompiler, loop *ast.IterLoopStatement) {
 	ccb.Linenum = loop.Token.Pos.Line
+	compileMain(ccb, loop.Iter)
+
+	compileIter(ccb, loop.Key, loop.Value, enterLoop(ccb, loop.Label), func(bodyCCB *compile.CodeBlockCompiler, nextLbl string) {
+		compileMain(bodyCCB, loop.Body)
+
+		// If the body ends in an expression, we need to pop it so the stack is correct
+		if _, ok := loop.Body.Statements[len(loop.Body.Statements)-1].(*ast.ExpressionStatement); ok {
+			bodyCCB.Code.AddInst(opcode.Pop, bodyCCB.Linenum)
+		}
+	})
+}
+
+// compileIter compiles a loop over the iterable on top of the stack. Each item
+// is bound to key and value, then body compiles the rest of the loop in its own
+// block. body can jump to nextLbl to skip to the next item. loops are the loops
+// break and continue in the body can refer to.
+func compileIter(
+	ccb *compile.CodeBlockCompiler,
+	key, value ast.Pattern,
+	loops []string,
+	body func(bodyCCB *compile.CodeBlockCompiler, nextLbl string)) {
+
 	endBlockLbl := randomLabel("end_")
 	iterBlockLbl := randomLabel("iter_")
-	endIterLbl := randomLabel("end_iter_")
+	nextItemLbl := randomLabel("next_item_")
 
-	compileMain(ccb, loop.Iter)
 	ccb.Code.AddInst(opcode.GetIter, ccb.Linenum)
 
 	ccb.Code.AddLabeledArgs(opcode.StartLoop, ccb.Linenum, endBlockLbl, iterBlockLbl)
@@ -432,27 +490,33 @@ func compileIterLoop(ccb *compile.CodeBlockCompiler, loop *ast.IterLoopStatement
 	ccb.Code.AddInst(opcode.Dup, ccb.Linenum)
 	ccb.Code.AddInst(opcode.LoadConst, ccb.Linenum, ccb.Constants.IndexOf(object.NullConst))
 	ccb.Code.AddInst(opcode.Compare, ccb.Linenum, uint16(opcode.CmpEq))
-	ccb.Code.AddLabeledArgs(opcode.PopJumpIfTrue, ccb.Linenum, endIterLbl)
-	ccb.Code.AddInst(opcode.JumpForward, ccb.Linenum, 4)
+	ccb.Code.AddLabeledArgs(opcode.PopJumpIfFalse, ccb.Linenum, nextItemLbl)
 
-	ccb.Code.AddLabel(endIterLbl, ccb.Linenum)
 	ccb.Code.AddInst(opcode.Pop, ccb.Linenum) // Duplicated return from _next()
 	ccb.Code.AddLabeledArgs(opcode.JumpAbsolute, ccb.Linenum, endBlockLbl)
 
+	ccb.Code.AddLabel(nextItemLbl, ccb.Linenum)
+
 	bodyStrTable := compile.NewStringTableOffset(len(ccb.Locals.Table))
 
-	if loop.Key != nil {
+	define := func(name *ast.Identifier) {
+		ccb.Code.AddInst(opcode.Define, ccb.Linenum, bodyStrTable.IndexOf(name.Value), 0)
+	}
+
+	if _, wildcard := key.(*ast.WildcardPattern); key != nil && !wildcard {
 		ccb.Code.AddInst(opcode.Dup, ccb.Linenum)
 		ccb.Code.AddInst(opcode.LoadConst, ccb.Linenum, ccb.Constants.IndexOf(object.MakeIntObj(0)))
 		ccb.Code.AddInst(opcode.LoadIndex, ccb.Linenum)
-		ccb.Code.AddInst(opcode.Define, ccb.Linenum, ccb.Locals.IndexOf(loop.Key.Value), 0)
-		bodyStrTable.IndexOf(loop.Key.Value)
+		compileDestructure(ccb, key, define)
 	}
 
-	ccb.Code.AddInst(opcode.LoadConst, ccb.Linenum, ccb.Constants.IndexOf(object.MakeIntObj(1)))
-	ccb.Code.AddInst(opcode.LoadIndex, ccb.Linenum)
-	ccb.Code.AddInst(opcode.Define, ccb.Linenum, ccb.Locals.IndexOf(loop.Value.Value), 0)
-	bodyStrTable.IndexOf(loop.Value.Value)
+	if _, wildcard := value.(*ast.WildcardPattern); wildcard {
+		ccb.Code.AddInst(opcode.Pop, ccb.Linenum)
+	} else {
+		ccb.Code.AddInst(opcode.LoadConst, ccb.Linenum, ccb.Constants.IndexOf(object.MakeIntObj(1)))
+		ccb.Code.AddInst(opcode.LoadIndex, ccb.Linenum)
+		compileDestructure(ccb, value, define)
+	}
 
 	bodyCCB := &compile.CodeBlockCompiler{
 		Constants: ccb.Constants,
@@ -461,21 +525,14 @@ func compileIterLoop(ccb *compile.CodeBlockCompiler, loop *ast.IterLoopStatement
 		Code:      compile.NewInstSet(),
 		Filename:  ccb.Filename,
 		Name:      ccb.Name,
-		InLoop:    true,
+		Loops:     loops,
 		Linenum:   ccb.Linenum,
 	}
-	compileMain(bodyCCB, loop.Body)
+	body(bodyCCB, iterBlockLbl)
 	ccb.Linenum = bodyCCB.Linenum
 
-	// If the body ends in an expression, we need to pop it so the stack is correct
-	if _, ok := loop.Body.Statements[len(loop.Body.Statements)-1].(*ast.ExpressionStatement); ok {
-		bodyCCB.Code.AddInst(opcode.Pop, ccb.Linenum)
-	}
-
 	// This copies the local variables into the outer compile block for table indexing
-	for _, n := range bodyCCB.Locals.Table[len(ccb.Locals.Table):] {
-		ccb.Locals.IndexOf(n)
-	}
+	mergeLocals(ccb, bodyCCB.Locals)
 	ccb.Code.Merge(bodyCCB.Code)
 
 	ccb.Code.AddLabel(iterBlockLbl, ccb.Linenum)
@@ -502,16 +559,14 @@ func compileDoBlock(ccb *compile.CodeBlockCompiler, node *ast.DoExpression) {
 		Code:      compile.NewInstSet(),
 		Filename:  ccb.Filename,
 		Name:      ccb.Name,
-		InLoop:    ccb.InLoop,
+		Loops:     ccb.Loops,
 		Linenum:   ccb.Linenum,
 	}
 	compileMain(bodyCCB, node.Statements)
 	ccb.Linenum = bodyCCB.Linenum
 
 	// This copies the local variables into the outer compile block for table indexing
-	for _, n := range bodyCCB.Locals.Table[len(ccb.Locals.Table):] {
-		ccb.Locals.IndexOf(n)
-	}
+	mergeLocals(ccb, bodyCCB.Locals)
 	ccb.Code.Merge(bodyCCB.Code)
 
 	ccb.Code.AddLabel(endBlockLabel, ccb.Linenum)
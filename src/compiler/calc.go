@@ -1,20 +1,26 @@
 package compiler
 
 import (
+	"strings"
+
 	"github.com/nitrogen-lang/nitrogen/src/elemental/compile"
 	"github.com/nitrogen-lang/nitrogen/src/elemental/vm/opcode"
 )
 
+// setMaxSizes sets the stack and block sizes of c by following every path through
+// its code. The linear estimates from code are only used if the paths can't be followed.
+func setMaxSizes(c *compile.CodeBlock, code *compile.InstSet) {
+	stack, block, err := compile.MaxDepths(c)
+	if err != nil {
+		stack, block = calculateStackSize(code), calculateBlockSize(code)
+	}
+	c.MaxStackSize, c.MaxBlockSize = stack, block
+}
+
 type maxsizer struct {
 	max, current int
 }
 
-func (s *maxsizer) sub(delta int) {
-	s.current -= delta
-	if s.current > s.max { // Delta can be negative which would add to the size
-		s.max = s.current
-	}
-}
 func (s *maxsizer) add(delta int) {
 	s.current += delta
 	if s.current > s.max {
@@ -22,31 +28,34 @@ func (s *maxsizer) add(delta int) {
 	}
 }
 
+// calculateStackSize walks the instructions in order. Code after a label
+// starts with the largest stack size of the forward jumps to it, code only
+// reachable by a jump may need more space than the instructions before it leave.
 func calculateStackSize(c *compile.InstSet) int {
 	stackSize := &maxsizer{}
+	targets := make(map[string]int)
 
 	i := c.Head
 	for i != nil {
-		switch i.Instr {
-		case opcode.LoadConst, opcode.LoadFast, opcode.LoadGlobal, opcode.Import, opcode.Dup:
-			stackSize.add(1)
-		case opcode.StoreIndex:
-			stackSize.sub(3)
-		case opcode.BinaryAdd, opcode.BinarySub, opcode.BinaryMul, opcode.BinaryDivide, opcode.BinaryMod, opcode.BinaryShiftL,
-			opcode.BinaryShiftR, opcode.BinaryAnd, opcode.BinaryOr, opcode.BinaryNot, opcode.BinaryAndNot,
-			opcode.StoreFast, opcode.Define, opcode.StoreGlobal, opcode.LoadIndex, opcode.Compare,
-			opcode.Return, opcode.Pop, opcode.PopJumpIfTrue, opcode.PopJumpIfFalse, opcode.Implements:
-			stackSize.sub(1)
-		case opcode.Call:
-			stackSize.sub(int(i.Args[0]))
-		case opcode.MakeArray:
-			stackSize.sub(int(i.Args[0]) - 1)
-		case opcode.BuildClass:
-			stackSize.sub(int(i.Args[0]) + 2)
-		case opcode.MakeMap:
-			stackSize.sub(int(i.Args[0])*2 - 1)
-		case opcode.MakeFunction, opcode.StoreAttribute:
-			stackSize.sub(2)
+		if i.Instr == opcode.Label {
+			if size, ok := targets[i.Label]; ok && size > stackSize.current {
+				stackSize.current = size
+			}
+			i = i.Next
+			continue
+		}
+
+		var arg uint16
+		if len(i.Args) > 0 {
+			arg = i.Args[0]
+		}
+		stackSize.add(opcode.StackEffect(i.Instr, arg))
+
+		for _, lbl := range i.ArgLabels {
+			lbl = strings.TrimPrefix(lbl, "~")
+			if lbl != "" && stackSize.current > targets[lbl] {
+				targets[lbl] = stackSize.current
+			}
 		}
 		i = i.Next
 	}
@@ -59,12 +68,7 @@ func calculateBlockSize(c *compile.InstSet) int {
 
 	i := c.Head
 	for i != nil {
-		switch i.Instr {
-		case opcode.StartBlock, opcode.StartLoop, opcode.Recover:
-			blockLen.add(1)
-		case opcode.EndBlock:
-			blockLen.sub(1)
-		}
+		blockLen.add(opcode.BlockEffect(i.Instr))
 		i = i.Next
 	}
 
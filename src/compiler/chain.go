@@ -0,0 +1,129 @@
+package compiler
+
+import (
+	"github.com/nitrogen-lang/nitrogen/src/ast"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/compile"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm/opcode"
+)
+
+// optionalChain holds the labels optional links jump to when the value before
+// them is nil. labels[n] expects n values under the nil, such as the arguments
+// of a call that haven't been used yet.
+type optionalChain struct {
+	labels []string
+}
+
+func (c *optionalChain) label(depth int) string {
+	for len(c.labels) <= depth {
+		c.labels = append(c.labels, randomLabel("optional_"))
+	}
+	return c.labels[depth]
+}
+
+/*
+A chain of attribute, index, slice and call expressions with an optional link such as
+a?.b(c).d compiles to:
+
+	<c>
+	<a>
+	JUMP_IF_NIL optional_1  ; the argument c is under the nil
+	LOAD_ATTRIBUTE b
+	CALL 1
+	LOAD_ATTRIBUTE d
+	JUMP_ABSOLUTE end
+	optional_1:
+	POP
+	POP
+	LOAD_CONST nil
+	end:
+
+A link jumping with nothing under the nil goes straight to end.
+*/
+func compileChain(ccb *compile.CodeBlockCompiler, node ast.Expression) {
+	if !ast.HasOptionalLink(node) {
+		compileChainLink(ccb, node, nil, 0)
+		return
+	}
+
+	endLbl := randomLabel("chainEnd_")
+	chain := &optionalChain{labels: []string{endLbl}}
+	compileChainLink(ccb, node, chain, 0)
+
+	if len(chain.labels) == 1 {
+		ccb.Code.AddLabel(endLbl, ccb.Linenum)
+		return
+	}
+
+	ccb.Code.AddLabeledArgs(opcode.JumpAbsolute, ccb.Linenum, endLbl)
+	for depth := len(chain.labels) - 1; depth > 0; depth-- {
+		ccb.Code.AddLabel(chain.labels[depth], ccb.Linenum)
+		ccb.Code.AddInst(opcode.Pop, ccb.Linenum)
+	}
+	ccb.Code.AddInst(opcode.Pop, ccb.Linenum)
+	compileLoadNull(ccb)
+	ccb.Code.AddLabel(endLbl, ccb.Linenum)
+}
+
+// compileChainLink compiles one link of a chain. depth is the number of values
+// pushed by links further out in the chain that are still on the stack.
+func compileChainLink(ccb *compile.CodeBlockCompiler, node ast.Expression, chain *optionalChain, depth int) {
+	switch node := node.(type) {
+	case *ast.AttributeExpression:
+		ccb.Linenum = node.Token.Pos.Line
+		compileChainLink(ccb, node.Left, chain, depth)
+		if node.Optional {
+			ccb.Code.AddLabeledArgs(opcode.JumpIfNil, ccb.Linenum, chain.label(depth))
+		}
+		ccb.Code.AddInst(opcode.LoadAttribute, ccb.Linenum, ccb.Names.IndexOf(node.Index.String()))
+
+	case *ast.IndexExpression:
+		ccb.Linenum = node.Token.Pos.Line
+		compileChainLink(ccb, node.Left, chain, depth)
+		if node.Optional {
+			ccb.Code.AddLabeledArgs(opcode.JumpIfNil, ccb.Linenum, chain.label(depth))
+		}
+		compileMain(ccb, node.Index)
+		ccb.Code.AddInst(opcode.LoadIndex, ccb.Linenum)
+
+	case *ast.SliceExpression:
+		ccb.Linenum = node.Token.Pos.Line
+		compileChainLink(ccb, node.Left, chain, depth)
+		if node.Optional {
+			ccb.Code.AddLabeledArgs(opcode.JumpIfNil, ccb.Linenum, chain.label(depth))
+		}
+		compileSliceBounds(ccb, node)
+		ccb.Code.AddInst(opcode.LoadSlice, ccb.Linenum)
+
+	case *ast.CallExpression:
+		ccb.Linenum = node.Token.Pos.Line
+		for i := len(node.Arguments) - 1; i >= 0; i-- {
+			compileMain(ccb, node.Arguments[i])
+		}
+
+		depth += len(node.Arguments)
+		compileChainLink(ccb, node.Function, chain, depth)
+		if node.Optional {
+			ccb.Code.AddLabeledArgs(opcode.JumpIfNil, ccb.Linenum, chain.label(depth))
+		}
+
+		if compileArgumentNames(ccb, node.Arguments) {
+			ccb.Code.AddInst(opcode.CallKw, ccb.Linenum, uint16(len(node.Arguments)))
+		} else {
+			ccb.Code.AddInst(opcode.Call, ccb.Linenum, uint16(len(node.Arguments)))
+		}
+
+	default:
+		compileMain(ccb, node)
+	}
+}
+
+// compileSliceBounds pushes the start, stop and step of a slice, omitted bounds are nil.
+func compileSliceBounds(ccb *compile.CodeBlockCompiler, node *ast.SliceExpression) {
+	for _, bound := range []ast.Expression{node.Start, node.Stop, node.Step} {
+		if bound == nil {
+			compileLoadNull(ccb)
+		} else {
+			compileMain(ccb, bound)
+		}
+	}
+}
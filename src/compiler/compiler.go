@@ -32,17 +32,16 @@ func compileFrame(node ast.Node, name, filename string) *compile.CodeBlock {
 	code := ccb.Code
 	assembledCode, lineOffsets := code.Assemble(ccb)
 	c := &compile.CodeBlock{
-		Name:         name,
-		Filename:     filename,
-		LocalCount:   len(ccb.Locals.Table),
-		Code:         assembledCode,
-		Constants:    ccb.Constants.Table,
-		Names:        ccb.Names.Table,
-		Locals:       ccb.Locals.Table,
-		MaxStackSize: calculateStackSize(code),
-		MaxBlockSize: calculateBlockSize(code),
-		LineOffsets:  lineOffsets,
+		Name:        name,
+		Filename:    filename,
+		LocalCount:  len(ccb.Locals.Table),
+		Code:        assembledCode,
+		Constants:   ccb.Constants.Table,
+		Names:       ccb.Names.Table,
+		Locals:      ccb.Locals.Table,
+		LineOffsets: lineOffsets,
 	}
+	setMaxSizes(c, code)
 
 	return c
 }
@@ -84,6 +83,21 @@ func compileMain(ccb *compile.CodeBlockCompiler, node ast.Node) {
 		str := &object.ByteString{Value: node.Value}
 		ccb.Code.AddInst(opcode.LoadConst, ccb.Linenum, ccb.Constants.IndexOf(str))
 
+	case *ast.FormatString:
+		ccb.Linenum = node.Token.Pos.Line
+		for _, part := range node.Parts {
+			compileMain(ccb, part)
+		}
+		// A single part is already a string
+		if len(node.Parts) != 1 {
+			ccb.Code.AddInst(opcode.BuildString, ccb.Linenum, uint16(len(node.Parts)))
+		}
+
+	case *ast.FormattedValue:
+		compileMain(ccb, node.Value)
+		spec := &object.String{Value: []rune(node.Spec)}
+		ccb.Code.AddInst(opcode.FormatValue, ccb.Linenum, ccb.Constants.IndexOf(spec))
+
 	case *ast.FloatLiteral:
 		ccb.Linenum = node.Token.Pos.Line
 		float := &object.Float{Value: node.Value}
@@ -189,13 +203,17 @@ func compileMain(ccb *compile.CodeBlockCompiler, node ast.Node) {
 			ccb.Code.AddInst(opcode.Implements, ccb.Linenum)
 		}
 
-	case *ast.CallExpression:
-		ccb.Linenum = node.Token.Pos.Line
-		for i := len(node.Arguments) - 1; i >= 0; i-- {
-			compileMain(ccb, node.Arguments[i])
-		}
-		compileMain(ccb, node.Function)
-		ccb.Code.AddInst(opcode.Call, ccb.Linenum, uint16(len(node.Arguments)))
+	case *ast.CallExpression, *ast.IndexExpression, *ast.SliceExpression, *ast.AttributeExpression:
+		compileChain(ccb, node.(ast.Expression))
+
+	case *ast.ArrayComprehension:
+		compileArrayComprehension(ccb, node)
+
+	case *ast.MapComprehension:
+		compileMapComprehension(ccb, node)
+
+	case *ast.NamedArgument:
+		compileMain(ccb, node.Value)
 
 	case *ast.ReturnStatement:
 		ccb.Linenum = node.Token.Pos.Line
@@ -221,6 +239,13 @@ func compileMain(ccb *compile.CodeBlockCompiler, node ast.Node) {
 			break
 		}
 
+		if slice, ok := node.Left.(*ast.SliceExpression); ok {
+			compileSliceBounds(ccb, slice)
+			compileMain(ccb, slice.Left)
+			ccb.Code.AddInst(opcode.StoreSlice, ccb.Linenum)
+			break
+		}
+
 		if attrib, ok := node.Left.(*ast.AttributeExpression); ok {
 			compileMain(ccb, attrib.Left)
 			ccb.Code.AddInst(opcode.StoreAttribute, ccb.Linenum, ccb.Names.IndexOf(attrib.Index.String()))
@@ -231,12 +256,16 @@ func compileMain(ccb *compile.CodeBlockCompiler, node ast.Node) {
 		if !ok {
 			panic("Assignment to non ident or index")
 		}
+		compileStoreName(ccb, ident)
 
-		if ccb.Locals.Contains(ident.Value) {
-			ccb.Code.AddInst(opcode.StoreFast, ccb.Linenum, ccb.Locals.IndexOf(ident.Value))
-		} else {
-			ccb.Code.AddInst(opcode.StoreGlobal, ccb.Linenum, ccb.Names.IndexOf(ident.Value))
-		}
+	case *ast.DestructureStatement:
+		compileDestructureStatement(ccb, node)
+
+	case *ast.DeferStatement:
+		compileDeferStatement(ccb, node)
+
+	case *ast.WithStatement:
+		compileWithStatement(ccb, node)
 
 	case *ast.DeleteStatement:
 		ccb.Linenum = node.Token.Pos.Line
@@ -245,6 +274,9 @@ func compileMain(ccb *compile.CodeBlockCompiler, node ast.Node) {
 	case *ast.IfExpression:
 		compileIfStatement(ccb, node)
 
+	case *ast.MatchExpression:
+		compileMatchExpression(ccb, node)
+
 	case *ast.CompareExpression:
 		compileCompareExpression(ccb, node)
 
@@ -257,12 +289,6 @@ func compileMain(ccb *compile.CodeBlockCompiler, node ast.Node) {
 	case *ast.FunctionLiteral:
 		compileFunction(ccb, node, false, false)
 
-	case *ast.IndexExpression:
-		ccb.Linenum = node.Token.Pos.Line
-		compileMain(ccb, node.Left)
-		compileMain(ccb, node.Index)
-		ccb.Code.AddInst(opcode.LoadIndex, ccb.Linenum)
-
 	case *ast.LoopStatement:
 		compileLoop(ccb, node)
 
@@ -271,17 +297,11 @@ func compileMain(ccb *compile.CodeBlockCompiler, node ast.Node) {
 
 	case *ast.ContinueStatement:
 		ccb.Linenum = node.Token.Pos.Line
-		if !ccb.InLoop {
-			panic("continue used in non-loop block")
-		}
-		ccb.Code.AddInst(opcode.Continue, ccb.Linenum)
+		ccb.Code.AddInst(opcode.Continue, ccb.Linenum, compileLoopControl(ccb, "continue", node.Label))
 
 	case *ast.BreakStatement:
 		ccb.Linenum = node.Token.Pos.Line
-		if !ccb.InLoop {
-			panic("break used in non-loop block")
-		}
-		ccb.Code.AddInst(opcode.Break, ccb.Linenum)
+		ccb.Code.AddInst(opcode.Break, ccb.Linenum, compileLoopControl(ccb, "break", node.Label))
 
 	case *ast.ClassLiteral:
 		compileClassLiteral(ccb, node)
@@ -292,13 +312,11 @@ func compileMain(ccb *compile.CodeBlockCompiler, node ast.Node) {
 			compileMain(ccb, node.Arguments[i])
 		}
 		compileMain(ccb, node.Class)
-
-		ccb.Code.AddInst(opcode.MakeInstance, ccb.Linenum, uint16(len(node.Arguments)))
-
-	case *ast.AttributeExpression:
-		ccb.Linenum = node.Token.Pos.Line
-		compileMain(ccb, node.Left)
-		ccb.Code.AddInst(opcode.LoadAttribute, ccb.Linenum, ccb.Names.IndexOf(node.Index.String()))
+		if compileArgumentNames(ccb, node.Arguments) {
+			ccb.Code.AddInst(opcode.MakeInstanceKw, ccb.Linenum, uint16(len(node.Arguments)))
+		} else {
+			ccb.Code.AddInst(opcode.MakeInstance, ccb.Linenum, uint16(len(node.Arguments)))
+		}
 
 	case *ast.PassStatement:
 		ccb.Linenum = node.Token.Pos.Line
@@ -316,3 +334,47 @@ func compileMain(ccb *compile.CodeBlockCompiler, node ast.Node) {
 		panic(fmt.Sprintf("Node type not implemented: %T", node))
 	}
 }
+
+// compileLoopControl returns the depth of the loop a break or continue with
+// label leaves, counting from the innermost loop.
+func compileLoopControl(ccb *compile.CodeBlockCompiler, keyword string, label *ast.Identifier) uint16 {
+	if len(ccb.Loops) == 0 {
+		panic(keyword + " used in non-loop block")
+	}
+	if label == nil {
+		return 0
+	}
+
+	depth := loopDepth(ccb.Loops, label.Value)
+	if depth < 0 {
+		panic(fmt.Sprintf("%s to unknown loop label %s", keyword, label.Value))
+	}
+	return uint16(depth)
+}
+
+// compileArgumentNames pushes an array of the names of any named arguments in
+// args. It returns false without generating code if there aren't any.
+func compileArgumentNames(ccb *compile.CodeBlockCompiler, args []ast.Expression) bool {
+	names := 0
+	for _, arg := range args {
+		if named, ok := arg.(*ast.NamedArgument); ok {
+			ccb.Code.AddInst(opcode.LoadConst, ccb.Linenum, ccb.Constants.IndexOf(object.MakeStringObj(named.Name.Value)))
+			names++
+		}
+	}
+
+	if names == 0 {
+		return false
+	}
+	ccb.Code.AddInst(opcode.MakeArray, ccb.Linenum, uint16(names))
+	return true
+}
+
+// compileStoreName assigns the value on top of the stack to an existing variable.
+func compileStoreName(ccb *compile.CodeBlockCompiler, ident *ast.Identifier) {
+	if ccb.Locals.Contains(ident.Value) {
+		ccb.Code.AddInst(opcode.StoreFast, ccb.Linenum, ccb.Locals.IndexOf(ident.Value))
+	} else {
+		ccb.Code.AddInst(opcode.StoreGlobal, ccb.Linenum, ccb.Names.IndexOf(ident.Value))
+	}
+}
@@ -0,0 +1,79 @@
+package compiler
+
+import (
+	"github.com/nitrogen-lang/nitrogen/src/ast"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/compile"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm/opcode"
+)
+
+/*
+A comprehension such as [x * 2 for x in items if x > 0] compiles to a loop
+that appends directly to the collection left under the loop's iterator:
+
+	MAKE_ARRAY 0
+	<items>
+	GET_ITER
+	START_LOOP end iter
+	<next item, bind x>
+	<x > 0>
+	POP_JUMP_IF_FALSE iter
+	<x * 2>
+	ARRAY_APPEND 1          ; the array is under one iterator
+	iter:
+	NEXT_ITER
+	end:
+	END_BLOCK
+	POP
+
+Each extra for clause nests another loop and adds an iterator to the stack.
+A map comprehension uses MAKE_MAP 0 and MAP_INSERT in the same way.
+
+The collection isn't preallocated. It starts empty and grows as items are appended
+since filters and nested clauses mean the final size isn't known in advance.
+*/
+func compileArrayComprehension(ccb *compile.CodeBlockCompiler, node *ast.ArrayComprehension) {
+	ccb.Linenum = node.Token.Pos.Line
+	ccb.Code.AddInst(opcode.MakeArray, ccb.Linenum, 0)
+
+	compileComprehension(ccb, node.Clauses, func(bodyCCB *compile.CodeBlockCompiler) {
+		compileMain(bodyCCB, node.Element)
+		bodyCCB.Code.AddInst(opcode.ArrayAppend, bodyCCB.Linenum, uint16(len(node.Clauses)))
+	})
+}
+
+func compileMapComprehension(ccb *compile.CodeBlockCompiler, node *ast.MapComprehension) {
+	ccb.Linenum = node.Token.Pos.Line
+	ccb.Code.AddInst(opcode.MakeMap, ccb.Linenum, 0)
+
+	compileComprehension(ccb, node.Clauses, func(bodyCCB *compile.CodeBlockCompiler) {
+		compileMain(bodyCCB, node.Value)
+		compileMain(bodyCCB, node.Key)
+		bodyCCB.Code.AddInst(opcode.MapInsert, bodyCCB.Linenum, uint16(len(node.Clauses)))
+	})
+}
+
+// compileComprehension compiles the loop of each clause nested in the one
+// before it, element compiles the innermost body.
+func compileComprehension(
+	ccb *compile.CodeBlockCompiler,
+	clauses []*ast.ComprehensionClause,
+	element func(bodyCCB *compile.CodeBlockCompiler)) {
+
+	if len(clauses) == 0 {
+		element(ccb)
+		return
+	}
+
+	clause := clauses[0]
+	ccb.Linenum = clause.Token.Pos.Line
+	compileMain(ccb, clause.Iter)
+
+	// break and continue aren't allowed since they would leave the element half built
+	compileIter(ccb, clause.Key, clause.Value, nil, func(bodyCCB *compile.CodeBlockCompiler, nextLbl string) {
+		for _, filter := range clause.Filters {
+			compileMain(bodyCCB, filter)
+			bodyCCB.Code.AddLabeledArgs(opcode.PopJumpIfFalse, bodyCCB.Linenum, nextLbl)
+		}
+		compileComprehension(bodyCCB, clauses[1:], element)
+	})
+}
@@ -0,0 +1,72 @@
+package compiler
+
+import (
+	"github.com/nitrogen-lang/nitrogen/src/ast"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/compile"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm/opcode"
+)
+
+// compileDeferStatement evaluates the function and arguments like a normal call,
+// DEFER saves them in the frame to be called when it returns.
+func compileDeferStatement(ccb *compile.CodeBlockCompiler, node *ast.DeferStatement) {
+	ccb.Linenum = node.Token.Pos.Line
+	call := node.Call
+
+	for i := len(call.Arguments) - 1; i >= 0; i-- {
+		compileMain(ccb, call.Arguments[i])
+	}
+	compileMain(ccb, call.Function)
+
+	if compileArgumentNames(ccb, call.Arguments) {
+		ccb.Code.AddInst(opcode.DeferKw, ccb.Linenum, uint16(len(call.Arguments)))
+	} else {
+		ccb.Code.AddInst(opcode.Defer, ccb.Linenum, uint16(len(call.Arguments)))
+	}
+}
+
+/*
+A with statement compiles to:
+
+	<resource>
+	START_WITH     ; starts a block that closes the resource when it's popped
+	DEFINE name    ; or POP if there's no name
+	<body>
+	END_BLOCK
+
+The resource isn't kept on the stack, a break or continue in the body leaves the
+block without cleaning up the stack.
+*/
+func compileWithStatement(ccb *compile.CodeBlockCompiler, node *ast.WithStatement) {
+	ccb.Linenum = node.Token.Pos.Line
+	compileMain(ccb, node.Resource)
+	ccb.Code.AddInst(opcode.StartWith, ccb.Linenum)
+
+	bodyCCB := &compile.CodeBlockCompiler{
+		Constants: ccb.Constants,
+		Locals:    compile.NewStringTableOffset(len(ccb.Locals.Table)),
+		Names:     ccb.Names,
+		Code:      compile.NewInstSet(),
+		Filename:  ccb.Filename,
+		Name:      ccb.Name,
+		Loops:     ccb.Loops,
+		Linenum:   ccb.Linenum,
+	}
+
+	if node.Name != nil {
+		bodyCCB.Code.AddInst(opcode.Define, bodyCCB.Linenum, bodyCCB.Locals.IndexOf(node.Name.Value), 0)
+	} else {
+		bodyCCB.Code.AddInst(opcode.Pop, bodyCCB.Linenum)
+	}
+
+	compileMain(bodyCCB, node.Body)
+	if l := len(node.Body.Statements); l > 0 {
+		if _, ok := node.Body.Statements[l-1].(*ast.ExpressionStatement); ok {
+			bodyCCB.Code.AddInst(opcode.Pop, bodyCCB.Linenum)
+		}
+	}
+	ccb.Linenum = bodyCCB.Linenum
+
+	mergeLocals(ccb, bodyCCB.Locals)
+	ccb.Code.Merge(bodyCCB.Code)
+	ccb.Code.AddInst(opcode.EndBlock, ccb.Linenum)
+}
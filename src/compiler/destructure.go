@@ -0,0 +1,108 @@
+package compiler
+
+import (
+	"github.com/nitrogen-lang/nitrogen/src/ast"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/compile"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm/opcode"
+)
+
+/*
+Destructuring unpacks the value on top of the stack one element at a time:
+
+	<value>
+	LOAD_CONST n
+	MATCH destruct_array  ; raises an exception unless value is an array of n elements
+	DUP
+	LOAD_CONST 0
+	LOAD_INDEX
+	<store element 0>     ; nested patterns unpack the element the same way
+	...
+	POP
+*/
+func compileDestructureStatement(ccb *compile.CodeBlockCompiler, stmt *ast.DestructureStatement) {
+	ccb.Linenum = stmt.Token.Pos.Line
+	compileMain(ccb, stmt.Value)
+
+	if stmt.Define {
+		flags := opcode.NewDefineFlag().WithConstant(stmt.Const).WithExport(stmt.Export)
+		compileDestructure(ccb, stmt.Pattern, func(name *ast.Identifier) {
+			ccb.Code.AddInst(opcode.Define, ccb.Linenum, ccb.Locals.IndexOf(name.Value), uint16(flags))
+		})
+		return
+	}
+
+	compileDestructure(ccb, stmt.Pattern, func(name *ast.Identifier) {
+		compileStoreName(ccb, name)
+	})
+}
+
+// compileDestructure unpacks the value on top of the stack into the variables
+// bound by pattern. store is called with a value on top of the stack and must
+// consume it.
+func compileDestructure(ccb *compile.CodeBlockCompiler, pattern ast.Pattern, store func(*ast.Identifier)) {
+	loadInt := func(i int) {
+		ccb.Code.AddInst(opcode.LoadConst, ccb.Linenum, ccb.Constants.IndexOf(object.MakeIntObj(int64(i))))
+	}
+
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+		ccb.Code.AddInst(opcode.Pop, ccb.Linenum)
+
+	case *ast.BindingPattern:
+		store(pattern.Name)
+
+	case *ast.ArrayPattern:
+		ccb.Linenum = pattern.Token.Pos.Line
+		loadInt(len(pattern.Head) + len(pattern.Tail))
+		if pattern.HasRest {
+			ccb.Code.AddInst(opcode.Match, ccb.Linenum, uint16(opcode.DestructArrayMin))
+		} else {
+			ccb.Code.AddInst(opcode.Match, ccb.Linenum, uint16(opcode.DestructArray))
+		}
+
+		for i, elem := range pattern.Head {
+			if _, ok := elem.(*ast.WildcardPattern); ok {
+				continue
+			}
+			ccb.Code.AddInst(opcode.Dup, ccb.Linenum)
+			loadInt(i)
+			ccb.Code.AddInst(opcode.LoadIndex, ccb.Linenum)
+			compileDestructure(ccb, elem, store)
+		}
+
+		for i, elem := range pattern.Tail {
+			if _, ok := elem.(*ast.WildcardPattern); ok {
+				continue
+			}
+			ccb.Code.AddInst(opcode.Dup, ccb.Linenum)
+			loadInt(len(pattern.Tail) - i)
+			ccb.Code.AddInst(opcode.Match, ccb.Linenum, uint16(opcode.MatchFromEnd))
+			compileDestructure(ccb, elem, store)
+		}
+
+		if pattern.Rest != nil {
+			ccb.Code.AddInst(opcode.Dup, ccb.Linenum)
+			loadInt(len(pattern.Head))
+			loadInt(len(pattern.Tail))
+			ccb.Code.AddInst(opcode.Match, ccb.Linenum, uint16(opcode.MatchRest))
+			store(pattern.Rest)
+		}
+
+		ccb.Code.AddInst(opcode.Pop, ccb.Linenum)
+
+	case *ast.MapPattern:
+		ccb.Linenum = pattern.Token.Pos.Line
+		for i, key := range pattern.Keys {
+			ccb.Code.AddInst(opcode.Dup, ccb.Linenum)
+			compileMain(ccb, key)
+			ccb.Code.AddInst(opcode.Match, ccb.Linenum, uint16(opcode.DestructKey))
+			compileDestructure(ccb, pattern.Values[i], store)
+		}
+
+		ccb.Code.AddInst(opcode.Pop, ccb.Linenum)
+
+	default:
+		panic("Pattern can't be destructured")
+	}
+}
@@ -4,114 +4,318 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
-	"io"
+	"fmt"
+	"hash/crc32"
 	"io/fs"
 	"os"
 	"time"
 
 	"github.com/nitrogen-lang/nitrogen/src/elemental/compile"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm/opcode"
 )
 
+/*
+A compiled file has the following layout. All integers are big endian.
+
+	Optional shebang line (execHeader)
+	Magic header     4 bytes (ByteFileHeader)
+	Version          4 bytes (VersionNumber)
+	Source mod time  8 bytes, seconds since the Unix epoch
+	Checksum         4 bytes, CRC-32 (IEEE) of the payload
+	Payload          Marshaled code block
+
+Version 0.0.0.9 files don't have a checksum. Files from 0.0.1.0 and earlier have BREAK
+and CONTINUE without a loop depth, they're rewritten to depth 0 when decoded. When the
+format changes, the previous version should be added to compatibleVersions and Decode
+updated to read it.
+*/
 var (
 	ByteFileHeader = []byte{31, 'N', 'I', 'B'}
-	VersionNumber  = []byte{0, 0, 0, 9}
+	VersionNumber  = []byte{0, 0, 1, 1}
 
-	ErrVersion = errors.New("File does not match current version")
+	ErrVersion  = errors.New("File does not match current version")
+	ErrChecksum = errors.New("File checksum does not match, the file may be corrupt")
+)
+
+var (
+	versionNoChecksum  = []byte{0, 0, 0, 9}
+	versionNoLoopDepth = []byte{0, 0, 1, 0}
 )
 
+// compatibleVersions are older file versions that can still be decoded.
+var compatibleVersions = [][]byte{versionNoLoopDepth, versionNoChecksum}
+
 const execHeader = "#!/usr/bin/nitrogenrun\n"
 
 func IsErrVersion(err error) bool {
 	return err == ErrVersion
 }
 
-func WriteFile(name string, cb *compile.CodeBlock, ts time.Time, executable bool) error {
+// IsErrVerify returns true if err was returned because the bytecode failed verification.
+func IsErrVerify(err error) bool {
+	var verr *compile.VerifyError
+	return errors.As(err, &verr)
+}
+
+// Encode returns the compiled file representation of cb. ts is the modification time
+// of the source file.
+func Encode(cb *compile.CodeBlock, ts time.Time, executable bool) ([]byte, error) {
 	marshaled, err := Marshal(cb)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	fileMode := 0644
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	ts = ts.Round(time.Second)
+
+	buf := &bytes.Buffer{}
+	buf.Grow(len(execHeader) + 20 + len(marshaled))
+
 	if executable {
-		fileMode = 0755
+		buf.WriteString(execHeader)
 	}
 
-	file, err := os.OpenFile(name, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, fs.FileMode(fileMode))
+	buf.Write(ByteFileHeader)
+	buf.Write(VersionNumber)
+	buf.Write(encodeUint64(uint64(ts.Unix())))
+	checksum := make([]byte, 4)
+	binary.BigEndian.PutUint32(checksum, crc32.ChecksumIEEE(marshaled))
+	buf.Write(checksum)
+	buf.Write(marshaled)
+	return buf.Bytes(), nil
+}
+
+func WriteFile(name string, cb *compile.CodeBlock, ts time.Time, executable bool) error {
+	data, err := Encode(cb, ts, executable)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
+	fileMode := 0644
 	if executable {
-		file.WriteString(execHeader)
+		fileMode = 0755
 	}
 
-	if ts.IsZero() {
-		ts = time.Now()
-	}
-	ts = ts.Round(time.Second)
-
-	file.Write(ByteFileHeader)
-	file.Write(VersionNumber)
-	t := make([]byte, 8)
-	binary.BigEndian.PutUint64(t, uint64(ts.Unix()))
-	file.Write(t)
-	file.Write(marshaled)
-	return nil
+	return os.WriteFile(name, data, fs.FileMode(fileMode))
 }
 
 type FileInfo struct {
 	Filename string
 	Version  []byte
 	ModTime  time.Time
+	Checksum uint32 // Zero for versions without a checksum
 }
 
 func ReadFile(name string) (*compile.CodeBlock, *FileInfo, error) {
-	file, err := os.Open(name)
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cb, fi, err := Decode(data)
 	if err != nil {
 		return nil, nil, err
 	}
-	defer file.Close()
+	fi.Filename = name
+	return cb, fi, nil
+}
 
-	fi := &FileInfo{Filename: name}
+// Decode reads a compiled file created by Encode or a compatible older version.
+// The code block is verified before it's returned. Malformed data returns an
+// error, Decode never panics.
+func Decode(data []byte) (*compile.CodeBlock, *FileInfo, error) {
+	fi := &FileInfo{}
 
-	firstChar := make([]byte, 1)
-	file.Read(firstChar)
-	if firstChar[0] == '#' {
-		file.Seek(int64(len(execHeader)), 0)
-	} else {
-		file.Seek(0, 0)
+	if len(data) > 0 && data[0] == '#' { // Skip shebang line of executable files
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			return nil, nil, errors.New("File is not Nitrogen bytecode")
+		}
+		data = data[end+1:]
 	}
 
-	fileHeader := make([]byte, 4)
-	file.Read(fileHeader)
-	if !bytes.Equal(ByteFileHeader, fileHeader) {
+	if len(data) < 4 || !bytes.Equal(ByteFileHeader, data[:4]) {
 		return nil, nil, errors.New("File is not Nitrogen bytecode")
 	}
+	data = data[4:]
 
-	fileVersion := make([]byte, 4)
-	file.Read(fileVersion)
-	if !bytes.Equal(VersionNumber, fileVersion) {
+	if len(data) < 4 {
 		return nil, nil, ErrVersion
 	}
-	fi.Version = fileVersion
+	fi.Version = append([]byte(nil), data[:4]...)
+	data = data[4:]
 
-	fileTime := make([]byte, 8)
-	n, _ := file.Read(fileTime)
-	if n != 8 {
+	if !bytes.Equal(VersionNumber, fi.Version) && !isCompatibleVersion(fi.Version) {
+		return nil, nil, ErrVersion
+	}
+	hasChecksum := !bytes.Equal(fi.Version, versionNoChecksum)
+
+	if len(data) < 8 {
 		return nil, nil, errors.New("Invalid timestamp")
 	}
 	// fileTime is checked by caller if they care about it
-	fi.ModTime = time.Unix(int64(binary.BigEndian.Uint64(fileTime)), 0)
+	fi.ModTime = time.Unix(int64(decodeUint64(data[:8])), 0)
+	data = data[8:]
+
+	if hasChecksum {
+		if len(data) < 4 {
+			return nil, nil, errTruncated
+		}
+		fi.Checksum = binary.BigEndian.Uint32(data[:4])
+		data = data[4:]
+
+		if crc32.ChecksumIEEE(data) != fi.Checksum {
+			return nil, nil, ErrChecksum
+		}
+	}
 
-	rest, err := io.ReadAll(file)
+	obj, _, err := Unmarshal(data)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	cb, _, err := Unmarshal(rest)
-	if err != nil {
+	code, ok := obj.(*compile.CodeBlock)
+	if !ok {
+		return nil, nil, errors.New("File does not contain a code block")
+	}
+	if !bytes.Equal(VersionNumber, fi.Version) { // Both compatible versions predate loop depths
+		if err := addLoopDepths(code); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := compile.Verify(code); err != nil {
 		return nil, nil, err
 	}
-	return cb.(*compile.CodeBlock), fi, nil
+	return code, fi, nil
+}
+
+func isCompatibleVersion(v []byte) bool {
+	for _, compat := range compatibleVersions {
+		if bytes.Equal(compat, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// addLoopDepths rewrites code from versions where BREAK and CONTINUE had no argument
+// to leave the innermost loop with depth 0. Jump targets and line offsets after them
+// are moved to account for the wider instructions. Code blocks in the constants,
+// such as functions, are rewritten too.
+func addLoopDepths(cb *compile.CodeBlock) error {
+	for _, c := range cb.Constants {
+		if inner, ok := c.(*compile.CodeBlock); ok {
+			if err := addLoopDepths(inner); err != nil {
+				return err
+			}
+		}
+	}
+
+	// moved maps each old offset to its new offset
+	code := cb.Code
+	moved := make([]int, len(code)+1)
+	shift := 0
+	for offset := 0; offset < len(code); {
+		width, err := oldArgWidth(code, offset)
+		if err != nil {
+			return err
+		}
+		for i := offset; i <= offset+width; i++ {
+			moved[i] = i + shift
+		}
+
+		op := opcode.Opcode(code[offset])
+		if op == opcode.Break || op == opcode.Continue {
+			shift += 2
+		}
+		offset += width + 1
+	}
+	moved[len(code)] = len(code) + shift
+
+	if shift == 0 {
+		return nil
+	}
+	if len(code)+shift > 0xffff {
+		return errors.New("Code block is too large to upgrade")
+	}
+
+	// Targets outside the code are left for Verify to reject
+	target := func(t uint16) []byte {
+		if int(t) < len(moved) {
+			t = uint16(moved[t])
+		}
+		return encodeUint16(t)
+	}
+
+	out := make([]byte, 0, len(code)+shift)
+	for offset := 0; offset < len(code); {
+		width, _ := oldArgWidth(code, offset)
+		op := opcode.Opcode(code[offset])
+		args := code[offset+1 : offset+1+width]
+		out = append(out, code[offset])
+
+		switch op {
+		case opcode.Break, opcode.Continue:
+			out = append(out, 0, 0)
+		case opcode.JumpForward:
+			// Relative to the instruction following the jump
+			next := offset + 1 + width
+			to := next + int(decodeUint16(args))
+			if to < len(moved) {
+				out = append(out, encodeUint16(uint16(moved[to]-moved[next]))...)
+			} else {
+				out = append(out, args...)
+			}
+		case opcode.JumpAbsolute, opcode.PopJumpIfTrue, opcode.PopJumpIfFalse,
+			opcode.JumpIfTrueOrPop, opcode.JumpIfFalseOrPop, opcode.Recover:
+			out = append(out, target(decodeUint16(args))...)
+		case opcode.StartLoop:
+			out = append(out, target(decodeUint16(args[:2]))...)
+			out = append(out, target(decodeUint16(args[2:]))...)
+		default:
+			out = append(out, args...)
+		}
+		offset += width + 1
+	}
+	cb.Code = out
+
+	for i := 0; i < len(cb.LineOffsets); i += 2 {
+		if off := int(cb.LineOffsets[i]); off < len(moved) {
+			cb.LineOffsets[i] = uint16(moved[off])
+		}
+	}
+	return nil
+}
+
+// oldArgWidth returns the argument width of the instruction at offset in code from
+// a version before loop depths.
+func oldArgWidth(code []byte, offset int) (int, error) {
+	op := opcode.Opcode(code[offset])
+
+	var width int
+	switch {
+	case op == opcode.Break || op == opcode.Continue:
+		width = 0
+	case op >= opcode.Match: // Added after loop depths
+		return 0, fmt.Errorf("Invalid opcode %d at offset %d", code[offset], offset)
+	case opcode.HasNoArg[op]:
+		width = 0
+	case opcode.HasOneByteArg[op]:
+		width = 1
+	case opcode.HasTwoByteArg[op]:
+		width = 2
+	case opcode.HasThreeByteArg[op]:
+		width = 3
+	case opcode.HasFourByteArg[op]:
+		width = 4
+	default:
+		return 0, fmt.Errorf("Invalid opcode %d at offset %d", code[offset], offset)
+	}
+
+	if offset+1+width > len(code) {
+		return 0, errTruncated
+	}
+	return width, nil
 }
@@ -0,0 +1,79 @@
+package marshal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nitrogen-lang/nitrogen/src/compiler"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/compile"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/lexer"
+	"github.com/nitrogen-lang/nitrogen/src/parser"
+)
+
+func compileSimple(tb testing.TB) []byte {
+	l, err := lexer.NewFile("./testdata/simple.ni")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	program := parser.New(l, &parser.Settings{}).ParseProgram()
+	data, err := Encode(compiler.Compile(program, "__main"), time.Unix(0, 0), false)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return data
+}
+
+func FuzzUnmarshal(f *testing.F) {
+	seeds := []object.Object{
+		object.MakeIntObj(42),
+		object.MakeFloatObj(1.5),
+		object.MakeStringObj("hello"),
+		object.TrueConst,
+		object.NullConst,
+		&object.Interface{
+			Name: "Iface",
+			Methods: map[string]*object.IfaceMethodDef{
+				"m": {Name: "m", Parameters: []string{"a", "b"}},
+			},
+		},
+	}
+	for _, seed := range seeds {
+		b, _ := Marshal(seed)
+		f.Add(b)
+	}
+	f.Add(compileSimple(f)[20:])
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		obj, rest, err := Unmarshal(data)
+		if err != nil {
+			return
+		}
+		if obj == nil {
+			t.Fatal("nil object without an error")
+		}
+		if len(rest) > len(data) {
+			t.Fatal("remaining input grew")
+		}
+		if cb, ok := obj.(*compile.CodeBlock); ok {
+			compile.Verify(cb)
+		}
+	})
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add(compileSimple(f))
+	f.Add([]byte(execHeader))
+	f.Add(append(append([]byte{}, ByteFileHeader...), versionNoChecksum...))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cb, fi, err := Decode(data)
+		if err != nil {
+			return
+		}
+		if cb == nil || fi == nil {
+			t.Fatal("nil result without an error")
+		}
+	})
+}
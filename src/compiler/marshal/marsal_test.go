@@ -2,8 +2,10 @@ package marshal
 
 import (
 	"bytes"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/nitrogen-lang/nitrogen/src/compiler"
 	"github.com/nitrogen-lang/nitrogen/src/lexer"
@@ -11,6 +13,7 @@ import (
 
 	"github.com/nitrogen-lang/nitrogen/src/elemental/compile"
 	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm/opcode"
 )
 
 func TestIntegerMarshal(t *testing.T) {
@@ -148,3 +151,126 @@ func TestCodeBlockMarshal(t *testing.T) {
 		t.Fatal("Code objects are not the same")
 	}
 }
+
+func TestReadFileVerifies(t *testing.T) {
+	l, err := lexer.NewFile("./testdata/simple.ni")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	program := parser.New(l, &parser.Settings{}).ParseProgram()
+	code := compiler.Compile(program, "__main")
+
+	file := filepath.Join(t.TempDir(), "simple.nib")
+	if err := WriteFile(file, code, time.Time{}, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ReadFile(file); err != nil {
+		t.Fatal(err)
+	}
+
+	// Point the first instruction at a constant that doesn't exist
+	code.Code[1] = 0xff
+	if err := WriteFile(file, code, time.Time{}, false); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = ReadFile(file)
+	if !IsErrVerify(err) {
+		t.Fatalf("Expected verification error, got %v", err)
+	}
+}
+
+func TestDecodeChecksum(t *testing.T) {
+	data := compileSimple(t)
+	if _, _, err := Decode(data); err != nil {
+		t.Fatal(err)
+	}
+
+	data[len(data)-1] ^= 0xff
+	if _, _, err := Decode(data); err != ErrChecksum {
+		t.Fatalf("Expected checksum error, got %v", err)
+	}
+}
+
+func TestDecodePreviousVersion(t *testing.T) {
+	data := compileSimple(t)
+
+	// Rewrite as a version 0.0.0.9 file which has no checksum
+	legacy := append([]byte{}, ByteFileHeader...)
+	legacy = append(legacy, versionNoChecksum...)
+	legacy = append(legacy, data[8:16]...)
+	legacy = append(legacy, data[20:]...)
+
+	cb, fi, err := Decode(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fi.Version, versionNoChecksum) {
+		t.Fatalf("Wrong version %v", fi.Version)
+	}
+	if cb.Name != "__main" {
+		t.Fatalf("Wrong code block name %s", cb.Name)
+	}
+
+	legacy[7] = 8
+	if _, _, err := Decode(legacy); !IsErrVersion(err) {
+		t.Fatalf("Expected version error, got %v", err)
+	}
+}
+
+func TestAddLoopDepths(t *testing.T) {
+	cb := &compile.CodeBlock{
+		Name: "__main",
+		Code: []byte{
+			opcode.StartLoop.ToByte(), 0, 11, 0, 10,
+			opcode.Continue.ToByte(),
+			opcode.JumpForward.ToByte(), 0, 1,
+			opcode.Break.ToByte(),
+			opcode.NextIter.ToByte(),
+			opcode.EndBlock.ToByte(),
+			opcode.Return.ToByte(),
+		},
+		LineOffsets: []uint16{0, 1, 6, 2, 9, 3},
+	}
+	inner := &compile.CodeBlock{Code: []byte{opcode.Break.ToByte()}}
+	cb.Constants = []object.Object{inner}
+
+	if err := addLoopDepths(cb); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []byte{
+		opcode.StartLoop.ToByte(), 0, 15, 0, 14,
+		opcode.Continue.ToByte(), 0, 0,
+		opcode.JumpForward.ToByte(), 0, 3,
+		opcode.Break.ToByte(), 0, 0,
+		opcode.NextIter.ToByte(),
+		opcode.EndBlock.ToByte(),
+		opcode.Return.ToByte(),
+	}
+	if !bytes.Equal(cb.Code, expected) {
+		t.Fatalf("Wrong code\nexpected %v\ngot      %v", expected, cb.Code)
+	}
+	if !reflect.DeepEqual(cb.LineOffsets, []uint16{0, 1, 8, 2, 11, 3}) {
+		t.Fatalf("Wrong line offsets %v", cb.LineOffsets)
+	}
+	if !bytes.Equal(inner.Code, []byte{opcode.Break.ToByte(), 0, 0}) {
+		t.Fatalf("Inner code block wasn't rewritten %v", inner.Code)
+	}
+
+	// Opcodes added after loop depths can't be in an old file
+	bad := &compile.CodeBlock{Code: []byte{opcode.Match.ToByte(), 0}}
+	if err := addLoopDepths(bad); err == nil {
+		t.Fatal("Expected an error for a newer opcode")
+	}
+}
+
+func TestUnmarshalTruncated(t *testing.T) {
+	data := compileSimple(t)[20:]
+
+	for i := 0; i < len(data); i++ {
+		if _, _, err := Unmarshal(data[:i]); err == nil {
+			t.Fatalf("Expected error unmarshaling %d of %d bytes", i, len(data))
+		}
+	}
+}
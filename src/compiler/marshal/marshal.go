@@ -141,131 +141,215 @@ func Marshal(o object.Object) ([]byte, error) {
 	return nil, fmt.Errorf("Object type %T doesn't have a marshal implementation", o)
 }
 
+var errTruncated = errors.New("Malformed data, unexpected end of input")
+
+// Unmarshal decodes a single object from the front of in and returns the remaining bytes.
+// Malformed input returns an error, Unmarshal never panics.
 func Unmarshal(in []byte) (object.Object, []byte, error) {
+	if len(in) == 0 {
+		return nil, in, errTruncated
+	}
+
 	switch in[0] {
 	case 'i':
+		if len(in) < 9 {
+			return nil, in, errTruncated
+		}
 		v := decodeUint64(in[1:9])
 		return object.MakeIntObj(int64(v)), in[9:], nil
 	case 'f':
+		if len(in) < 9 {
+			return nil, in, errTruncated
+		}
 		v := decodeUint64(in[1:9])
 		return object.MakeFloatObj(math.Float64frombits(v)), in[9:], nil
 	case 's':
-		slen := int(binary.BigEndian.Uint32(in[1:5]))
-		if len(in) < slen+5 {
+		if len(in) < 5 {
+			return nil, in, errTruncated
+		}
+		slen := uint64(binary.BigEndian.Uint32(in[1:5]))
+		if uint64(len(in)-5) < slen {
 			return nil, in, errors.New("Malformed string")
 		}
-		s := make([]byte, slen)
-		copy(s, in[5:])
-		return object.MakeStringObj(string(s)), in[slen+5:], nil
+		return object.MakeStringObj(string(in[5 : slen+5])), in[slen+5:], nil
 	case 'b':
+		if len(in) < 2 {
+			return nil, in, errTruncated
+		}
 		return object.NativeBoolToBooleanObj(in[1] == 1), in[2:], nil
 	case 'n':
 		return object.NullConst, in[1:], nil
 	case 'e':
-		inslice := in[9:]
+		inslice, rest, err := unmarshalLength(in)
+		if err != nil {
+			return nil, in, err
+		}
 
 		iface := &object.Interface{}
-		name, inslice, _ := Unmarshal(inslice)
-		iface.Name = string(name.(*object.String).Value)
+		iface.Name, inslice, err = unmarshalString(inslice)
+		if err != nil {
+			return nil, in, err
+		}
 
-		numOfMethods := int(decodeUint16(inslice[:2]))
-		inslice = inslice[2:]
+		var numOfMethods uint16
+		numOfMethods, inslice, err = unmarshalUint16(inslice)
+		if err != nil {
+			return nil, in, err
+		}
 		iface.Methods = make(map[string]*object.IfaceMethodDef, numOfMethods)
 
-		for i := 0; i < numOfMethods; i++ {
+		for i := 0; i < int(numOfMethods); i++ {
 			methDef := &object.IfaceMethodDef{}
-			name, inslice, _ = Unmarshal(inslice)
-			methDef.Name = string(name.(*object.String).Value)
+			methDef.Name, inslice, err = unmarshalString(inslice)
+			if err != nil {
+				return nil, in, err
+			}
 
-			numOfParams := int(decodeUint16(inslice[:2]))
-			inslice = inslice[2:]
+			var numOfParams uint16
+			numOfParams, inslice, err = unmarshalUint16(inslice)
+			if err != nil {
+				return nil, in, err
+			}
 			methDef.Parameters = make([]string, numOfParams)
 
-			for p := 0; p < numOfParams; p++ {
-				name, inslice, _ = Unmarshal(inslice)
-				methDef.Parameters[p] = string(name.(*object.String).Value)
+			for p := range methDef.Parameters {
+				methDef.Parameters[p], inslice, err = unmarshalString(inslice)
+				if err != nil {
+					return nil, in, err
+				}
 			}
 
 			iface.Methods[methDef.Name] = methDef
 		}
 
-		return iface, inslice, nil
+		return iface, rest, nil
 	case 'c':
-		inslice := in[9:] // Length is bytes [1-8]
+		inslice, rest, err := unmarshalLength(in)
+		if err != nil {
+			return nil, in, err
+		}
 
 		cb := &compile.CodeBlock{}
-		name, inslice, _ := Unmarshal(inslice)
-		cb.Name = string(name.(*object.String).Value)
+		cb.Name, inslice, err = unmarshalString(inslice)
+		if err != nil {
+			return nil, in, err
+		}
 
-		filename, inslice, _ := Unmarshal(inslice)
-		cb.Filename = string(filename.(*object.String).Value)
+		cb.Filename, inslice, err = unmarshalString(inslice)
+		if err != nil {
+			return nil, in, err
+		}
 
+		if len(inslice) < 8 {
+			return nil, in, errTruncated
+		}
 		cb.Native = inslice[0] == 1
-		inslice = inslice[1:]
-		cb.ClassMethod = inslice[0] == 1
-		inslice = inslice[1:]
-		cb.LocalCount = int(decodeUint16(inslice[:2]))
-		inslice = inslice[2:]
-		cb.MaxStackSize = int(decodeUint16(inslice[:2]))
-		inslice = inslice[2:]
-		cb.MaxBlockSize = int(decodeUint16(inslice[:2]))
-		inslice = inslice[2:]
-
-		constantsLen := int(decodeUint16(inslice[:2]))
-		inslice = inslice[2:]
+		cb.ClassMethod = inslice[1] == 1
+		cb.LocalCount = int(decodeUint16(inslice[2:4]))
+		cb.MaxStackSize = int(decodeUint16(inslice[4:6]))
+		cb.MaxBlockSize = int(decodeUint16(inslice[6:8]))
+		inslice = inslice[8:]
+
+		var constantsLen uint16
+		constantsLen, inslice, err = unmarshalUint16(inslice)
+		if err != nil {
+			return nil, in, err
+		}
 		cb.Constants = make([]object.Object, constantsLen)
-		var err error
 		for i := range cb.Constants {
 			cb.Constants[i], inslice, err = Unmarshal(inslice)
 			if err != nil {
-				return nil, inslice, err
+				return nil, in, err
 			}
 		}
 
-		localsLen := int(decodeUint16(inslice[:2]))
-		inslice = inslice[2:]
-		cb.Locals = make([]string, localsLen)
-		for i := range cb.Locals {
-			var tmpStr object.Object
-			tmpStr, inslice, err = Unmarshal(inslice)
-			if err != nil {
-				return nil, inslice, err
-			}
-			cb.Locals[i] = string(tmpStr.(*object.String).Value)
+		cb.Locals, inslice, err = unmarshalStringSlice(inslice)
+		if err != nil {
+			return nil, in, err
 		}
 
-		namesLen := int(decodeUint16(inslice[:2]))
-		inslice = inslice[2:]
-		cb.Names = make([]string, namesLen)
-		for i := range cb.Names {
-			var tmpStr object.Object
-			tmpStr, inslice, err = Unmarshal(inslice)
-			if err != nil {
-				return nil, inslice, err
-			}
-			cb.Names[i] = string(tmpStr.(*object.String).Value)
+		cb.Names, inslice, err = unmarshalStringSlice(inslice)
+		if err != nil {
+			return nil, in, err
 		}
 
-		lineOffsetPairs := int(decodeUint16(inslice[:2]))
-		inslice = inslice[2:]
-		cb.LineOffsets = make([]uint16, lineOffsetPairs*2)
-		for i := 0; i < len(cb.LineOffsets); i += 2 {
+		var lineOffsetPairs uint16
+		lineOffsetPairs, inslice, err = unmarshalUint16(inslice)
+		if err != nil {
+			return nil, in, err
+		}
+		if len(inslice) < int(lineOffsetPairs)*4 {
+			return nil, in, errTruncated
+		}
+		cb.LineOffsets = make([]uint16, int(lineOffsetPairs)*2)
+		for i := range cb.LineOffsets {
 			cb.LineOffsets[i] = decodeUint16(inslice[:2])
 			inslice = inslice[2:]
-			cb.LineOffsets[i+1] = decodeUint16(inslice[:2])
-			inslice = inslice[2:]
 		}
 
-		codeLen := int(decodeUint16(inslice[:2]))
-		inslice = inslice[2:]
+		var codeLen uint16
+		codeLen, inslice, err = unmarshalUint16(inslice)
+		if err != nil {
+			return nil, in, err
+		}
+		if len(inslice) < int(codeLen) {
+			return nil, in, errTruncated
+		}
 		cb.Code = make([]byte, codeLen)
 		copy(cb.Code, inslice)
 
-		return cb, inslice[codeLen:], nil
+		return cb, rest, nil
 	}
 	return nil, in, fmt.Errorf("Unknown unmarshal for type char %c", in[0])
 }
 
+// unmarshalLength splits a length prefixed object into its content and the bytes following it.
+func unmarshalLength(in []byte) ([]byte, []byte, error) {
+	if len(in) < 9 {
+		return nil, nil, errTruncated
+	}
+	clen := decodeUint64(in[1:9])
+	if uint64(len(in)-9) < clen {
+		return nil, nil, errTruncated
+	}
+	return in[9 : 9+clen], in[9+clen:], nil
+}
+
+func unmarshalUint16(in []byte) (uint16, []byte, error) {
+	if len(in) < 2 {
+		return 0, in, errTruncated
+	}
+	return decodeUint16(in[:2]), in[2:], nil
+}
+
+func unmarshalString(in []byte) (string, []byte, error) {
+	obj, rest, err := Unmarshal(in)
+	if err != nil {
+		return "", in, err
+	}
+	str, ok := obj.(*object.String)
+	if !ok {
+		return "", in, fmt.Errorf("Malformed data, expected string got %s", obj.Type())
+	}
+	return str.String(), rest, nil
+}
+
+func unmarshalStringSlice(in []byte) ([]string, []byte, error) {
+	num, in, err := unmarshalUint16(in)
+	if err != nil {
+		return nil, in, err
+	}
+
+	strs := make([]string, num)
+	for i := range strs {
+		strs[i], in, err = unmarshalString(in)
+		if err != nil {
+			return nil, in, err
+		}
+	}
+	return strs, in, nil
+}
+
 func decodeUint64(in []byte) uint64 {
 	return binary.BigEndian.Uint64(in)
 }
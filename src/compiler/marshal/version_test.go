@@ -0,0 +1,44 @@
+package marshal_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nitrogen-lang/nitrogen/src/compiler/marshal"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm"
+)
+
+// loops-0.0.1.0.nib is testdata/loops.ni compiled by version 0.0.1.0, before BREAK
+// and CONTINUE had a loop depth.
+func TestRunPreviousVersion(t *testing.T) {
+	code, fi, err := marshal.ReadFile("./testdata/loops-0.0.1.0.nib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fi.Version, []byte{0, 0, 1, 0}) {
+		t.Fatalf("Wrong version %v", fi.Version)
+	}
+
+	env := object.NewEnvironment()
+	ret, err := vm.NewVM(vm.NewSettings()).Execute(code, env, "__main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exc, ok := ret.(*object.Exception); ok {
+		t.Fatal(exc.Message)
+	}
+
+	expected := map[string]int64{
+		"total": 1345,
+		"found": 2,
+		"count": 3,
+	}
+	for name, value := range expected {
+		obj, _ := env.Get(name)
+		i, ok := obj.(*object.Integer)
+		if !ok || i.Value != value {
+			t.Errorf("Expected %s to be %d, got %v", name, value, obj)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package compiler
+
+import (
+	"github.com/nitrogen-lang/nitrogen/src/ast"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/compile"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm/opcode"
+)
+
+// matchSubject is the variable holding the value being matched. It can't be
+// written in source code so it never collides with a user variable.
+const matchSubject = "$match"
+
+/*
+A match expression compiles to:
+
+	<subject>
+	START_BLOCK
+	DEFINE $match
+	; for each arm
+	START_BLOCK
+	<pattern test>        ; leaves a boolean, bindings are defined in the arm's block
+	POP_JUMP_IF_FALSE fail
+	<guard>
+	POP_JUMP_IF_FALSE fail
+	<body>
+	LOAD_CONST true
+	JUMP_ABSOLUTE join
+	fail:
+	LOAD_CONST nil
+	LOAD_CONST false
+	join:
+	END_BLOCK
+	POP_JUMP_IF_TRUE end  ; the body's value is left on the stack
+	POP
+	; after all arms
+	LOAD_CONST nil
+	end:
+	END_BLOCK
+*/
+func compileMatchExpression(ccb *compile.CodeBlockCompiler, match *ast.MatchExpression) {
+	ccb.Linenum = match.Token.Pos.Line
+	endLbl := randomLabel("endMatch_")
+
+	// The subject is only evaluated once
+	compileMain(ccb, match.Subject)
+	ccb.Code.AddInst(opcode.StartBlock, ccb.Linenum)
+	ccb.Code.AddInst(opcode.Define, ccb.Linenum, ccb.Locals.IndexOf(matchSubject), 0)
+
+	for _, arm := range match.Arms {
+		compileMatchArm(ccb, arm, endLbl)
+	}
+
+	compileLoadNull(ccb)
+	ccb.Code.AddLabel(endLbl, ccb.Linenum)
+	ccb.Code.AddInst(opcode.EndBlock, ccb.Linenum)
+}
+
+func compileMatchArm(ccb *compile.CodeBlockCompiler, arm *ast.MatchArm, endLbl string) {
+	ccb.Linenum = arm.Token.Pos.Line
+	failLbl := randomLabel("matchFail_")
+	joinLbl := randomLabel("matchJoin_")
+
+	armCCB := &compile.CodeBlockCompiler{
+		Constants: ccb.Constants,
+		Locals:    compile.NewStringTableOffset(len(ccb.Locals.Table)),
+		Names:     ccb.Names,
+		Code:      compile.NewInstSet(),
+		Filename:  ccb.Filename,
+		Name:      ccb.Name,
+		Loops:     ccb.Loops,
+		Linenum:   ccb.Linenum,
+	}
+
+	armCCB.Code.AddInst(opcode.StartBlock, armCCB.Linenum)
+	compilePattern(armCCB, arm.Pattern, func() {
+		armCCB.Code.AddInst(opcode.LoadGlobal, armCCB.Linenum, armCCB.Names.IndexOf(matchSubject))
+	})
+	armCCB.Code.AddLabeledArgs(opcode.PopJumpIfFalse, armCCB.Linenum, failLbl)
+
+	if arm.Guard != nil {
+		compileMain(armCCB, arm.Guard)
+		armCCB.Code.AddLabeledArgs(opcode.PopJumpIfFalse, armCCB.Linenum, failLbl)
+	}
+
+	compileMain(armCCB, arm.Body)
+	if l := len(arm.Body.Statements); l == 0 {
+		compileLoadNull(armCCB)
+	} else if _, ok := arm.Body.Statements[l-1].(*ast.ExpressionStatement); !ok {
+		compileLoadNull(armCCB)
+	}
+	armCCB.Code.AddInst(opcode.LoadConst, armCCB.Linenum, armCCB.Constants.IndexOf(object.TrueConst))
+	armCCB.Code.AddLabeledArgs(opcode.JumpAbsolute, armCCB.Linenum, joinLbl)
+
+	armCCB.Code.AddLabel(failLbl, armCCB.Linenum)
+	compileLoadNull(armCCB)
+	armCCB.Code.AddInst(opcode.LoadConst, armCCB.Linenum, armCCB.Constants.IndexOf(object.FalseConst))
+
+	armCCB.Code.AddLabel(joinLbl, armCCB.Linenum)
+	armCCB.Code.AddInst(opcode.EndBlock, armCCB.Linenum)
+	armCCB.Code.AddLabeledArgs(opcode.PopJumpIfTrue, armCCB.Linenum, endLbl)
+	armCCB.Code.AddInst(opcode.Pop, armCCB.Linenum)
+
+	ccb.Linenum = armCCB.Linenum
+	mergeLocals(ccb, armCCB.Locals)
+	ccb.Code.Merge(armCCB.Code)
+}
+
+// compilePattern generates code that leaves true on the stack if the value
+// pushed by load matches pattern, otherwise false. Variables are bound in the
+// current block as the pattern is checked.
+func compilePattern(ccb *compile.CodeBlockCompiler, pattern ast.Pattern, load func()) {
+	doneLbl := randomLabel("pattern_")
+
+	// Each check after the first skips the rest of the pattern if the previous one failed
+	checks := 0
+	check := func() {
+		if checks > 0 {
+			ccb.Code.AddLabeledArgs(opcode.JumpIfFalseOrPop, ccb.Linenum, doneLbl)
+		}
+		checks++
+	}
+	loadConst := func(o object.Object) {
+		ccb.Code.AddInst(opcode.LoadConst, ccb.Linenum, ccb.Constants.IndexOf(o))
+	}
+	loadInt := func(i int) {
+		loadConst(object.MakeIntObj(int64(i)))
+	}
+
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+		ccb.Linenum = pattern.Token.Pos.Line
+		loadConst(object.TrueConst)
+		return
+
+	case *ast.BindingPattern:
+		ccb.Linenum = pattern.Token.Pos.Line
+		load()
+		ccb.Code.AddInst(opcode.Define, ccb.Linenum, ccb.Locals.IndexOf(pattern.Name.Value), 0)
+		loadConst(object.TrueConst)
+		return
+
+	case *ast.ValuePattern:
+		ccb.Linenum = pattern.Token.Pos.Line
+		load()
+		compileMain(ccb, pattern.Value)
+		ccb.Code.AddInst(opcode.Compare, ccb.Linenum, uint16(opcode.CmpEq))
+		return
+
+	case *ast.RangePattern:
+		ccb.Linenum = pattern.Token.Pos.Line
+		if pattern.Low != nil {
+			check()
+			load()
+			compileMain(ccb, pattern.Low)
+			ccb.Code.AddInst(opcode.Compare, ccb.Linenum, uint16(opcode.CmpGTEq))
+		}
+		if pattern.High != nil {
+			check()
+			load()
+			compileMain(ccb, pattern.High)
+			ccb.Code.AddInst(opcode.Compare, ccb.Linenum, uint16(opcode.CmpLTEq))
+		}
+
+	case *ast.AlternativePattern:
+		ccb.Linenum = pattern.Token.Pos.Line
+		for i, alt := range pattern.Alternatives {
+			if i > 0 {
+				ccb.Code.AddLabeledArgs(opcode.JumpIfTrueOrPop, ccb.Linenum, doneLbl)
+			}
+			compilePattern(ccb, alt, load)
+		}
+
+	case *ast.ArrayPattern:
+		ccb.Linenum = pattern.Token.Pos.Line
+		check()
+		load()
+		loadInt(len(pattern.Head) + len(pattern.Tail))
+		if pattern.HasRest {
+			ccb.Code.AddInst(opcode.Match, ccb.Linenum, uint16(opcode.MatchArrayMin))
+		} else {
+			ccb.Code.AddInst(opcode.Match, ccb.Linenum, uint16(opcode.MatchArray))
+		}
+
+		for i, elem := range pattern.Head {
+			if _, ok := elem.(*ast.WildcardPattern); ok {
+				continue
+			}
+			check()
+			compilePattern(ccb, elem, func() {
+				load()
+				loadInt(i)
+				ccb.Code.AddInst(opcode.LoadIndex, ccb.Linenum)
+			})
+		}
+
+		for i, elem := range pattern.Tail {
+			if _, ok := elem.(*ast.WildcardPattern); ok {
+				continue
+			}
+			fromEnd := len(pattern.Tail) - i
+			check()
+			compilePattern(ccb, elem, func() {
+				load()
+				loadInt(fromEnd)
+				ccb.Code.AddInst(opcode.Match, ccb.Linenum, uint16(opcode.MatchFromEnd))
+			})
+		}
+
+		if pattern.Rest != nil {
+			check()
+			load()
+			loadInt(len(pattern.Head))
+			loadInt(len(pattern.Tail))
+			ccb.Code.AddInst(opcode.Match, ccb.Linenum, uint16(opcode.MatchRest))
+			ccb.Code.AddInst(opcode.Define, ccb.Linenum, ccb.Locals.IndexOf(pattern.Rest.Value), 0)
+			loadConst(object.TrueConst)
+		}
+
+	case *ast.MapPattern:
+		ccb.Linenum = pattern.Token.Pos.Line
+		if len(pattern.Keys) == 0 {
+			check()
+			load()
+			ccb.Code.AddInst(opcode.Match, ccb.Linenum, uint16(opcode.MatchMap))
+		}
+
+		for i, key := range pattern.Keys {
+			check()
+			load()
+			compileMain(ccb, key)
+			ccb.Code.AddInst(opcode.Match, ccb.Linenum, uint16(opcode.MatchKey))
+
+			if _, ok := pattern.Values[i].(*ast.WildcardPattern); ok {
+				continue
+			}
+			check()
+			compilePattern(ccb, pattern.Values[i], func() {
+				load()
+				compileMain(ccb, key)
+				ccb.Code.AddInst(opcode.LoadIndex, ccb.Linenum)
+			})
+		}
+
+	case *ast.ClassPattern:
+		ccb.Linenum = pattern.Token.Pos.Line
+		check()
+		load()
+		compileMain(ccb, pattern.Class)
+		ccb.Code.AddInst(opcode.Match, ccb.Linenum, uint16(opcode.MatchInstance))
+
+		for i, field := range pattern.Fields {
+			if _, ok := pattern.Patterns[i].(*ast.WildcardPattern); ok {
+				continue
+			}
+			check()
+			compilePattern(ccb, pattern.Patterns[i], func() {
+				load()
+				ccb.Code.AddInst(opcode.LoadAttribute, ccb.Linenum, ccb.Names.IndexOf(field))
+			})
+		}
+	}
+
+	ccb.Code.AddLabel(doneLbl, ccb.Linenum)
+}
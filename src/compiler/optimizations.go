@@ -71,3 +71,21 @@ func optimizeDefineLoadFast(i *compile.InstSet, ccb *compile.CodeBlockCompiler)
 		curr = curr.Next
 	}
 }
+
+// markTailCalls replaces a CALL followed by RETURN with a TAIL_CALL. The VM
+// reuses the frame of a function for a call in tail position so deep recursion
+// doesn't need a frame for each call. The RETURN is kept for calls the VM can't
+// make in place. Only function bodies are marked so module frames stay in stack traces.
+func markTailCalls(i *compile.InstSet) {
+	for curr := i.Head; curr != nil && curr.Next != nil; curr = curr.Next {
+		if !curr.Next.Is(opcode.Return) {
+			continue
+		}
+
+		if curr.Is(opcode.Call) {
+			curr.Instr = opcode.TailCall
+		} else if curr.Is(opcode.CallKw) {
+			curr.Instr = opcode.TailCallKw
+		}
+	}
+}
@@ -3,6 +3,8 @@ package compiler
 import (
 	"math/rand"
 	"time"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/compile"
 )
 
 const (
@@ -44,3 +46,10 @@ func boolToUint16(b bool) uint16 {
 	}
 	return 0
 }
+
+// mergeLocals appends the locals defined in a sub-block to the outer table. The
+// sub-block's table starts at the end of the outer table so every name is appended,
+// even when it shadows an outer local, to keep the indices the same.
+func mergeLocals(ccb *compile.CodeBlockCompiler, locals *compile.StringTable) {
+	ccb.Locals.Table = append(ccb.Locals.Table, locals.Table[len(ccb.Locals.Table):]...)
+}
@@ -55,7 +55,9 @@ func (cb *CodeBlock) Print(indent string) {
 		offset++
 
 		switch code {
-		case opcode.MakeArray, opcode.MakeMap, opcode.Recover, opcode.BuildClass, opcode.MakeInstance:
+		case opcode.MakeArray, opcode.MakeMap, opcode.Recover, opcode.BuildClass, opcode.MakeInstance, opcode.BuildString,
+			opcode.CallKw, opcode.TailCallKw, opcode.MakeInstanceKw, opcode.ArrayAppend, opcode.MapInsert,
+			opcode.Defer, opcode.DeferKw, opcode.Continue, opcode.Break:
 			fmt.Printf("\t\t%d", bytesToUint16(cb.Code[offset], cb.Code[offset+1]))
 		case opcode.JumpForward:
 			target := int(bytesToUint16(cb.Code[offset], cb.Code[offset+1]))
@@ -65,9 +67,10 @@ func (cb *CodeBlock) Print(indent string) {
 			fmt.Printf("\t%d", target)
 		case opcode.StartLoop:
 			fmt.Printf("\t%d %d", bytesToUint16(cb.Code[offset], cb.Code[offset+1]), bytesToUint16(cb.Code[offset+2], cb.Code[offset+3]))
-		case opcode.PopJumpIfTrue, opcode.PopJumpIfFalse, opcode.JumpIfTrueOrPop, opcode.JumpIfFalseOrPop:
+		case opcode.PopJumpIfTrue, opcode.PopJumpIfFalse, opcode.JumpIfTrueOrPop, opcode.JumpIfFalseOrPop,
+			opcode.JumpIfNil, opcode.JumpNotNilOrPop:
 			fmt.Printf("\t%d", bytesToUint16(cb.Code[offset], cb.Code[offset+1]))
-		case opcode.LoadConst, opcode.Import:
+		case opcode.LoadConst, opcode.Import, opcode.FormatValue:
 			index := bytesToUint16(cb.Code[offset], cb.Code[offset+1])
 			fmt.Printf("\t%d (%s)", index, cb.Constants[index].Inspect())
 		case opcode.LoadFast, opcode.StoreFast, opcode.DeleteFast:
@@ -77,7 +80,7 @@ func (cb *CodeBlock) Print(indent string) {
 			index := bytesToUint16(cb.Code[offset], cb.Code[offset+1])
 			flags := cb.Code[offset+2]
 			fmt.Printf("\t\t%d (%s) (%#.2x)", index, cb.Locals[index], flags)
-		case opcode.Call:
+		case opcode.Call, opcode.TailCall:
 			params := bytesToUint16(cb.Code[offset], cb.Code[offset+1])
 			fmt.Printf("\t\t%d (%d positional parameters)", params, params)
 		case opcode.LoadGlobal, opcode.StoreGlobal, opcode.LoadAttribute, opcode.StoreAttribute:
@@ -85,6 +88,8 @@ func (cb *CodeBlock) Print(indent string) {
 			fmt.Printf("\t%d (%s)", index, cb.Names[index])
 		case opcode.Compare:
 			fmt.Printf("\t%d (%s)", cb.Code[offset], opcode.CmpOps[cb.Code[offset]])
+		case opcode.Match:
+			fmt.Printf("\t%d (%s)", cb.Code[offset], opcode.MatchOps[cb.Code[offset]])
 		}
 
 		switch {
@@ -140,8 +145,8 @@ type CodeBlockCompiler struct {
 	Names          *StringTable   // Identifiers for non-local variables
 	Code           *InstSet
 	Filename, Name string
-	InLoop         bool
-	Linenum        uint // Absolute line number for the first line of this block in the source file
+	Loops          []string // Labels of the loops the code is in, innermost last. Unlabeled loops are empty
+	Linenum        uint     // Absolute line number for the first line of this block in the source file
 }
 
 type ConstantTable struct {
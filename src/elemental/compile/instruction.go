@@ -123,7 +123,11 @@ func (i *InstSet) AddLabeledArgs(code opcode.Opcode, line uint, argLabels ...str
 }
 
 func (i *InstSet) Merge(j *InstSet) {
+	if j.Head == nil {
+		return
+	}
 	i.Tail.Next = j.Head
+	j.Head.Prev = i.Tail
 	i.Tail = j.Tail
 }
 
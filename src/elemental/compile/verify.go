@@ -0,0 +1,643 @@
+package compile
+
+import (
+	"fmt"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm/opcode"
+)
+
+// VerifyError is returned by Verify when a code block is malformed.
+type VerifyError struct {
+	Name     string // Name of the code block that failed
+	Filename string
+	Offset   int // Offset of the offending instruction, -1 if the error isn't tied to one
+	Msg      string
+}
+
+func (e *VerifyError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("invalid bytecode in %s (%s): %s", e.Name, e.Filename, e.Msg)
+	}
+	return fmt.Sprintf("invalid bytecode in %s (%s) at offset %d: %s", e.Name, e.Filename, e.Offset, e.Msg)
+}
+
+type decodedInst struct {
+	offset int
+	code   opcode.Opcode
+	args   []uint16
+}
+
+// Verify checks a code block, and all code blocks in its constant table, can be
+// run safely by the virtual machine. Opcodes, argument widths, jump targets, table
+// indices, the constants functions and classes are built from, and the stack and
+// block sizes are checked. Code blocks read from untrusted
+// sources such as .nib files should be verified before being executed.
+func Verify(cb *CodeBlock) error {
+	v := &verifier{cb: cb}
+	if err := v.verify(); err != nil {
+		return err
+	}
+
+	for _, c := range cb.Constants {
+		if child, ok := c.(*CodeBlock); ok {
+			if err := Verify(child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MaxDepths follows every path through the code of cb and returns the largest stack
+// and block depths it reaches. Code blocks in the constant table aren't checked.
+// An error is returned if the code can't be followed, the stack and block sizes of cb are ignored.
+func MaxDepths(cb *CodeBlock) (stack, block int, err error) {
+	v := &verifier{cb: cb}
+	if err := v.analyze(); err != nil {
+		return 0, 0, err
+	}
+	return v.maxStack, v.maxBlock, nil
+}
+
+type verifier struct {
+	cb      *CodeBlock
+	insts   []decodedInst
+	start   []bool // Marks offsets that begin an instruction
+	index   map[int]int
+	targets map[int]bool // Offsets that can be jumped to
+
+	states     []*flowState // State before each instruction, nil if it hasn't been reached
+	maxStack   int
+	maxStackAt int // Offset of the first instruction reached with the max stack depth
+	maxBlock   int
+	maxBlockAt int
+}
+
+// openBlock is an entry on the block stack.
+type openBlock struct {
+	inst decodedInst // Instruction that opened the block
+	sp   int         // Stack depth when the block was opened
+}
+
+// flowState is the stack and block stack depth before an instruction. Every path to
+// an instruction must arrive with the same depths.
+type flowState struct {
+	sp     int
+	consts []int // Constant index loaded into each stack slot, -1 if it isn't a known constant
+	blocks []openBlock
+}
+
+// with returns a state with stack depth sp and blocks. Slots kept from s keep their
+// constants, new slots are unknown.
+func (s *flowState) with(sp int, blocks []openBlock) *flowState {
+	consts := make([]int, sp)
+	n := copy(consts, s.consts)
+	for i := n; i < sp; i++ {
+		consts[i] = -1
+	}
+	return &flowState{sp: sp, consts: consts, blocks: blocks}
+}
+
+// merge returns s with the slots where o has a different constant marked unknown,
+// or nil if there aren't any. The states must be equal.
+func (s *flowState) merge(o *flowState) *flowState {
+	var merged *flowState
+	for i, c := range s.consts {
+		if c < 0 || c == o.consts[i] {
+			continue
+		}
+		if merged == nil {
+			merged = s.with(s.sp, s.blocks)
+		}
+		merged.consts[i] = -1
+	}
+	return merged
+}
+
+// constant returns the constant depth slots below the top of the stack, or nil if
+// the value isn't a known constant.
+func (v *verifier) constant(s *flowState, depth int) object.Object {
+	if depth >= s.sp || s.consts[s.sp-1-depth] < 0 {
+		return nil
+	}
+	return v.cb.Constants[s.consts[s.sp-1-depth]]
+}
+
+func (s *flowState) equal(o *flowState) bool {
+	if s.sp != o.sp || len(s.blocks) != len(o.blocks) {
+		return false
+	}
+	for i := range s.blocks {
+		if s.blocks[i].inst.offset != o.blocks[i].inst.offset {
+			return false
+		}
+	}
+	return true
+}
+
+// loop returns the index in the block stack of the loop skip loops out from the innermost one, or -1.
+func (s *flowState) loop(skip uint16) int {
+	for i := len(s.blocks) - 1; i >= 0; i-- {
+		if s.blocks[i].inst.code != opcode.StartLoop {
+			continue
+		}
+		if skip == 0 {
+			return i
+		}
+		skip--
+	}
+	return -1
+}
+
+// loops returns the number of open loops.
+func (s *flowState) loops() int {
+	n := 0
+	for _, b := range s.blocks {
+		if b.inst.code == opcode.StartLoop {
+			n++
+		}
+	}
+	return n
+}
+
+func (v *verifier) errorf(offset int, format string, a ...interface{}) *VerifyError {
+	return &VerifyError{
+		Name:     v.cb.Name,
+		Filename: v.cb.Filename,
+		Offset:   offset,
+		Msg:      fmt.Sprintf(format, a...),
+	}
+}
+
+func (v *verifier) verify() error {
+	cb := v.cb
+
+	if err := v.analyze(); err != nil {
+		return err
+	}
+
+	if v.maxStack > cb.MaxStackSize {
+		return v.errorf(v.maxStackAt, "stack depth %d exceeds max stack size %d", v.maxStack, cb.MaxStackSize)
+	}
+	if v.maxBlock > cb.MaxBlockSize {
+		return v.errorf(v.maxBlockAt, "block depth %d exceeds max block size %d", v.maxBlock, cb.MaxBlockSize)
+	}
+
+	return nil
+}
+
+func (v *verifier) analyze() error {
+	cb := v.cb
+
+	if cb.Native {
+		if len(cb.Code) > 0 {
+			return v.errorf(-1, "native code block contains bytecode")
+		}
+		return nil
+	}
+	if len(cb.Code) == 0 {
+		return v.errorf(-1, "code block is empty")
+	}
+	if len(cb.LineOffsets)%2 != 0 {
+		return v.errorf(-1, "line offset table has odd length %d", len(cb.LineOffsets))
+	}
+	if cb.MaxStackSize < 0 || cb.MaxBlockSize < 0 {
+		return v.errorf(-1, "negative stack or block size")
+	}
+
+	if err := v.decode(); err != nil {
+		return err
+	}
+
+	for _, inst := range v.insts {
+		if err := v.checkArgs(inst); err != nil {
+			return err
+		}
+	}
+
+	return v.flow()
+}
+
+// flow follows every path through the code block from the first instruction and
+// tracks the stack and block depth. Each basic block is walked once, the state it
+// leaves with is checked against the state already recorded at its jump targets.
+func (v *verifier) flow() error {
+	v.states = make([]*flowState, len(v.insts))
+	work := []int{0}
+	if _, err := v.enter(0, &flowState{}); err != nil {
+		return err
+	}
+
+	for len(work) > 0 {
+		i := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		for {
+			inst := v.insts[i]
+			edges, next, err := v.step(inst, v.states[i])
+			if err != nil {
+				return err
+			}
+
+			for _, e := range edges {
+				target := v.index[e.offset]
+				changed, err := v.enter(target, e.state)
+				if err != nil {
+					return err
+				}
+				if changed {
+					work = append(work, target)
+				}
+			}
+
+			if next == nil {
+				break
+			}
+			if i+1 == len(v.insts) {
+				return v.errorf(inst.offset, "code block ends with %s, execution would run past the end", inst.code)
+			}
+
+			i++
+			changed, err := v.enter(i, next)
+			if err != nil {
+				return err
+			}
+			if !changed {
+				// Basic block already walked with the same state
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// enter records state as the state before instruction i, or checks it matches the
+// recorded state. It returns true if the recorded state changed and instruction i
+// needs to be walked again.
+func (v *verifier) enter(i int, state *flowState) (bool, error) {
+	if state.sp > v.maxStack {
+		v.maxStack, v.maxStackAt = state.sp, v.insts[i].offset
+	}
+	if len(state.blocks) > v.maxBlock {
+		v.maxBlock, v.maxBlockAt = len(state.blocks), v.insts[i].offset
+	}
+
+	if v.insts[i].code == opcode.EndBlock && state.sp == 0 {
+		// END_BLOCK pushes nil on an empty stack, so arriving with an empty stack
+		// or a single value leaves the same state
+		state = state.with(1, state.blocks)
+	}
+	if v.insts[i].code == opcode.NextIter {
+		// NEXT_ITER resets the stack to the loop's depth, so anything left above it
+		// doesn't carry into the next iteration
+		if l := state.loop(0); l >= 0 && state.sp > state.blocks[l].sp {
+			state = state.with(state.blocks[l].sp, state.blocks)
+		}
+	}
+
+	recorded := v.states[i]
+	if recorded == nil {
+		v.states[i] = state
+		return true, nil
+	}
+	if !recorded.equal(state) {
+		return false, v.errorf(v.insts[i].offset, "paths merge with different stack depths (%d and %d) or block depths (%d and %d)",
+			recorded.sp, state.sp, len(recorded.blocks), len(state.blocks))
+	}
+	if merged := recorded.merge(state); merged != nil {
+		v.states[i] = merged
+		return true, nil
+	}
+	return false, nil
+}
+
+type flowEdge struct {
+	offset int
+	state  *flowState
+}
+
+// step applies inst to state. It returns the states at any jump targets and the state
+// for the next instruction, which is nil if execution can't continue past inst.
+func (v *verifier) step(inst decodedInst, state *flowState) ([]flowEdge, *flowState, error) {
+	var arg uint16
+	if len(inst.args) > 0 {
+		arg = inst.args[0]
+	}
+
+	pops, pushes := stackUse(inst.code, arg, state.sp)
+	if pops > state.sp {
+		return nil, nil, v.errorf(inst.offset, "%s needs %d values on the stack, only %d available", inst.code, pops, state.sp)
+	}
+	if err := v.checkStack(inst, state); err != nil {
+		return nil, nil, err
+	}
+
+	next := state.with(state.sp-pops+pushes, state.blocks)
+	if inst.code == opcode.LoadConst {
+		next.consts[next.sp-1] = int(arg)
+	}
+	with := state.with
+	opened := func() []openBlock {
+		blocks := make([]openBlock, len(state.blocks), len(state.blocks)+1)
+		copy(blocks, state.blocks)
+		return append(blocks, openBlock{inst: inst, sp: state.sp})
+	}
+
+	switch inst.code {
+	case opcode.Return:
+		return nil, nil, nil
+
+	case opcode.JumpAbsolute:
+		return []flowEdge{{int(arg), next}}, nil, nil
+	case opcode.JumpForward:
+		return []flowEdge{{inst.offset + 3 + int(arg), next}}, nil, nil
+
+	case opcode.PopJumpIfTrue, opcode.PopJumpIfFalse, opcode.JumpIfNil:
+		return []flowEdge{{int(arg), next}}, next, nil
+	case opcode.JumpIfTrueOrPop, opcode.JumpIfFalseOrPop, opcode.JumpNotNilOrPop:
+		// The value is only popped if the jump isn't taken
+		return []flowEdge{{int(arg), with(state.sp, state.blocks)}}, with(state.sp-1, state.blocks), nil
+
+	case opcode.StartBlock, opcode.StartWith:
+		next.blocks = opened()
+
+	case opcode.Recover:
+		// An exception restores the stack depth and pushes the exception before jumping to the handler
+		next.blocks = opened()
+		return []flowEdge{{int(arg), with(state.sp+1, next.blocks)}}, next, nil
+
+	case opcode.StartLoop:
+		// Break and continue restore the stack depth when the loop started
+		next.blocks = opened()
+		return []flowEdge{
+			{int(inst.args[0]), with(state.sp, next.blocks)},
+			{int(inst.args[1]), with(state.sp, next.blocks)},
+		}, next, nil
+
+	case opcode.EndBlock:
+		if len(state.blocks) == 0 {
+			return nil, nil, v.errorf(inst.offset, "%s without an open block", inst.code)
+		}
+		next.blocks = state.blocks[:len(state.blocks)-1]
+
+	case opcode.NextIter:
+		l := state.loop(0)
+		if l < 0 {
+			return nil, nil, v.errorf(inst.offset, "%s outside of a loop", inst.code)
+		}
+		return []flowEdge{{v.nextOffset(state.blocks[l].inst), with(state.blocks[l].sp, state.blocks[:l+1])}}, nil, nil
+
+	case opcode.Continue, opcode.Break:
+		l := state.loop(arg)
+		if l < 0 {
+			if n := state.loops(); n > 0 {
+				return nil, nil, v.errorf(inst.offset, "%s depth %d exceeds loop nesting %d", inst.code, arg, n)
+			}
+			return nil, nil, v.errorf(inst.offset, "%s outside of a loop", inst.code)
+		}
+		loop := state.blocks[l]
+		target := int(loop.inst.args[1])
+		if inst.code == opcode.Break {
+			target = int(loop.inst.args[0])
+		}
+		return []flowEdge{{target, with(loop.sp, state.blocks[:l+1])}}, nil, nil
+	}
+
+	return nil, next, nil
+}
+
+// nextOffset returns the offset of the instruction following inst.
+func (v *verifier) nextOffset(inst decodedInst) int {
+	return v.insts[v.index[inst.offset]+1].offset
+}
+
+// stackUse returns how many values code pops from a stack of depth sp and how many it pushes.
+func stackUse(code opcode.Opcode, arg uint16, sp int) (pops, pushes int) {
+	n := int(arg)
+	switch code {
+	case opcode.LoadConst, opcode.LoadFast, opcode.LoadGlobal, opcode.Import:
+		return 0, 1
+	case opcode.StoreFast, opcode.Define, opcode.StoreGlobal, opcode.Pop,
+		opcode.PopJumpIfTrue, opcode.PopJumpIfFalse, opcode.ArrayAppend:
+		return 1, 0
+	case opcode.LoadAttribute, opcode.UnaryNeg, opcode.UnaryNot, opcode.GetIter,
+		opcode.FormatValue, opcode.JumpIfNil, opcode.StartWith,
+		opcode.JumpIfTrueOrPop, opcode.JumpIfFalseOrPop, opcode.JumpNotNilOrPop:
+		return 1, 1
+	case opcode.Dup:
+		return 1, 2
+	case opcode.LoadIndex, opcode.Compare, opcode.Implements,
+		opcode.BinaryAdd, opcode.BinarySub, opcode.BinaryMul, opcode.BinaryDivide,
+		opcode.BinaryMod, opcode.BinaryShiftL, opcode.BinaryShiftR, opcode.BinaryAnd,
+		opcode.BinaryOr, opcode.BinaryNot, opcode.BinaryAndNot:
+		return 2, 1
+	case opcode.StoreAttribute, opcode.MapInsert:
+		return 2, 0
+	case opcode.StoreIndex:
+		return 3, 0
+	case opcode.MakeFunction:
+		return 3, 1
+	case opcode.LoadSlice:
+		return 4, 1
+	case opcode.StoreSlice:
+		return 5, 0
+	case opcode.Call, opcode.TailCall, opcode.MakeInstance:
+		return n + 1, 1
+	case opcode.CallKw, opcode.TailCallKw, opcode.MakeInstanceKw:
+		return n + 2, 1
+	case opcode.Defer:
+		return n + 1, 0
+	case opcode.DeferKw:
+		return n + 2, 0
+	case opcode.MakeArray, opcode.BuildString:
+		return n, 1
+	case opcode.MakeMap:
+		return n * 2, 1
+	case opcode.BuildClass:
+		return n + 3, 1
+	case opcode.Match:
+		switch byte(arg) {
+		case opcode.MatchMap:
+			return 1, 1
+		case opcode.MatchRest:
+			return 3, 1
+		}
+		return 2, 1
+	case opcode.Return:
+		// The return value is optional
+		if sp > 0 {
+			return 1, 0
+		}
+	case opcode.EndBlock:
+		// The block's value is nil if it left nothing on the stack
+		if sp == 0 {
+			return 0, 1
+		}
+	}
+	return 0, 0
+}
+
+// decode splits the bytecode into instructions and checks each opcode and its argument width.
+func (v *verifier) decode() error {
+	code := v.cb.Code
+	v.start = make([]bool, len(code))
+	v.index = make(map[int]int)
+	v.targets = make(map[int]bool)
+
+	offset := 0
+	for offset < len(code) {
+		op := opcode.Opcode(code[offset])
+		if op >= opcode.MaxOpcode {
+			return v.errorf(offset, "invalid opcode %d", code[offset])
+		}
+
+		var width int
+		switch {
+		case opcode.HasNoArg[op]:
+			width = 0
+		case opcode.HasOneByteArg[op]:
+			width = 1
+		case opcode.HasTwoByteArg[op]:
+			width = 2
+		case opcode.HasThreeByteArg[op]:
+			width = 3
+		case opcode.HasFourByteArg[op]:
+			width = 4
+		default:
+			return v.errorf(offset, "opcode %s has no argument width", op)
+		}
+
+		if offset+1+width > len(code) {
+			return v.errorf(offset, "%s expects %d argument bytes, only %d remain", op, width, len(code)-offset-1)
+		}
+
+		inst := decodedInst{offset: offset, code: op}
+		argBytes := code[offset+1 : offset+1+width]
+		switch width {
+		case 1:
+			inst.args = []uint16{uint16(argBytes[0])}
+		case 2, 3: // The third byte is a flag and can hold any value
+			inst.args = []uint16{bytesToUint16(argBytes[0], argBytes[1])}
+		case 4:
+			inst.args = []uint16{bytesToUint16(argBytes[0], argBytes[1]), bytesToUint16(argBytes[2], argBytes[3])}
+		}
+
+		v.start[offset] = true
+		v.index[offset] = len(v.insts)
+		v.insts = append(v.insts, inst)
+		offset += 1 + width
+	}
+	return nil
+}
+
+func (v *verifier) checkArgs(inst decodedInst) error {
+	cb := v.cb
+
+	switch inst.code {
+	case opcode.LoadConst:
+		if int(inst.args[0]) >= len(cb.Constants) {
+			return v.errorf(inst.offset, "%s constant index %d out of range (%d constants)", inst.code, inst.args[0], len(cb.Constants))
+		}
+	case opcode.Import, opcode.FormatValue:
+		if int(inst.args[0]) >= len(cb.Constants) {
+			return v.errorf(inst.offset, "%s constant index %d out of range (%d constants)", inst.code, inst.args[0], len(cb.Constants))
+		}
+		if _, ok := cb.Constants[inst.args[0]].(*object.String); !ok {
+			return v.errorf(inst.offset, "%s constant %d is not a string", inst.code, inst.args[0])
+		}
+	case opcode.LoadFast, opcode.StoreFast, opcode.DeleteFast, opcode.Define:
+		if int(inst.args[0]) >= len(cb.Locals) {
+			return v.errorf(inst.offset, "%s local index %d out of range (%d locals)", inst.code, inst.args[0], len(cb.Locals))
+		}
+	case opcode.LoadGlobal, opcode.StoreGlobal, opcode.LoadAttribute, opcode.StoreAttribute:
+		if int(inst.args[0]) >= len(cb.Names) {
+			return v.errorf(inst.offset, "%s name index %d out of range (%d names)", inst.code, inst.args[0], len(cb.Names))
+		}
+	case opcode.Compare:
+		if byte(inst.args[0]) >= opcode.MaxCmpCodes {
+			return v.errorf(inst.offset, "invalid comparison %d", inst.args[0])
+		}
+	case opcode.Match:
+		if byte(inst.args[0]) >= opcode.MaxMatchCodes {
+			return v.errorf(inst.offset, "invalid match operation %d", inst.args[0])
+		}
+	case opcode.JumpForward:
+		// Relative to the instruction following the jump
+		return v.checkTarget(inst, inst.offset+3+int(inst.args[0]))
+	case opcode.JumpAbsolute, opcode.PopJumpIfTrue, opcode.PopJumpIfFalse,
+		opcode.JumpIfTrueOrPop, opcode.JumpIfFalseOrPop, opcode.JumpIfNil, opcode.JumpNotNilOrPop,
+		opcode.Recover:
+		return v.checkTarget(inst, int(inst.args[0]))
+	case opcode.StartLoop:
+		if err := v.checkTarget(inst, int(inst.args[0])); err != nil {
+			return err
+		}
+		return v.checkTarget(inst, int(inst.args[1]))
+	}
+	return nil
+}
+
+func (v *verifier) checkTarget(inst decodedInst, target int) error {
+	if target >= len(v.cb.Code) {
+		return v.errorf(inst.offset, "%s target %d is outside the code block (length %d)", inst.code, target, len(v.cb.Code))
+	}
+	if !v.start[target] {
+		return v.errorf(inst.offset, "%s target %d is not the start of an instruction", inst.code, target)
+	}
+	v.targets[target] = true
+	return nil
+}
+
+// checkStack checks operands of inst that depend on the state of the stack.
+func (v *verifier) checkStack(inst decodedInst, state *flowState) error {
+	switch inst.code {
+	case opcode.MakeFunction, opcode.BuildClass:
+		// The VM expects the name on top of the stack and the code two slots below it
+		if _, ok := v.constant(state, 0).(*object.String); !ok {
+			return v.errorf(inst.offset, "%s name is not a string constant", inst.code)
+		}
+		if _, ok := v.constant(state, 2).(*CodeBlock); !ok {
+			return v.errorf(inst.offset, "%s code is not a code block constant", inst.code)
+		}
+	case opcode.ArrayAppend, opcode.MapInsert:
+		// The collection is found relative to the top of the stack after the operands are popped
+		pops, _ := stackUse(inst.code, inst.args[0], state.sp)
+		if int(inst.args[0]) >= state.sp-pops {
+			return v.errorf(inst.offset, "%s depth %d is outside the stack (depth %d)", inst.code, inst.args[0], state.sp-pops)
+		}
+	case opcode.Match:
+		switch byte(inst.args[0]) {
+		case opcode.MatchArray, opcode.MatchArrayMin, opcode.MatchFromEnd,
+			opcode.DestructArray, opcode.DestructArrayMin:
+			return v.checkIntOperands(inst, 1)
+		case opcode.MatchRest:
+			return v.checkIntOperands(inst, 2)
+		}
+	}
+	return nil
+}
+
+// checkIntOperands checks the n instructions before inst load non-negative integer
+// constants and can't be jumped past.
+func (v *verifier) checkIntOperands(inst decodedInst, n int) error {
+	i := v.index[inst.offset]
+	for j := i - n; j < i; j++ {
+		if j < 0 {
+			return v.errorf(inst.offset, "%s expects %d integer operands", inst.code, n)
+		}
+		if v.targets[v.insts[j+1].offset] {
+			return v.errorf(inst.offset, "%s integer operands can be jumped past", inst.code)
+		}
+
+		load := v.insts[j]
+		if load.code != opcode.LoadConst {
+			return v.errorf(inst.offset, "%s expects integer operands, got %s", inst.code, load.code)
+		}
+		if c, ok := v.cb.Constants[load.args[0]].(*object.Integer); !ok || c.Value < 0 {
+			return v.errorf(inst.offset, "%s expects non-negative integer operands, got constant %d", inst.code, load.args[0])
+		}
+	}
+	return nil
+}
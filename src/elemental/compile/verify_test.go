@@ -0,0 +1,266 @@
+package compile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm/opcode"
+)
+
+func op(c opcode.Opcode) byte { return c.ToByte() }
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name  string
+		code  []byte
+		stack int
+		block int
+		err   string
+	}{
+		{
+			name:  "valid",
+			code:  []byte{op(opcode.LoadConst), 0, 0, op(opcode.Return)},
+			stack: 1,
+		},
+		{
+			name:  "valid jumps",
+			code:  []byte{op(opcode.LoadConst), 0, 0, op(opcode.PopJumpIfTrue), 0, 9, op(opcode.JumpForward), 0, 0, op(opcode.Return)},
+			stack: 1,
+		},
+		{
+			name: "invalid opcode",
+			code: []byte{op(opcode.MaxOpcode), op(opcode.Return)},
+			err:  "offset 0: invalid opcode",
+		},
+		{
+			name: "truncated argument",
+			code: []byte{op(opcode.Return), op(opcode.LoadConst), 0},
+			err:  "offset 1: LOAD_CONST expects 2 argument bytes, only 1 remain",
+		},
+		{
+			name:  "constant out of range",
+			code:  []byte{op(opcode.LoadConst), 0, 5, op(opcode.Return)},
+			stack: 1,
+			err:   "constant index 5 out of range",
+		},
+		{
+			name: "local out of range",
+			code: []byte{op(opcode.LoadFast), 0, 1, op(opcode.Return)},
+			err:  "local index 1 out of range",
+		},
+		{
+			name:  "name out of range",
+			code:  []byte{op(opcode.LoadGlobal), 0, 1, op(opcode.Return)},
+			stack: 1,
+			err:   "name index 1 out of range",
+		},
+		{
+			name: "jump outside code",
+			code: []byte{op(opcode.JumpAbsolute), 0, 50},
+			err:  "JUMP_ABSOLUTE target 50 is outside the code block",
+		},
+		{
+			name: "jump into arguments",
+			code: []byte{op(opcode.LoadConst), 0, 0, op(opcode.JumpAbsolute), 0, 1},
+			err:  "JUMP_ABSOLUTE target 1 is not the start of an instruction",
+		},
+		{
+			name: "relative jump outside code",
+			code: []byte{op(opcode.JumpForward), 0, 1, op(opcode.Return)},
+			err:  "JUMP_FORWARD target 4 is outside the code block",
+		},
+		{
+			name:  "stack too small",
+			code:  []byte{op(opcode.LoadConst), 0, 0, op(opcode.LoadConst), 0, 0, op(opcode.Return)},
+			stack: 1,
+			err:   "stack depth 2 exceeds max stack size 1",
+		},
+		{
+			name:  "block stack too small",
+			code:  []byte{op(opcode.StartBlock), op(opcode.EndBlock), op(opcode.Return)},
+			stack: 1,
+			err:   "block depth 1 exceeds max block size 0",
+		},
+		{
+			name:  "invalid comparison",
+			code:  []byte{op(opcode.LoadConst), 0, 0, op(opcode.Dup), op(opcode.Compare), 20, op(opcode.Return)},
+			stack: 2,
+			err:   "invalid comparison 20",
+		},
+		{
+			name:  "invalid match operation",
+			code:  []byte{op(opcode.LoadConst), 0, 0, op(opcode.Match), 20, op(opcode.Return)},
+			stack: 1,
+			err:   "invalid match operation 20",
+		},
+		{
+			name:  "format spec not a string",
+			code:  []byte{op(opcode.LoadConst), 0, 0, op(opcode.FormatValue), 0, 0, op(opcode.Return)},
+			stack: 1,
+			err:   "FORMAT_VALUE constant 0 is not a string",
+		},
+		{
+			name:  "runs past end",
+			code:  []byte{op(opcode.LoadConst), 0, 0},
+			stack: 1,
+			err:   "ends with LOAD_CONST",
+		},
+		{
+			name:  "stack grows around a loop",
+			code:  []byte{op(opcode.LoadConst), 0, 0, op(opcode.JumpAbsolute), 0, 0},
+			stack: 2,
+			err:   "paths merge with different stack depths (0 and 1)",
+		},
+		{
+			name: "stack underflow",
+			code: []byte{op(opcode.Pop), op(opcode.Return)},
+			err:  "POP needs 1 values on the stack, only 0 available",
+		},
+		{
+			name:  "end block without a block",
+			code:  []byte{op(opcode.EndBlock), op(opcode.Return)},
+			stack: 1,
+			err:   "END_BLOCK without an open block",
+		},
+		{
+			name:  "valid loop",
+			code:  []byte{op(opcode.StartLoop), 0, 6, 0, 5, op(opcode.NextIter), op(opcode.EndBlock), op(opcode.Return)},
+			stack: 1,
+			block: 1,
+		},
+		{
+			name: "next iter outside a loop",
+			code: []byte{op(opcode.NextIter), op(opcode.Return)},
+			err:  "NEXT_ITER outside of a loop",
+		},
+		{
+			name: "break outside a loop",
+			code: []byte{op(opcode.Break), 0, 0, op(opcode.Return)},
+			err:  "BREAK outside of a loop",
+		},
+		{
+			name:  "break deeper than loops",
+			code:  []byte{op(opcode.StartLoop), 0, 9, 0, 8, op(opcode.Break), 0, 1, op(opcode.NextIter), op(opcode.EndBlock), op(opcode.Return)},
+			stack: 1,
+			block: 1,
+			err:   "BREAK depth 1 exceeds loop nesting 1",
+		},
+		{
+			name:  "continue deeper than loops",
+			code:  []byte{op(opcode.StartLoop), 0, 9, 0, 8, op(opcode.Continue), 0, 3, op(opcode.NextIter), op(opcode.EndBlock), op(opcode.Return)},
+			stack: 1,
+			block: 1,
+			err:   "CONTINUE depth 3 exceeds loop nesting 1",
+		},
+		{
+			name:  "append depth outside the stack",
+			code:  []byte{op(opcode.LoadConst), 0, 0, op(opcode.ArrayAppend), 0, 0, op(opcode.Return)},
+			stack: 1,
+			err:   "ARRAY_APPEND depth 0 is outside the stack (depth 0)",
+		},
+		{
+			name:  "valid match from end",
+			code:  []byte{op(opcode.LoadConst), 0, 0, op(opcode.LoadConst), 0, 1, op(opcode.Match), opcode.MatchFromEnd, op(opcode.Return)},
+			stack: 2,
+		},
+		{
+			name:  "match from end without an integer",
+			code:  []byte{op(opcode.LoadConst), 0, 0, op(opcode.LoadConst), 0, 0, op(opcode.Match), opcode.MatchFromEnd, op(opcode.Return)},
+			stack: 2,
+			err:   "expects non-negative integer operands",
+		},
+		{
+			name:  "valid make function",
+			code:  []byte{op(opcode.LoadConst), 0, 3, op(opcode.MakeArray), 0, 0, op(opcode.LoadConst), 0, 2, op(opcode.MakeFunction), op(opcode.Return)},
+			stack: 3,
+		},
+		{
+			name:  "make function from an integer",
+			code:  []byte{op(opcode.LoadConst), 0, 1, op(opcode.MakeArray), 0, 0, op(opcode.LoadConst), 0, 2, op(opcode.MakeFunction), op(opcode.Return)},
+			stack: 3,
+			err:   "MAKE_FUNCTION code is not a code block constant",
+		},
+		{
+			name:  "make function name not a constant",
+			code:  []byte{op(opcode.LoadConst), 0, 3, op(opcode.MakeArray), 0, 0, op(opcode.LoadGlobal), 0, 0, op(opcode.MakeFunction), op(opcode.Return)},
+			stack: 3,
+			err:   "MAKE_FUNCTION name is not a string constant",
+		},
+		{
+			name:  "valid build class",
+			code:  []byte{op(opcode.LoadConst), 0, 3, op(opcode.LoadConst), 0, 0, op(opcode.LoadConst), 0, 2, op(opcode.BuildClass), 0, 0, op(opcode.Return)},
+			stack: 3,
+		},
+		{
+			name: "build class code differs between paths",
+			code: []byte{
+				op(opcode.LoadConst), 0, 0,
+				op(opcode.PopJumpIfTrue), 0, 12,
+				op(opcode.LoadConst), 0, 3,
+				op(opcode.JumpForward), 0, 3,
+				op(opcode.LoadConst), 0, 1,
+				op(opcode.LoadConst), 0, 0,
+				op(opcode.LoadConst), 0, 2,
+				op(opcode.BuildClass), 0, 0,
+				op(opcode.Return),
+			},
+			stack: 3,
+			err:   "BUILD_CLASS code is not a code block constant",
+		},
+	}
+
+	fn := &CodeBlock{Name: "f", Code: []byte{op(opcode.Return)}}
+	for _, test := range tests {
+		cb := &CodeBlock{
+			Name:         "test",
+			Filename:     "test.ni",
+			Code:         test.code,
+			Constants:    []object.Object{object.TrueConst, object.MakeIntObj(1), object.MakeStringObj("f"), fn},
+			Locals:       []string{"a"},
+			Names:        []string{"b"},
+			MaxStackSize: test.stack,
+			MaxBlockSize: test.block,
+		}
+
+		err := Verify(cb)
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %s", test.name, err)
+			}
+			continue
+		}
+
+		if err == nil {
+			t.Errorf("%s: expected error %q", test.name, test.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error %q, got %q", test.name, test.err, err.Error())
+		}
+	}
+}
+
+func TestVerifyNested(t *testing.T) {
+	inner := &CodeBlock{
+		Name:     "test.inner",
+		Filename: "test.ni",
+		Code:     []byte{op(opcode.LoadFast), 0, 0, op(opcode.Return)},
+	}
+	outer := &CodeBlock{
+		Name:         "test",
+		Filename:     "test.ni",
+		Code:         []byte{op(opcode.LoadConst), 0, 0, op(opcode.Return)},
+		Constants:    []object.Object{inner},
+		MaxStackSize: 1,
+	}
+
+	err := Verify(outer)
+	verr, ok := err.(*VerifyError)
+	if !ok {
+		t.Fatalf("Expected a VerifyError, got %v", err)
+	}
+	if verr.Name != "test.inner" || verr.Offset != 0 {
+		t.Fatalf("Wrong error location %s", verr)
+	}
+}
@@ -62,6 +62,24 @@ func (e *Environment) Clone() *Environment {
 	}
 }
 
+// Copy returns an environment with the same parent and bindings as e. Unlike Clone,
+// changing or adding a binding in the copy doesn't affect e.
+func (e *Environment) Copy() *Environment {
+	c := &Environment{
+		parent:    e.parent,
+		localOnly: e.localOnly,
+	}
+
+	tail := &c.root
+	for obj := e.root; obj != nil; obj = obj.n {
+		n := *obj
+		n.n = nil
+		*tail = &n
+		tail = &n.n
+	}
+	return c
+}
+
 func (e *Environment) SetParent(env *Environment) {
 	e.parent = env
 }
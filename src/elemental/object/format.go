@@ -0,0 +1,242 @@
+package object
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FormatSpec controls how a value is written in a format string. It's parsed
+// from a string in the form [[fill]align][sign][0][width][.precision][type].
+type FormatSpec struct {
+	Fill      rune
+	Align     rune // One of '<', '>', '^' or 0 for the default alignment
+	Sign      rune // One of '+', ' ' or 0 to only show negative signs
+	ZeroPad   bool
+	Width     int
+	Precision int  // -1 if not given
+	Type      rune // 0 if not given
+}
+
+// ParseFormatSpec parses a format spec, an empty spec uses the default formatting.
+func ParseFormatSpec(spec string) (*FormatSpec, error) {
+	f := &FormatSpec{Fill: ' ', Precision: -1}
+	s := []rune(spec)
+	i := 0
+
+	isAlign := func(r rune) bool { return r == '<' || r == '>' || r == '^' }
+	readInt := func() int {
+		n := 0
+		for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+			n = n*10 + int(s[i]-'0')
+		}
+		return n
+	}
+
+	if len(s) > 1 && isAlign(s[1]) {
+		f.Fill = s[0]
+		f.Align = s[1]
+		i = 2
+	} else if len(s) > 0 && isAlign(s[0]) {
+		f.Align = s[0]
+		i = 1
+	}
+
+	if i < len(s) && (s[i] == '+' || s[i] == ' ') {
+		f.Sign = s[i]
+		i++
+	} else if i < len(s) && s[i] == '-' {
+		i++
+	}
+
+	if i < len(s) && s[i] == '0' {
+		f.ZeroPad = true
+		i++
+	}
+
+	f.Width = readInt()
+
+	if i < len(s) && s[i] == '.' {
+		i++
+		if i == len(s) || s[i] < '0' || s[i] > '9' {
+			return nil, fmt.Errorf("format spec %q is missing a precision after '.'", spec)
+		}
+		f.Precision = readInt()
+	}
+
+	if i < len(s) {
+		switch s[i] {
+		case 's', 'd', 'x', 'X', 'o', 'b', 'f', 'e', 'E', '%':
+			f.Type = s[i]
+			i++
+		default:
+			return nil, fmt.Errorf("format spec %q has unknown type %q", spec, s[i])
+		}
+	}
+
+	if i < len(s) {
+		return nil, fmt.Errorf("invalid format spec %q", spec)
+	}
+
+	switch f.Type {
+	case 'd', 'x', 'X', 'o', 'b':
+		if f.Precision >= 0 {
+			return nil, fmt.Errorf("format spec %q can't have a precision with an integer type", spec)
+		}
+	case 's':
+		if f.Sign != 0 || f.ZeroPad {
+			return nil, fmt.Errorf("format spec %q can't have a sign or zero padding with the string type", spec)
+		}
+	}
+
+	return f, nil
+}
+
+// Format converts obj to a string according to the spec. Values that aren't
+// numbers are formatted by their Inspect method.
+func (f *FormatSpec) Format(obj Object) (string, error) {
+	var str string
+	numeric := false
+
+	switch f.Type {
+	case 0:
+		switch obj := obj.(type) {
+		case *Integer:
+			str = f.formatInt(obj.Value, 10, false)
+			numeric = true
+		case *Float:
+			if f.Precision >= 0 {
+				str = f.formatFloat(obj.Value, 'f')
+			} else {
+				digits := obj.Inspect()
+				str = f.sign(!strings.HasPrefix(digits, "-")) + strings.TrimPrefix(digits, "-")
+			}
+			numeric = true
+		default:
+			str = f.truncate(obj.Inspect())
+		}
+
+	case 's':
+		str = f.truncate(obj.Inspect())
+
+	case 'd', 'x', 'X', 'o', 'b':
+		i, ok := obj.(*Integer)
+		if !ok {
+			return "", fmt.Errorf("format type %q requires an integer, got %s", f.Type, obj.Type())
+		}
+		switch f.Type {
+		case 'd':
+			str = f.formatInt(i.Value, 10, false)
+		case 'x':
+			str = f.formatInt(i.Value, 16, false)
+		case 'X':
+			str = f.formatInt(i.Value, 16, true)
+		case 'o':
+			str = f.formatInt(i.Value, 8, false)
+		case 'b':
+			str = f.formatInt(i.Value, 2, false)
+		}
+		numeric = true
+
+	case 'f', 'e', 'E', '%':
+		var v float64
+		switch obj := obj.(type) {
+		case *Integer:
+			v = float64(obj.Value)
+		case *Float:
+			v = obj.Value
+		default:
+			return "", fmt.Errorf("format type %q requires a number, got %s", f.Type, obj.Type())
+		}
+
+		if f.Type == '%' {
+			str = f.formatFloat(v*100, 'f') + "%"
+		} else {
+			str = f.formatFloat(v, byte(f.Type))
+		}
+		numeric = true
+	}
+
+	return f.pad(str, numeric), nil
+}
+
+func (f *FormatSpec) sign(positive bool) string {
+	if !positive {
+		return "-"
+	}
+	if f.Sign != 0 {
+		return string(f.Sign)
+	}
+	return ""
+}
+
+func (f *FormatSpec) formatInt(v int64, base int, upper bool) string {
+	digits := strconv.FormatUint(absInt(v), base)
+	if upper {
+		digits = strings.ToUpper(digits)
+	}
+	return f.sign(v >= 0) + digits
+}
+
+func absInt(v int64) uint64 {
+	if v < 0 {
+		return uint64(-(v + 1)) + 1
+	}
+	return uint64(v)
+}
+
+func (f *FormatSpec) formatFloat(v float64, verb byte) string {
+	prec := f.Precision
+	if prec < 0 {
+		prec = 6
+	}
+	digits := strconv.FormatFloat(v, verb, prec, 64)
+	return f.sign(!strings.HasPrefix(digits, "-")) + strings.TrimPrefix(digits, "-")
+}
+
+func (f *FormatSpec) truncate(s string) string {
+	if f.Precision >= 0 && utf8.RuneCountInString(s) > f.Precision {
+		return string([]rune(s)[:f.Precision])
+	}
+	return s
+}
+
+// pad fills str up to the spec's width. Numbers are aligned right by default and
+// zero padding goes between the sign and digits, everything else is aligned left.
+func (f *FormatSpec) pad(str string, numeric bool) string {
+	n := f.Width - utf8.RuneCountInString(str)
+	if n <= 0 {
+		return str
+	}
+
+	if f.ZeroPad && numeric && f.Align == 0 {
+		sign := ""
+		if str[0] == '-' || str[0] == '+' || str[0] == ' ' {
+			sign, str = str[:1], str[1:]
+		}
+		return sign + strings.Repeat("0", n) + str
+	}
+
+	align := f.Align
+	if align == 0 {
+		align = '<'
+		if numeric {
+			align = '>'
+		}
+	}
+
+	fill := f.Fill
+	if f.ZeroPad && f.Align == 0 {
+		fill = '0'
+	}
+
+	switch align {
+	case '>':
+		return strings.Repeat(string(fill), n) + str
+	case '^':
+		left := n / 2
+		return strings.Repeat(string(fill), left) + str + strings.Repeat(string(fill), n-left)
+	}
+	return str + strings.Repeat(string(fill), n)
+}
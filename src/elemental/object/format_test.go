@@ -0,0 +1,65 @@
+package object
+
+import "testing"
+
+func TestFormatSpec(t *testing.T) {
+	tests := []struct {
+		spec     string
+		value    Object
+		expected string
+		err      string
+	}{
+		{"", MakeStringObj("abc"), "abc", ""},
+		{"", MakeIntObj(42), "42", ""},
+		{"", MakeFloatObj(1.5), "1.5", ""},
+		{"5", MakeStringObj("abc"), "abc  ", ""},
+		{"5", MakeIntObj(42), "   42", ""},
+		{">5", MakeStringObj("abc"), "  abc", ""},
+		{"^7", MakeStringObj("abc"), "  abc  ", ""},
+		{"*<6", MakeStringObj("abc"), "abc***", ""},
+		{".2", MakeStringObj("abcdef"), "ab", ""},
+		{".2", MakeFloatObj(3.14159), "3.14", ""},
+		{"8.3f", MakeFloatObj(-3.14159), "  -3.142", ""},
+		{"08.3f", MakeFloatObj(-3.14159), "-003.142", ""},
+		{"f", MakeIntObj(2), "2.000000", ""},
+		{"+d", MakeIntObj(5), "+5", ""},
+		{" d", MakeIntObj(5), " 5", ""},
+		{"05d", MakeIntObj(-42), "-0042", ""},
+		{"x", MakeIntObj(255), "ff", ""},
+		{"X", MakeIntObj(-255), "-FF", ""},
+		{"08b", MakeIntObj(5), "00000101", ""},
+		{"o", MakeIntObj(8), "10", ""},
+		{".1%", MakeFloatObj(0.256), "25.6%", ""},
+		{".2e", MakeIntObj(12345), "1.23e+04", ""},
+		{"s", MakeIntObj(12), "12", ""},
+		{"d", MakeFloatObj(1.5), "", "format type 'd' requires an integer, got FLOAT"},
+		{"f", MakeStringObj("a"), "", "format type 'f' requires a number, got STRING"},
+		{"q", NullConst, "", `format spec "q" has unknown type 'q'`},
+		{"5.", NullConst, "", `format spec "5." is missing a precision after '.'`},
+		{".2x", NullConst, "", `format spec ".2x" can't have a precision with an integer type`},
+		{"+s", NullConst, "", `format spec "+s" can't have a sign or zero padding with the string type`},
+		{"5d!", NullConst, "", `invalid format spec "5d!"`},
+	}
+
+	for _, tt := range tests {
+		spec, err := ParseFormatSpec(tt.spec)
+		var str string
+		if err == nil {
+			str, err = spec.Format(tt.value)
+		}
+
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%q: expected error %q, got %v", tt.spec, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", tt.spec, err)
+			continue
+		}
+		if str != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.spec, tt.expected, str)
+		}
+	}
+}
@@ -26,7 +26,8 @@ func (vm *VirtualMachine) assignStringIndex(
 		return object.NewException("Invalid string index type %s", index.Type())
 	}
 
-	if in.Value < 0 || in.Value > int64(len(str.Value)-1) {
+	idx, ok := normalizeIndex(in.Value, len(str.Value))
+	if !ok {
 		return object.NewException("Index out of bounds: %s", index.Inspect())
 	}
 
@@ -35,7 +36,7 @@ func (vm *VirtualMachine) assignStringIndex(
 		return object.NewException("Invalid string index value type %s", val.Type())
 	}
 
-	str.Value[in.Value] = replace.Value[0]
+	str.Value[idx] = replace.Value[0]
 	return object.NullConst
 }
 
@@ -48,7 +49,8 @@ func (vm *VirtualMachine) assignByteStringIndex(
 		return object.NewException("Invalid byte string index type %s", index.Type())
 	}
 
-	if in.Value < 0 || in.Value > int64(len(str.Value)-1) {
+	idx, ok := normalizeIndex(in.Value, len(str.Value))
+	if !ok {
 		return object.NewException("Index out of bounds: %s", index.Inspect())
 	}
 
@@ -57,7 +59,7 @@ func (vm *VirtualMachine) assignByteStringIndex(
 		return object.NewException("Invalid byte string index value type %s", val.Type())
 	}
 
-	str.Value[in.Value] = replace.Value[0]
+	str.Value[idx] = replace.Value[0]
 	return object.NullConst
 }
 
@@ -70,14 +72,24 @@ func (vm *VirtualMachine) assignArrayIndex(
 		return object.NewException("Invalid array index type %s", index.Type())
 	}
 
-	if in.Value < 0 || in.Value > int64(len(array.Elements)-1) {
+	idx, ok := normalizeIndex(in.Value, len(array.Elements))
+	if !ok {
 		return object.NewException("Index out of bounds: %s", index.Inspect())
 	}
 
-	array.Elements[in.Value] = val
+	array.Elements[idx] = val
 	return object.NullConst
 }
 
+// normalizeIndex converts a negative index to count from the end of a
+// sequence of length l. The returned bool is false if the index is out of bounds.
+func normalizeIndex(idx int64, l int) (int64, bool) {
+	if idx < 0 {
+		idx += int64(l)
+	}
+	return idx, idx >= 0 && idx < int64(l)
+}
+
 func (vm *VirtualMachine) assignHashMapIndex(
 	hashmap *object.Hash,
 	index, val object.Object) object.Object {
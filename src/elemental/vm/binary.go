@@ -88,7 +88,9 @@ func (vm *VirtualMachine) evalStringBinaryExpression(op string, left, right obje
 	rightVal := right.(*object.String).Value
 
 	if op == "+" {
-		return &object.String{Value: append(leftVal, rightVal...)}
+		// Always copy, appending to leftVal could overwrite a string sharing its array
+		val := make([]rune, 0, len(leftVal)+len(rightVal))
+		return &object.String{Value: append(append(val, leftVal...), rightVal...)}
 	}
 
 	return object.NewException("unknown operator: %s %s %s", left.Type(), op, right.Type())
@@ -99,7 +101,8 @@ func (vm *VirtualMachine) evalByteStringBinaryExpression(op string, left, right
 	rightVal := right.(*object.ByteString).Value
 
 	if op == "+" {
-		return &object.ByteString{Value: append(leftVal, rightVal...)}
+		val := make([]byte, 0, len(leftVal)+len(rightVal))
+		return &object.ByteString{Value: append(append(val, leftVal...), rightVal...)}
 	}
 
 	return object.NewException("unknown operator: %s %s %s", left.Type(), op, right.Type())
@@ -10,98 +10,60 @@ import (
 	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
 )
 
-var (
-	builtins      = map[string]*object.Builtin{}
-	modules       = map[string]*object.Module{}
-	nativeFn      = map[string]*object.Builtin{}
-	nativeMethods = map[string]*BuiltinMethod{}
-	identRegex    = regexp.MustCompile(`[a-zA-Z_][a-zA-Z0-9_]*`)
-)
+var identRegex = regexp.MustCompile(`[a-zA-Z_][a-zA-Z0-9_]*`)
 
 // RegisterBuiltin allows other packages to register functions for availability in user code
 func RegisterBuiltin(name string, fn object.BuiltinFunction) {
-	if !validBuiltinIdent(name) {
-		panic("Invalid VM builtin function name " + name)
-	}
-
-	if _, defined := builtins[name]; defined {
+	if DefaultRegistry.defined(registryKey{regBuiltin, name}) {
 		// Panic because this should NEVER happen when built
 		panic("Builtin VM function " + name + " already defined")
 	}
 
-	builtins[name] = &object.Builtin{Fn: fn}
+	DefaultRegistry.RegisterBuiltin(name, fn)
 }
 
 func RegisterModule(name string, m *object.Module) {
-	for k := range m.Methods {
-		if !validBuiltinIdent(k) {
-			panic("Invalid VM module function name " + name)
-		}
-	}
-
-	if _, defined := modules[name]; defined {
+	if DefaultRegistry.defined(registryKey{regModule, name}) {
 		// Panic because this should NEVER happen when built
 		panic("VM module " + name + " already defined")
 	}
 
-	modules[name] = m
+	DefaultRegistry.RegisterModule(name, m)
 }
 
 func RegisterNative(name string, fn object.BuiltinFunction) {
-	if _, defined := nativeFn[name]; defined {
+	if DefaultRegistry.defined(registryKey{regNative, name}) {
 		// Panic because this should NEVER happen when built
 		panic("VM native func " + name + " already defined")
 	}
 
-	nativeFn[name] = &object.Builtin{Fn: fn}
+	DefaultRegistry.RegisterNative(name, fn)
 }
 
 func RegisterNativeMethod(name string, fn BuiltinMethodFunction, params int) {
-	if _, defined := nativeMethods[name]; defined {
+	if DefaultRegistry.defined(registryKey{regNativeMethod, name}) {
 		// Panic because this should NEVER happen when built
 		panic("VM native method " + name + " already defined")
 	}
 
-	nativeMethods[name] = &BuiltinMethod{
-		Name:        name[strings.LastIndex(name, ".")+1:],
-		Fn:          fn,
-		NumOfParams: params,
-	}
+	DefaultRegistry.RegisterNativeMethod(name, fn, params)
 }
 
 func validBuiltinIdent(ident string) bool {
 	return identRegex.Match([]byte(ident))
 }
 
-func getBuiltin(name string) object.Object {
-	if builtin, defined := builtins[name]; defined {
-		return builtin
-	}
-	return nil
-}
-
-// GetModule returns a Module object is a module with the given name is registered, otherwise nil.
+// GetModule returns a Module object is a module with the given name is registered
+// in the default registry, otherwise nil.
 func GetModule(name string) *object.Module {
-	modulePath := strings.Split(name, "/")
-	module, defined := modules[modulePath[0]]
-	if !defined {
-		return nil
-	}
-
-	for _, p := range modulePath[1:] {
-		obj, exists := module.Vars[p]
-		if !exists || obj.Type() != object.ModuleObj {
-			return nil
-		}
-		module = obj.(*object.Module)
-	}
-
-	return module
+	return DefaultRegistry.Module(name)
 }
 
 type VMFunction struct {
 	Name       string
 	Parameters []string
+	Defaults   []object.Object // Default values of Parameters, nil if a parameter doesn't have one
+	Rest       string          // Parameter collecting extra arguments, empty if there isn't one
 	Native     bool
 	Body       *compile.CodeBlock
 	Env        *object.Environment
@@ -111,11 +73,23 @@ type VMFunction struct {
 func (f *VMFunction) Inspect() string {
 	var out bytes.Buffer
 
+	params := make([]string, len(f.Parameters))
+	for i, p := range f.Parameters {
+		if f.Defaults != nil && f.Defaults[i] != nil {
+			params[i] = p + " = " + f.Defaults[i].Inspect()
+		} else {
+			params[i] = p
+		}
+	}
+	if f.Rest != "" {
+		params = append(params, "..."+f.Rest)
+	}
+
 	out.WriteString("func")
 	out.WriteByte(' ')
 	out.WriteString(f.Name)
 	out.WriteByte('(')
-	out.WriteString(strings.Join(f.Parameters, ", "))
+	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {...}")
 
 	return out.String()
@@ -76,8 +76,10 @@ func (vm *VirtualMachine) evalImplementsExpression(left, right object.Object) ob
 		for _, method := range iface.Methods {
 			var m object.ClassMethod
 
-			m, exists := nativeMethods[class.Name+"."+method.Name]
-			if !exists {
+			if native := vm.registry.NativeMethod(class.Name + "." + method.Name); native != nil {
+				m = native
+			} else {
+				var exists bool
 				m, exists = class.Methods[method.Name]
 				if !exists {
 					return object.FalseConst
@@ -65,6 +65,9 @@ func debugExecCmd(input string, vm *VirtualMachine) bool {
 	case "frames":
 		vm.callStack.forEach(func(f *Frame) {
 			fmt.Fprintf(vm.GetStdout(), "** %s:%d in module %s\n", f.code.Filename, f.lineno(), f.module)
+			if f.tailCalls > 0 {
+				fmt.Fprintf(vm.GetStdout(), "** ... tail calls elided: %d\n", f.tailCalls)
+			}
 		})
 	case "env":
 		vm.currentFrame.env.Print("  ")
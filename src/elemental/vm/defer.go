@@ -0,0 +1,100 @@
+package vm
+
+import "github.com/nitrogen-lang/nitrogen/src/elemental/object"
+
+// deferCall records a call to fn with argc arguments from the stack. It runs
+// when the current frame returns or an exception unwinds through it.
+func (vm *VirtualMachine) deferCall(argc uint16, names []string, fn object.Object) {
+	args := make([]object.Object, argc)
+	for i := range args {
+		args[i] = vm.currentFrame.popStack()
+	}
+
+	call := &deferredCall{fn: fn, args: args, names: names}
+	if this, exists := vm.currentFrame.env.GetLocal("this"); exists {
+		call.this, _ = this.(*VMInstance)
+	}
+	vm.currentFrame.deferred = append(vm.currentFrame.deferred, call)
+}
+
+// startWith starts a with block for the resource on the stack, the resource is
+// left on the stack to be bound to a name. Its _exit method is called when the
+// block ends, or close if it doesn't have one.
+func (vm *VirtualMachine) startWith() object.Object {
+	resource := vm.currentFrame.getFrontStack()
+
+	var exit *BoundMethod
+	if instance, ok := resource.(*VMInstance); ok {
+		exit = instance.GetBoundMethod("_exit")
+		if exit == nil {
+			exit = instance.GetBoundMethod("close")
+		}
+	}
+	if exit == nil {
+		return object.NewException("with requires an instance with a _exit or close method, got %s", resource.Type())
+	}
+
+	vm.currentFrame.pushBlock(&withBlock{exit: exit})
+	vm.currentFrame.env = object.NewEnclosedEnv(vm.currentFrame.env)
+	return nil
+}
+
+// exitBlocks closes the resources of the with blocks in blocks, starting from
+// the end. All resources are closed even if one throws, the first exception is returned.
+func (vm *VirtualMachine) exitBlocks(blocks []block) *object.Exception {
+	var exc *object.Exception
+	for i := len(blocks) - 1; i >= 0; i-- {
+		wb, ok := blocks[i].(*withBlock)
+		if !ok {
+			continue
+		}
+		if ex, ok := vm.callDetached(wb.exit, nil, nil, nil).(*object.Exception); ok && exc == nil {
+			exc = ex
+		}
+	}
+	return exc
+}
+
+// unwindBlocks pops blocks from the current frame until a block of type bt,
+// skipping the first skip blocks of that type. The resources of with blocks
+// popped along the way are closed. If there aren't enough blocks of type bt
+// nothing is popped and the returned block is nil.
+func (vm *VirtualMachine) unwindBlocks(bt blockType, skip uint16) (block, *object.Exception) {
+	f := vm.currentFrame
+	i := f.bp - 1
+	for ; i >= 0; i-- {
+		if f.blockStack[i].blockType() != bt {
+			continue
+		}
+		if skip == 0 {
+			break
+		}
+		skip--
+	}
+	if i < 0 {
+		return nil, nil
+	}
+
+	bp := f.bp
+	f.bp = i + 1
+	return f.blockStack[i], vm.exitBlocks(f.blockStack[f.bp:bp])
+}
+
+// leaveFrame closes the resources of any with blocks still open in the current
+// frame, then runs its deferred calls in reverse order. All of them run even if
+// one throws, the first exception is returned.
+func (vm *VirtualMachine) leaveFrame() *object.Exception {
+	f := vm.currentFrame
+	exc := vm.exitBlocks(f.blockStack[:f.bp])
+	f.bp = 0
+
+	for len(f.deferred) > 0 {
+		call := f.deferred[len(f.deferred)-1]
+		f.deferred = f.deferred[:len(f.deferred)-1]
+
+		if ex, ok := vm.callDetached(call.fn, call.names, call.this, call.args).(*object.Exception); ok && exc == nil {
+			exc = ex
+		}
+	}
+	return exc
+}
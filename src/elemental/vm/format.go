@@ -0,0 +1,53 @@
+package vm
+
+import (
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+)
+
+// formatValue converts obj to a string for a format string. Instances with a
+// toString method are converted by calling it before the spec is applied.
+func (vm *VirtualMachine) formatValue(obj object.Object, spec string) object.Object {
+	if instance, ok := obj.(*VMInstance); ok {
+		if toString := instance.GetBoundMethod("toString"); toString != nil {
+			vm.CallFunction(0, toString, true, nil, false)
+			obj = vm.currentFrame.popStack()
+		}
+	}
+
+	if spec == "" {
+		if str, ok := obj.(*object.String); ok {
+			return str
+		}
+	}
+
+	f, err := object.ParseFormatSpec(spec)
+	if err != nil {
+		return object.NewException("%s", err)
+	}
+	str, err := f.Format(obj)
+	if err != nil {
+		return object.NewException("%s", err)
+	}
+	return object.MakeStringObj(str)
+}
+
+// buildString concatenates the top n strings on the stack.
+func (vm *VirtualMachine) buildString(n uint16) object.Object {
+	parts := make([][]rune, n)
+	length := 0
+	for i := n; i > 0; i-- {
+		obj := vm.currentFrame.popStack()
+		if str, ok := obj.(*object.String); ok {
+			parts[i-1] = str.Value
+		} else {
+			parts[i-1] = []rune(obj.Inspect())
+		}
+		length += len(parts[i-1])
+	}
+
+	value := make([]rune, 0, length)
+	for _, part := range parts {
+		value = append(value, part...)
+	}
+	return object.MakeStringObjRunes(value)
+}
@@ -8,8 +8,6 @@ import (
 	"github.com/nitrogen-lang/nitrogen/src/moduleutils"
 )
 
-var included = make(map[string]object.Object)
-
 func pathToName(path string) string {
 	path = strings.Replace(path, "/", ".", -1)
 	path = strings.Replace(path, "\\", ".", -1)
@@ -21,7 +19,7 @@ func pathToName(path string) string {
 }
 
 func (vm *VirtualMachine) importPackage(path string) {
-	mod := GetModule(path)
+	mod := vm.registry.Module(path)
 	if mod != nil {
 		vm.currentFrame.pushStack(mod)
 		return
@@ -64,8 +62,7 @@ func (vm *VirtualMachine) importPackage(path string) {
 }
 
 func importScriptFile(vm *VirtualMachine, scriptPath, name string) object.Object {
-	res, imported := included[scriptPath]
-	if imported {
+	if res, imported := vm.registry.importedScript(scriptPath); imported {
 		return res
 	}
 
@@ -86,11 +83,11 @@ func importScriptFile(vm *VirtualMachine, scriptPath, name string) object.Object
 		return ret
 	}
 
-	res = &object.Module{
+	res := &object.Module{
 		Name: name,
 		Vars: env.GetExported(),
 	}
-	included[scriptPath] = res
+	vm.registry.setImportedScript(scriptPath, res)
 	return res
 }
 
@@ -0,0 +1,103 @@
+package vm
+
+import (
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm/opcode"
+)
+
+// evalMatch runs a single step of a match pattern. Checks return a boolean,
+// MatchFromEnd and MatchRest return elements of an array already checked by MatchArrayMin.
+// The destructuring operations return an exception if the value has the wrong shape.
+func (vm *VirtualMachine) evalMatch(op byte) object.Object {
+	switch op {
+	case opcode.MatchArray, opcode.MatchArrayMin:
+		n := vm.currentFrame.popStack().(*object.Integer).Value
+		arr, ok := vm.currentFrame.popStack().(*object.Array)
+		if !ok {
+			return object.FalseConst
+		}
+		if op == opcode.MatchArrayMin {
+			return object.NativeBoolToBooleanObj(int64(len(arr.Elements)) >= n)
+		}
+		return object.NativeBoolToBooleanObj(int64(len(arr.Elements)) == n)
+
+	case opcode.MatchMap:
+		return object.NativeBoolToBooleanObj(object.ObjectIs(vm.currentFrame.popStack(), object.HashObj))
+
+	case opcode.MatchKey:
+		key, ok := vm.currentFrame.popStack().(object.Hashable)
+		hash, isHash := vm.currentFrame.popStack().(*object.Hash)
+		if !ok || !isHash {
+			return object.FalseConst
+		}
+		_, exists := hash.Pairs[key.HashKey()]
+		return object.NativeBoolToBooleanObj(exists)
+
+	case opcode.MatchInstance:
+		class := vm.currentFrame.popStack()
+		instance, ok := vm.currentFrame.popStack().(*VMInstance)
+		if !ok {
+			return object.FalseConst
+		}
+
+		switch class := class.(type) {
+		case *VMClass:
+			return object.NativeBoolToBooleanObj(InstanceOf(class.Name, instance))
+		case *BuiltinClass:
+			return object.NativeBoolToBooleanObj(InstanceOf(class.Name, instance))
+		}
+		return object.NewException("Class pattern requires a class, got %s", class.Type())
+
+	case opcode.MatchFromEnd:
+		n := vm.currentFrame.popStack().(*object.Integer).Value
+		arr := vm.currentFrame.popStack().(*object.Array)
+		return arr.Elements[int64(len(arr.Elements))-n]
+
+	case opcode.MatchRest:
+		tail := vm.currentFrame.popStack().(*object.Integer).Value
+		start := vm.currentFrame.popStack().(*object.Integer).Value
+		arr := vm.currentFrame.popStack().(*object.Array)
+
+		elements := arr.Elements[start : int64(len(arr.Elements))-tail]
+		rest := make([]object.Object, len(elements))
+		copy(rest, elements)
+		return &object.Array{Elements: rest}
+
+	case opcode.DestructArray, opcode.DestructArrayMin:
+		n := vm.currentFrame.popStack().(*object.Integer).Value
+		value := vm.currentFrame.popStack()
+		arr, ok := value.(*object.Array)
+		if !ok {
+			return object.NewException("Can't destructure %s as an array", value.Type())
+		}
+
+		l := int64(len(arr.Elements))
+		if op == opcode.DestructArrayMin && l < n {
+			return object.NewException("Can't destructure array of length %d, expected at least %d elements", l, n)
+		}
+		if op == opcode.DestructArray && l != n {
+			return object.NewException("Can't destructure array of length %d, expected %d elements", l, n)
+		}
+		return arr
+
+	case opcode.DestructKey:
+		key := vm.currentFrame.popStack()
+		value := vm.currentFrame.popStack()
+		hash, ok := value.(*object.Hash)
+		if !ok {
+			return object.NewException("Can't destructure %s as a map", value.Type())
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return object.NewException("Invalid map key: %s", key.Type())
+		}
+		pair, exists := hash.Pairs[hashKey.HashKey()]
+		if !exists {
+			return object.NewException("Can't destructure map, key %s doesn't exist", key.Inspect())
+		}
+		return pair.Value
+	}
+
+	return object.NewPanic("Invalid match operation %x", op)
+}
@@ -46,8 +46,9 @@ When adding a new opcode, make sure to check and make any needed changes to the
 
 - Add the opcode to the appropiate arg count map below.
 - Add a string representation of the opcode below.
-- If the opcode changes the stack in any way, edit the calculateStackSize function in the compiler.
-- If the opcode changes the block stack in any way, edit the calculateBlockSize function in the compiler.
+- If the opcode changes the stack in any way, edit the StackEffect function below.
+- If the opcode changes the block stack in any way, edit the BlockEffect function below.
+- If the opcode takes a jump target or table index, edit the bytecode verifier in the compile package.
 - If the opcode takes any arguments, edit the compiler.CodeBlock.Print() method to print the correct output.
 - And obviously, implement it in the virtual machine.
 */
@@ -104,6 +105,22 @@ const (
 	Dup
 	GetIter
 	Breakpoint
+	Match
+	FormatValue
+	BuildString
+	CallKw
+	MakeInstanceKw
+	JumpIfNil
+	JumpNotNilOrPop
+	LoadSlice
+	StoreSlice
+	ArrayAppend
+	MapInsert
+	Defer
+	DeferKw
+	StartWith
+	TailCall
+	TailCallKw
 
 	MaxOpcode // Not a real opcode, just used to denote the maximum value of a valid opcode
 	Label
@@ -119,6 +136,23 @@ const (
 	MaxCmpCodes
 )
 
+// Argument to the MATCH opcode, the comment shows the stack before and after.
+const (
+	MatchArray    byte = iota // [value, n] -> [value is an array with n elements]
+	MatchArrayMin             // [value, n] -> [value is an array with at least n elements]
+	MatchMap                  // [value] -> [value is a map]
+	MatchKey                  // [value, key] -> [value is a map containing key]
+	MatchInstance             // [value, class] -> [value is an instance of class or a child class]
+	MatchFromEnd              // [array, n] -> [element n from the end of array]
+	MatchRest                 // [array, start, tail] -> [array elements from start up to the last tail elements]
+
+	// Destructuring raises an exception instead of returning false
+	DestructArray    // [value, n] -> [value], value must be an array with n elements
+	DestructArrayMin // [value, n] -> [value], value must be an array with at least n elements
+	DestructKey      // [value, key] -> [value[key]], value must be a map containing key
+	MaxMatchCodes
+)
+
 // 2 16-bit arguments
 var HasFourByteArg = map[Opcode]bool{
 	StartLoop: true,
@@ -152,17 +186,35 @@ var HasTwoByteArg = map[Opcode]bool{
 	BuildClass:       true,
 	MakeInstance:     true,
 	Import:           true,
+	FormatValue:      true,
+	BuildString:      true,
+	CallKw:           true,
+	MakeInstanceKw:   true,
+	JumpIfNil:        true,
+	JumpNotNilOrPop:  true,
+	ArrayAppend:      true,
+	MapInsert:        true,
+	Defer:            true,
+	DeferKw:          true,
+	Continue:         true,
+	Break:            true,
+	TailCall:         true,
+	TailCallKw:       true,
 }
 
 // 1 8-bit argument
 var HasOneByteArg = map[Opcode]bool{
 	Compare: true,
+	Match:   true,
 }
 
 var HasNoArg = map[Opcode]bool{
 	Noop:         true,
 	LoadIndex:    true,
 	StoreIndex:   true,
+	LoadSlice:    true,
+	StoreSlice:   true,
+	StartWith:    true,
 	BinaryAdd:    true,
 	BinarySub:    true,
 	BinaryMul:    true,
@@ -182,9 +234,7 @@ var HasNoArg = map[Opcode]bool{
 	MakeFunction: true,
 	StartBlock:   true,
 	EndBlock:     true,
-	Continue:     true,
 	NextIter:     true,
-	Break:        true,
 	Dup:          true,
 	GetIter:      true,
 	Breakpoint:   true,
@@ -243,6 +293,89 @@ var Names = map[Opcode]string{
 	Dup:              "DUP",
 	GetIter:          "GET_ITER",
 	Breakpoint:       "BREAKPOINT",
+	Match:            "MATCH",
+	FormatValue:      "FORMAT_VALUE",
+	BuildString:      "BUILD_STRING",
+	CallKw:           "CALL_KW",
+	MakeInstanceKw:   "MAKE_INSTANCE_KW",
+	JumpIfNil:        "JUMP_IF_NIL",
+	JumpNotNilOrPop:  "JUMP_NOT_NIL_OR_POP",
+	LoadSlice:        "LOAD_SLICE",
+	StoreSlice:       "STORE_SLICE",
+	ArrayAppend:      "ARRAY_APPEND",
+	MapInsert:        "MAP_INSERT",
+	Defer:            "DEFER",
+	DeferKw:          "DEFER_KW",
+	StartWith:        "START_WITH",
+	TailCall:         "TAIL_CALL",
+	TailCallKw:       "TAIL_CALL_KW",
+}
+
+// StackEffect returns the change in stack size after code is executed with
+// the first argument arg. It's used to calculate the maximum stack size of a code block,
+// branches are not taken into account.
+func StackEffect(code Opcode, arg uint16) int {
+	switch code {
+	case LoadConst, LoadFast, LoadGlobal, Import, Dup:
+		return 1
+	case StoreIndex, LoadSlice:
+		return -3
+	case StoreSlice:
+		return -5
+	case BinaryAdd, BinarySub, BinaryMul, BinaryDivide, BinaryMod, BinaryShiftL,
+		BinaryShiftR, BinaryAnd, BinaryOr, BinaryNot, BinaryAndNot,
+		StoreFast, Define, StoreGlobal, LoadIndex, Compare,
+		Return, Pop, PopJumpIfTrue, PopJumpIfFalse, Implements, ArrayAppend:
+		return -1
+	case Call, TailCall:
+		return -int(arg)
+	case CallKw, TailCallKw, Defer:
+		return -(int(arg) + 1)
+	case DeferKw:
+		return -(int(arg) + 2)
+	case MakeArray, BuildString:
+		return -(int(arg) - 1)
+	case BuildClass:
+		return -(int(arg) + 2)
+	case MakeMap:
+		return -(int(arg)*2 - 1)
+	case MakeFunction, StoreAttribute, MapInsert:
+		return -2
+	case Match:
+		switch byte(arg) {
+		case MatchMap:
+			return 0
+		case MatchRest:
+			return -2
+		}
+		return -1
+	}
+	return 0
+}
+
+// BlockEffect returns the change in block stack size after code is executed.
+func BlockEffect(code Opcode) int {
+	switch code {
+	case StartBlock, StartLoop, Recover, StartWith:
+		return 1
+	case EndBlock:
+		return -1
+	}
+	return 0
+}
+
+var MatchOps = map[byte]string{
+	MatchArray:    "array",
+	MatchArrayMin: "array_min",
+	MatchMap:      "map",
+	MatchKey:      "key",
+	MatchInstance: "instance",
+	MatchFromEnd:  "from_end",
+	MatchRest:     "rest",
+
+	DestructArray:    "destruct_array",
+	DestructArrayMin: "destruct_array_min",
+	DestructKey:      "destruct_key",
 }
 
 var CmpOps = map[byte]string{
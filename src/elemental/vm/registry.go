@@ -0,0 +1,198 @@
+package vm
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+)
+
+type registryKind int
+
+const (
+	regBuiltin registryKind = iota
+	regModule
+	regNative
+	regNativeMethod
+)
+
+type registryKey struct {
+	kind registryKind
+	name string
+}
+
+// DefaultRegistry contains all natives registered by the package level Register functions.
+// It's used by a VirtualMachine unless a different registry is set.
+var DefaultRegistry = NewRegistry(nil)
+
+// A Registry holds the native functions, native methods, builtins and modules available
+// to a VirtualMachine. A registry with a parent falls back to the parent for anything
+// it doesn't define itself, so a host can add, override or remove natives for a single
+// VM without affecting any other.
+type Registry struct {
+	parent  *Registry
+	entries map[registryKey]object.Object
+	removed map[registryKey]bool
+
+	// scripts caches imported script modules. Natives are resolved when a module is
+	// imported so the cache belongs to the registry they were resolved from.
+	scripts     map[string]object.Object
+	scriptsLock sync.Mutex
+}
+
+// NewRegistry creates an empty registry. If parent isn't nil, lookups that fail in the new
+// registry will continue in parent.
+func NewRegistry(parent *Registry) *Registry {
+	return &Registry{
+		parent:  parent,
+		entries: make(map[registryKey]object.Object),
+		removed: make(map[registryKey]bool),
+		scripts: make(map[string]object.Object),
+	}
+}
+
+// Parent returns the registry used as a fallback, or nil.
+func (r *Registry) Parent() *Registry { return r.parent }
+
+func (r *Registry) set(key registryKey, obj object.Object) {
+	delete(r.removed, key)
+	r.entries[key] = obj
+}
+
+func (r *Registry) remove(key registryKey) {
+	delete(r.entries, key)
+	if r.parent != nil {
+		r.removed[key] = true
+	}
+}
+
+func (r *Registry) get(key registryKey) (object.Object, bool) {
+	for reg := r; reg != nil; reg = reg.parent {
+		if obj, ok := reg.entries[key]; ok {
+			return obj, true
+		}
+		if reg.removed[key] {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// RegisterNative adds or replaces the native function name. Natives are bound to the
+// functions declared with the native keyword in a script, ex. std.os.env.
+func (r *Registry) RegisterNative(name string, fn object.BuiltinFunction) {
+	r.set(registryKey{regNative, name}, &object.Builtin{Fn: fn})
+}
+
+// RegisterNativeMethod adds or replaces the native class method name, ex. std.string.String.contains.
+func (r *Registry) RegisterNativeMethod(name string, fn BuiltinMethodFunction, params int) {
+	r.set(registryKey{regNativeMethod, name}, &BuiltinMethod{
+		Name:        name[strings.LastIndex(name, ".")+1:],
+		Fn:          fn,
+		NumOfParams: params,
+	})
+}
+
+// RegisterBuiltin adds or replaces the global builtin function name.
+func (r *Registry) RegisterBuiltin(name string, fn object.BuiltinFunction) {
+	if !validBuiltinIdent(name) {
+		panic("Invalid VM builtin function name " + name)
+	}
+	r.set(registryKey{regBuiltin, name}, &object.Builtin{Fn: fn})
+}
+
+// RegisterModule adds or replaces the module name.
+func (r *Registry) RegisterModule(name string, m *object.Module) {
+	for k := range m.Methods {
+		if !validBuiltinIdent(k) {
+			panic("Invalid VM module function name " + name)
+		}
+	}
+	r.set(registryKey{regModule, name}, m)
+}
+
+// RemoveNative hides the native function name, including one defined by a parent registry.
+func (r *Registry) RemoveNative(name string) { r.remove(registryKey{regNative, name}) }
+
+// RemoveNativeMethod hides the native method name, including one defined by a parent registry.
+func (r *Registry) RemoveNativeMethod(name string) { r.remove(registryKey{regNativeMethod, name}) }
+
+// RemoveBuiltin hides the builtin name, including one defined by a parent registry.
+func (r *Registry) RemoveBuiltin(name string) { r.remove(registryKey{regBuiltin, name}) }
+
+// RemoveModule hides the module name, including one defined by a parent registry.
+func (r *Registry) RemoveModule(name string) { r.remove(registryKey{regModule, name}) }
+
+// Native returns the native function name or nil.
+func (r *Registry) Native(name string) *object.Builtin {
+	if obj, ok := r.get(registryKey{regNative, name}); ok {
+		return obj.(*object.Builtin)
+	}
+	return nil
+}
+
+// NativeMethod returns the native method name or nil.
+func (r *Registry) NativeMethod(name string) *BuiltinMethod {
+	if obj, ok := r.get(registryKey{regNativeMethod, name}); ok {
+		return obj.(*BuiltinMethod)
+	}
+	return nil
+}
+
+// Builtin returns the builtin function name or nil.
+func (r *Registry) Builtin(name string) *object.Builtin {
+	if obj, ok := r.get(registryKey{regBuiltin, name}); ok {
+		return obj.(*object.Builtin)
+	}
+	return nil
+}
+
+// Module returns a Module object if a module with the given name is registered, otherwise nil.
+// Submodules are separated with a slash, ex. foo/bar.
+func (r *Registry) Module(name string) *object.Module {
+	modulePath := strings.Split(name, "/")
+	obj, defined := r.get(registryKey{regModule, modulePath[0]})
+	if !defined {
+		return nil
+	}
+	module := obj.(*object.Module)
+
+	for _, p := range modulePath[1:] {
+		obj, exists := module.Vars[p]
+		if !exists || obj.Type() != object.ModuleObj {
+			return nil
+		}
+		module = obj.(*object.Module)
+	}
+
+	return module
+}
+
+// Merge copies everything defined directly in other into r, replacing existing entries.
+// Removals in other are applied to r as well.
+func (r *Registry) Merge(other *Registry) {
+	for key, obj := range other.entries {
+		r.set(key, obj)
+	}
+	for key := range other.removed {
+		r.remove(key)
+	}
+}
+
+func (r *Registry) importedScript(path string) (object.Object, bool) {
+	r.scriptsLock.Lock()
+	defer r.scriptsLock.Unlock()
+	mod, ok := r.scripts[path]
+	return mod, ok
+}
+
+func (r *Registry) setImportedScript(path string, mod object.Object) {
+	r.scriptsLock.Lock()
+	r.scripts[path] = mod
+	r.scriptsLock.Unlock()
+}
+
+func (r *Registry) defined(key registryKey) bool {
+	_, ok := r.get(key)
+	return ok
+}
@@ -0,0 +1,104 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+)
+
+func nativeReturning(s string) object.BuiltinFunction {
+	return func(i object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+		return object.MakeStringObj(s)
+	}
+}
+
+func TestRegistryLayers(t *testing.T) {
+	base := NewRegistry(nil)
+	base.RegisterNative("test.a", nativeReturning("base a"))
+	base.RegisterNative("test.b", nativeReturning("base b"))
+
+	child := NewRegistry(base)
+	child.RegisterNative("test.a", nativeReturning("child a"))
+	child.RemoveNative("test.b")
+	child.RegisterNative("test.c", nativeReturning("child c"))
+
+	tests := []struct {
+		reg      *Registry
+		name     string
+		expected string
+	}{
+		{base, "test.a", "base a"},
+		{base, "test.b", "base b"},
+		{base, "test.c", ""},
+		{child, "test.a", "child a"},
+		{child, "test.b", ""},
+		{child, "test.c", "child c"},
+	}
+
+	for _, tt := range tests {
+		fn := tt.reg.Native(tt.name)
+		if tt.expected == "" {
+			if fn != nil {
+				t.Errorf("%s: expected no native", tt.name)
+			}
+			continue
+		}
+		if fn == nil {
+			t.Errorf("%s: native not found", tt.name)
+			continue
+		}
+		if got := fn.Fn(nil, nil).Inspect(); got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, got)
+		}
+	}
+
+	// Registering again undoes a removal
+	child.RegisterNative("test.b", nativeReturning("child b"))
+	if fn := child.Native("test.b"); fn == nil || fn.Fn(nil, nil).Inspect() != "child b" {
+		t.Error("test.b: expected native to be registered again")
+	}
+}
+
+func TestRegistryModules(t *testing.T) {
+	sub := &object.Module{Name: "sub", Vars: map[string]object.Object{}}
+	base := NewRegistry(nil)
+	base.RegisterModule("mod", &object.Module{
+		Name: "mod",
+		Vars: map[string]object.Object{"sub": sub},
+	})
+
+	child := NewRegistry(base)
+	if child.Module("mod/sub") != sub {
+		t.Fatal("Submodule not found through parent")
+	}
+
+	merged := NewRegistry(nil)
+	merged.Merge(child)
+	if merged.Module("mod") != nil {
+		t.Fatal("Merge copied parent entries")
+	}
+
+	child.RemoveModule("mod")
+	if child.Module("mod") != nil {
+		t.Fatal("Removed module still found")
+	}
+	if base.Module("mod") == nil {
+		t.Fatal("Removing from child affected parent")
+	}
+}
+
+func TestRegistryImportedScripts(t *testing.T) {
+	mod := &object.Module{Name: "mod", Vars: map[string]object.Object{}}
+	base := NewRegistry(nil)
+	base.setImportedScript("/lib/mod.ni", mod)
+
+	if m, ok := base.importedScript("/lib/mod.ni"); !ok || m != mod {
+		t.Fatal("Imported script not cached")
+	}
+
+	// Natives are resolved per registry so a child can't reuse its parent's modules
+	child := NewRegistry(base)
+	if _, ok := child.importedScript("/lib/mod.ni"); ok {
+		t.Fatal("Imported script found through parent")
+	}
+}
@@ -13,6 +13,7 @@ const (
 	loopBlockT blockType = iota
 	tryBlockT
 	doBlockT
+	withBlockT
 )
 
 type block interface {
@@ -21,6 +22,8 @@ type block interface {
 
 type forLoopBlock struct {
 	start, iter, end int
+	sp               int                 // Stack pointer when the loop started, restored by break, continue and each iteration
+	env              *object.Environment // Environment of the current iteration
 }
 
 func (b *forLoopBlock) blockType() blockType { return loopBlockT }
@@ -28,6 +31,7 @@ func (b *forLoopBlock) blockType() blockType { return loopBlockT }
 type recoverBlock struct {
 	pc, sp int
 	caught bool
+	env    *object.Environment // Environment of the recover block, restored when an exception is caught
 }
 
 func (b *recoverBlock) blockType() blockType { return tryBlockT }
@@ -36,6 +40,21 @@ type doBlock struct{}
 
 func (b *doBlock) blockType() blockType { return doBlockT }
 
+// withBlock is started by a with statement, exit closes the resource when the block is popped.
+type withBlock struct {
+	exit *BoundMethod
+}
+
+func (b *withBlock) blockType() blockType { return withBlockT }
+
+// deferredCall is a call made by a defer statement. It runs when its frame returns or unwinds.
+type deferredCall struct {
+	fn    object.Object
+	args  []object.Object
+	names []string
+	this  *VMInstance
+}
+
 type Frame struct {
 	module     string
 	lastFrame  *Frame
@@ -47,6 +66,43 @@ type Frame struct {
 	env        *object.Environment
 	pc         int
 	unwind     bool
+	deferred   []*deferredCall
+	tailCalls  int // Frames replaced by tail calls, they're elided from stack traces
+}
+
+// canTailCall returns if the frame can be reused for a call in tail position.
+// Recover and with blocks and deferred calls would run too early.
+func (f *Frame) canTailCall() bool {
+	if len(f.deferred) > 0 {
+		return false
+	}
+	for _, b := range f.blockStack[:f.bp] {
+		if bt := b.blockType(); bt == tryBlockT || bt == withBlockT {
+			return false
+		}
+	}
+	return true
+}
+
+// reuse starts running code in the frame with env, replacing the current call.
+func (f *Frame) reuse(code *compile.CodeBlock, env *object.Environment) {
+	if cap(f.stack) < code.MaxStackSize+1 {
+		f.stack = make([]object.Object, code.MaxStackSize+1) // +1 to make room for a runtime exception if thrown
+	} else {
+		f.stack = f.stack[:code.MaxStackSize+1]
+		clear(f.stack)
+	}
+	if cap(f.blockStack) < code.MaxBlockSize {
+		f.blockStack = make([]block, code.MaxBlockSize)
+	} else {
+		f.blockStack = f.blockStack[:code.MaxBlockSize]
+		clear(f.blockStack)
+	}
+
+	f.code = code
+	f.env = env
+	f.pc, f.sp, f.bp = 0, 0, 0
+	f.tailCalls++
 }
 
 func (f *Frame) lineno() uint {
@@ -90,6 +146,11 @@ func (f *Frame) getFrontStack() object.Object {
 	return f.stack[f.sp-1]
 }
 
+// getStackAt returns the value depth items below the front of the stack.
+func (f *Frame) getStackAt(depth int) object.Object {
+	return f.stack[f.sp-1-depth]
+}
+
 func (f *Frame) printStack() {
 	for i := f.sp - 1; i >= 0; i-- {
 		fmt.Printf(" %d: %s\n", i, f.stack[i].Inspect())
@@ -1,7 +1,12 @@
 package vm
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/compile"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm/opcode"
 )
 
 func TestBlockStack(t *testing.T) {
@@ -40,3 +45,100 @@ func TestBlockStack(t *testing.T) {
 		t.Fatalf("Block pointer isn't right. Got %d, wanted %d", f.bp, 1)
 	}
 }
+
+func TestFrameTailCall(t *testing.T) {
+	f := &Frame{
+		blockStack: make([]block, 2),
+		stack:      make([]object.Object, 3),
+	}
+
+	f.pushBlock(&forLoopBlock{})
+	if !f.canTailCall() {
+		t.Fatal("Frame with a loop block should allow tail calls")
+	}
+
+	f.pushBlock(&recoverBlock{})
+	if f.canTailCall() {
+		t.Fatal("Frame with a recover block shouldn't allow tail calls")
+	}
+
+	f.bp = 1
+	f.deferred = []*deferredCall{{}}
+	if f.canTailCall() {
+		t.Fatal("Frame with deferred calls shouldn't allow tail calls")
+	}
+	f.deferred = nil
+
+	f.pushStack(object.NullConst)
+	f.pc = 10
+	code := &compile.CodeBlock{MaxStackSize: 5, MaxBlockSize: 1}
+	env := object.NewEnvironment()
+	f.reuse(code, env)
+
+	if f.code != code || f.env != env {
+		t.Fatal("Frame wasn't given the new code and environment")
+	}
+	if f.pc != 0 || f.sp != 0 || f.bp != 0 {
+		t.Fatalf("Frame wasn't reset. pc %d, sp %d, bp %d", f.pc, f.sp, f.bp)
+	}
+	if len(f.stack) != 6 || len(f.blockStack) != 1 {
+		t.Fatalf("Stacks are the wrong size. Got %d and %d", len(f.stack), len(f.blockStack))
+	}
+	if f.tailCalls != 1 {
+		t.Fatalf("Tail call wasn't counted. Got %d", f.tailCalls)
+	}
+}
+
+func TestBreakDeeperThanLoops(t *testing.T) {
+	// The exception should be caught by the recover block around the loop
+	code := &compile.CodeBlock{
+		Name: "__main",
+		Code: []byte{
+			opcode.Recover.ToByte(), 0, 14,
+			opcode.StartLoop.ToByte(), 0, 12, 0, 11,
+			opcode.Break.ToByte(), 0, 1,
+			opcode.NextIter.ToByte(),
+			opcode.EndBlock.ToByte(),
+			opcode.Return.ToByte(),
+			opcode.Return.ToByte(),
+		},
+		MaxStackSize: 1,
+		MaxBlockSize: 2,
+	}
+
+	ret, _ := NewVM(NewSettings()).Execute(code, nil, "__main")
+
+	exc, ok := ret.(*object.Exception)
+	if !ok {
+		t.Fatalf("Expected an exception, got %v", ret)
+	}
+	if !strings.Contains(exc.Message, "BREAK depth 1 is deeper than the enclosing loops") {
+		t.Fatalf("Wrong exception %q", exc.Message)
+	}
+}
+
+func TestForkIsolation(t *testing.T) {
+	machine := NewVM(NewSettings())
+	env := object.NewEnvironment()
+	env.CreateConst("shared", object.MakeIntObj(1))
+	env.Create("counter", object.MakeIntObj(1))
+	machine.SetGlobalEnv(env)
+
+	fork := machine.Fork()
+	if fork.Registry() == machine.Registry() || fork.Registry().Parent() != machine.Registry() {
+		t.Fatal("Fork should have its own registry layered over the original")
+	}
+
+	fork.GlobalEnv().Set("counter", object.MakeIntObj(2))
+	fork.GlobalEnv().Create("added", object.NullConst)
+
+	if v, _ := env.Get("counter"); v.(*object.Integer).Value != 1 {
+		t.Fatalf("Fork changed the original environment, counter is %s", v.Inspect())
+	}
+	if _, ok := env.Get("added"); ok {
+		t.Fatal("Fork added a variable to the original environment")
+	}
+	if v, _ := fork.GlobalEnv().Get("shared"); v.(*object.Integer).Value != 1 || !fork.GlobalEnv().IsConst("shared") {
+		t.Fatal("Fork should have the original global variables")
+	}
+}
@@ -0,0 +1,163 @@
+package vm
+
+import "github.com/nitrogen-lang/nitrogen/src/elemental/object"
+
+// sliceBounds converts the bounds of a slice to indexes into a sequence of
+// length l. Omitted bounds are nil. Negative bounds count from the end of the
+// sequence and bounds out of range are clamped so slicing never fails because of them.
+func sliceBounds(l int64, start, stop, step object.Object) (int64, int64, int64, object.Object) {
+	bound := func(o object.Object, def int64) (int64, object.Object) {
+		if o == object.NullConst {
+			return def, nil
+		}
+		i, ok := o.(*object.Integer)
+		if !ok {
+			return 0, object.NewException("Slice bounds must be INTEGER or nil, got %s", o.Type())
+		}
+		return i.Value, nil
+	}
+
+	s, err := bound(step, 1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if s == 0 {
+		return 0, 0, 0, object.NewException("Slice step can't be zero")
+	}
+
+	// A negative step walks backwards from the last element to before the first
+	lower, upper := int64(0), l
+	if s < 0 {
+		lower, upper = -1, l-1
+	}
+	clamp := func(i int64) int64 {
+		if i < 0 {
+			i += l
+		}
+		if i < lower {
+			return lower
+		}
+		if i > upper {
+			return upper
+		}
+		return i
+	}
+
+	defStart, defStop := lower, upper
+	if s < 0 {
+		defStart, defStop = upper, lower
+	}
+
+	b, err := bound(start, defStart)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	e, err := bound(stop, defStop)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if start != object.NullConst {
+		b = clamp(b)
+	}
+	if stop != object.NullConst {
+		e = clamp(e)
+	}
+	return b, e, s, nil
+}
+
+// sliceIndexes returns the indexes of the elements selected by a slice.
+func sliceIndexes(start, stop, step int64) []int64 {
+	var indexes []int64
+	for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+func (vm *VirtualMachine) evalSliceExpression(left, start, stop, step object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		b, e, s, err := sliceBounds(int64(len(left.Elements)), start, stop, step)
+		if err != nil {
+			return err
+		}
+		indexes := sliceIndexes(b, e, s)
+		elements := make([]object.Object, len(indexes))
+		for i, idx := range indexes {
+			elements[i] = left.Elements[idx]
+		}
+		return &object.Array{Elements: elements}
+
+	case *object.String:
+		b, e, s, err := sliceBounds(int64(len(left.Value)), start, stop, step)
+		if err != nil {
+			return err
+		}
+		indexes := sliceIndexes(b, e, s)
+		runes := make([]rune, len(indexes))
+		for i, idx := range indexes {
+			runes[i] = left.Value[idx]
+		}
+		return &object.String{Value: runes}
+
+	case *object.ByteString:
+		b, e, s, err := sliceBounds(int64(len(left.Value)), start, stop, step)
+		if err != nil {
+			return err
+		}
+		indexes := sliceIndexes(b, e, s)
+		bytes := make([]byte, len(indexes))
+		for i, idx := range indexes {
+			bytes[i] = left.Value[idx]
+		}
+		return &object.ByteString{Value: bytes}
+	}
+
+	return object.NewException("Slice operator not allowed on type %s", left.Type())
+}
+
+// assignSlice replaces the elements of an array selected by a slice. A slice
+// with a step of 1 can be replaced by any number of elements, an extended
+// slice must be replaced by the same number of elements it selects.
+func (vm *VirtualMachine) assignSlice(left, start, stop, step, val object.Object) object.Object {
+	array, ok := left.(*object.Array)
+	if !ok {
+		return object.NewException("Slice assignment not allowed on type %s", left.Type())
+	}
+
+	replace, ok := val.(*object.Array)
+	if !ok {
+		return object.NewException("Can only assign an ARRAY to a slice, got %s", val.Type())
+	}
+
+	b, e, s, err := sliceBounds(int64(len(array.Elements)), start, stop, step)
+	if err != nil {
+		return err
+	}
+
+	if s == 1 {
+		if e < b {
+			e = b
+		}
+		elements := make([]object.Object, 0, int64(len(array.Elements))-(e-b)+int64(len(replace.Elements)))
+		elements = append(elements, array.Elements[:b]...)
+		elements = append(elements, replace.Elements...)
+		elements = append(elements, array.Elements[e:]...)
+		array.Elements = elements
+		return object.NullConst
+	}
+
+	indexes := sliceIndexes(b, e, s)
+	if len(indexes) != len(replace.Elements) {
+		return object.NewException("Can't assign %d elements to an extended slice of %d elements",
+			len(replace.Elements), len(indexes))
+	}
+
+	// Copy first in case the array is assigned to a slice of itself
+	elements := make([]object.Object, len(replace.Elements))
+	copy(elements, replace.Elements)
+	for i, idx := range indexes {
+		array.Elements[idx] = elements[i]
+	}
+	return object.NullConst
+}
@@ -0,0 +1,30 @@
+package vm
+
+import "github.com/nitrogen-lang/nitrogen/src/elemental/object"
+
+// tailCall calls fn with argc arguments from the stack by reusing the current
+// frame, a frame isn't needed anymore once it makes a call in tail position.
+// It returns false without calling fn if fn isn't a function or the frame still
+// has recover blocks, with blocks or deferred calls that depend on it. unwind
+// is the same as for a normal call so exceptions from fn are handled the same way.
+func (vm *VirtualMachine) tailCall(argc uint16, names []string, fn object.Object, this *VMInstance, unwind bool) bool {
+	if method, ok := fn.(*BoundMethod); ok {
+		fn, this = method.Method, method.Instance
+	}
+
+	vmFn, ok := fn.(*VMFunction)
+	if !ok || !vm.currentFrame.canTailCall() {
+		return false
+	}
+
+	env, ex := vm.functionEnv(vmFn, argc, names, this)
+	if ex != nil {
+		vm.currentFrame.pushStack(ex)
+		vm.throw()
+		return true
+	}
+
+	vm.currentFrame.reuse(vmFn.Body, env)
+	vm.currentFrame.unwind = unwind
+	return true
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"runtime/debug"
 	"strconv"
+	"strings"
 
 	"github.com/nitrogen-lang/nitrogen/src/elemental/compile"
 	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
@@ -47,6 +48,7 @@ type VirtualMachine struct {
 	Settings     *Settings
 	globalEnv    *object.Environment
 	instanceVars map[string]object.Object
+	registry     *Registry
 
 	breakpoint bool
 	unwind     bool
@@ -64,6 +66,7 @@ func NewVM(settings *Settings) *VirtualMachine {
 		callStack:    newFrameStack(),
 		Settings:     settings,
 		instanceVars: make(map[string]object.Object),
+		registry:     DefaultRegistry,
 	}
 }
 
@@ -71,6 +74,23 @@ func (vm *VirtualMachine) SetGlobalEnv(env *object.Environment) {
 	vm.globalEnv = env
 }
 
+func (vm *VirtualMachine) GlobalEnv() *object.Environment {
+	return vm.globalEnv
+}
+
+// SetRegistry sets the registry used to resolve natives, builtins and modules.
+// It should be set before any code is executed.
+func (vm *VirtualMachine) SetRegistry(r *Registry) {
+	if r == nil {
+		r = DefaultRegistry
+	}
+	vm.registry = r
+}
+
+func (vm *VirtualMachine) Registry() *Registry {
+	return vm.registry
+}
+
 func (vm *VirtualMachine) GetCurrentScriptPath() string { return vm.currentFrame.code.Filename }
 func (vm *VirtualMachine) GetStdout() io.Writer         { return vm.Settings.Stdout }
 func (vm *VirtualMachine) GetStderr() io.Writer         { return vm.Settings.Stderr }
@@ -98,14 +118,84 @@ func (vm *VirtualMachine) RemoveInstanceVar(key string) {
 	delete(vm.instanceVars, key)
 }
 
+// Fork returns a new VM that can call functions defined in vm from another goroutine.
+// The fork shares the settings and instance variables of vm, but has a copy of the
+// global environment and its own registry layered over vm's, so modules imported by
+// the fork are cached separately. Functions from vm still run in the environments
+// they were defined in so concurrent code must not modify shared variables.
+func (vm *VirtualMachine) Fork() *VirtualMachine {
+	fork := NewVM(vm.Settings)
+	if vm.globalEnv != nil {
+		fork.globalEnv = vm.globalEnv.Copy()
+	}
+	fork.registry = NewRegistry(vm.registry)
+	for k, v := range vm.instanceVars {
+		fork.instanceVars[k] = v
+	}
+	return fork
+}
+
 func (vm *VirtualMachine) Execute(code *compile.CodeBlock, env *object.Environment, modulename string) (object.Object, error) {
 	if env == nil {
 		env = object.NewEnvironment()
 	}
 	env.SetParent(vm.globalEnv)
+	vm.unwind = false
+	vm.returnValue = nil
+	vm.returnErr = nil
 	return vm.RunFrame(vm.MakeFrame(code, env, modulename), false), vm.returnErr
 }
 
+// Call invokes fn with the given arguments and returns its result. It can be used
+// by host applications outside of a running frame, or by builtins that need to call
+// back into user code. Uncaught exceptions are returned as the result.
+func (vm *VirtualMachine) Call(fn object.Object, args ...object.Object) object.Object {
+	return vm.callDetached(fn, nil, nil, args)
+}
+
+// callDetached calls fn in a new frame on top of the current one. The last
+// len(names) arguments are passed by name. Uncaught exceptions are returned as the result.
+func (vm *VirtualMachine) callDetached(fn object.Object, names []string, this *VMInstance, args []object.Object) (ret object.Object) {
+	code := &compile.CodeBlock{
+		Name:         "__host__",
+		Filename:     "__host__",
+		MaxStackSize: len(args) + 1,
+	}
+	frame := vm.MakeFrame(code, vm.globalEnv, "__host__")
+	frame.unwind = false
+	frame.lastFrame = vm.currentFrame
+	if vm.currentFrame != nil {
+		frame.env = vm.currentFrame.env
+		frame.module = vm.currentFrame.module
+	}
+
+	prev := vm.currentFrame
+	vm.currentFrame = frame
+	defer func() {
+		if r := recover(); r != nil {
+			exc, ok := r.(*object.Exception)
+			if !ok {
+				panic(r)
+			}
+			ret = exc
+		}
+		vm.currentFrame = prev
+		if prev == nil {
+			vm.unwind = false
+		}
+	}()
+
+	for i := len(args) - 1; i >= 0; i-- {
+		frame.pushStack(args[i])
+	}
+
+	vm.callFunction(uint16(len(args)), names, fn, true, this, false)
+	if frame.sp == 0 {
+		return object.NullConst
+	}
+	return frame.popStack()
+}
+
 func (vm *VirtualMachine) CurrentFrame() *Frame {
 	return vm.currentFrame
 }
@@ -147,11 +237,7 @@ func (vm *VirtualMachine) RunFrame(f *Frame, immediateReturn bool) (ret object.O
 				stackBuf := bytes.Buffer{}
 				fmt.Fprintln(&stackBuf, retObj)
 				fmt.Fprintln(&stackBuf, "Stack Trace:")
-				frame := vm.currentFrame
-				for frame != nil {
-					fmt.Fprintf(&stackBuf, "\t%s: %s:%d\n", frame.code.Filename, frame.code.Name, frame.lineno())
-					frame = frame.lastFrame
-				}
+				writeStackTrace(&stackBuf, vm.currentFrame)
 				vm.unwind = vm.currentFrame.unwind
 				exc := object.NewException("%s", stackBuf.String())
 				exc.HasStackTrace = true
@@ -162,11 +248,7 @@ func (vm *VirtualMachine) RunFrame(f *Frame, immediateReturn bool) (ret object.O
 				fmt.Fprintln(vm.GetStderr(), string(debug.Stack()))
 
 				fmt.Fprintln(vm.GetStderr(), "VM Stack Trace:")
-				frame := vm.currentFrame
-				for frame != nil {
-					fmt.Fprintf(vm.GetStderr(), "\t%s: %s:%d\n", frame.code.Filename, frame.code.Name, frame.lineno())
-					frame = frame.lastFrame
-				}
+				writeStackTrace(vm.GetStderr(), vm.currentFrame)
 				vm.unwind = true
 			}
 		}
@@ -371,15 +453,21 @@ mainLoop:
 			}
 
 		case opcode.Return:
-			if vm.currentFrame.sp == 0 {
-				vm.returnValue = object.NullConst
-			} else {
-				vm.returnValue = vm.currentFrame.popStack()
+			var ret object.Object = object.NullConst
+			if vm.currentFrame.sp > 0 {
+				ret = vm.currentFrame.popStack()
+			}
+			// Cleanup can call functions which change the return value
+			if exc := vm.leaveFrame(); exc != nil {
+				ret = exc
 			}
+			vm.returnValue = ret
 
+			returning := vm.currentFrame
 			vm.currentFrame = vm.currentFrame.lastFrame
 			vm.callStack.Pop()
-			if vm.currentFrame == nil || immediateReturn {
+			// Functions called from f return to it normally, only f itself returns immediately
+			if vm.currentFrame == nil || (immediateReturn && returning == f) {
 				return vm.returnValue
 			}
 			vm.currentFrame.pushStack(vm.returnValue)
@@ -434,7 +522,7 @@ mainLoop:
 				vm.currentFrame.pushStack(val)
 				break
 			}
-			if fn := getBuiltin(name); fn != nil {
+			if fn := vm.registry.Builtin(name); fn != nil {
 				vm.currentFrame.pushStack(fn)
 				break
 			}
@@ -480,20 +568,46 @@ mainLoop:
 				vm.throw()
 			}
 
-		case opcode.Call:
+		case opcode.LoadSlice:
+			step := vm.currentFrame.popStack()
+			stop := vm.currentFrame.popStack()
+			start := vm.currentFrame.popStack()
+			left := vm.currentFrame.popStack()
+			res := vm.evalSliceExpression(left, start, stop, step)
+			vm.currentFrame.pushStack(res)
+			if object.ObjectIs(res, object.ExceptionObj) {
+				vm.throw()
+			}
+
+		case opcode.StoreSlice:
+			left := vm.currentFrame.popStack()
+			step := vm.currentFrame.popStack()
+			stop := vm.currentFrame.popStack()
+			start := vm.currentFrame.popStack()
+			value := vm.currentFrame.popStack()
+			res := vm.assignSlice(left, start, stop, step, value)
+			if object.ObjectIs(res, object.ExceptionObj) {
+				vm.currentFrame.pushStack(res)
+				vm.throw()
+			}
+
+		// A tail call that can't reuse the frame is a normal call, the RETURN after it returns its value
+		case opcode.Call, opcode.CallKw, opcode.TailCall, opcode.TailCallKw:
 			numargs := vm.getUint16()
+			var names []string
+			if code == opcode.CallKw || code == opcode.TailCallKw {
+				names = vm.argumentNames()
+			}
 			fn := vm.currentFrame.popStack()
-			this, exists := vm.currentFrame.env.GetLocal("this")
-			if !exists {
-				vm.CallFunction(numargs, fn, false, nil, !immediateReturn)
-				break
+			var instance *VMInstance
+			if this, exists := vm.currentFrame.env.GetLocal("this"); exists {
+				instance, _ = this.(*VMInstance)
 			}
-			instance, ok := this.(*VMInstance)
-			if !ok {
-				vm.CallFunction(numargs, fn, false, nil, !immediateReturn)
+
+			if (code == opcode.TailCall || code == opcode.TailCallKw) && vm.tailCall(numargs, names, fn, instance, !immediateReturn) {
 				break
 			}
-			vm.CallFunction(numargs, fn, false, instance, !immediateReturn)
+			vm.callFunction(numargs, names, fn, false, instance, !immediateReturn)
 
 		case opcode.Compare:
 			r := vm.currentFrame.popStack()
@@ -512,33 +626,44 @@ mainLoop:
 				vm.throw()
 			}
 
+		case opcode.Match:
+			res := vm.evalMatch(vm.fetchByte())
+			vm.currentFrame.pushStack(res)
+			if object.ObjectIs(res, object.ExceptionObj) {
+				vm.throw()
+			}
+
 		case opcode.MakeFunction:
-			fnName := vm.currentFrame.popStack().(*object.String)
-			params := vm.currentFrame.popStack().(*object.Array)
-			codeBlock := vm.currentFrame.popStack().(*compile.CodeBlock)
+			fnName, nameOk := vm.currentFrame.popStack().(*object.String)
+			params, paramsOk := vm.currentFrame.popStack().(*object.Array)
+			codeBlock, codeOk := vm.currentFrame.popStack().(*compile.CodeBlock)
+			if !nameOk || !paramsOk || !codeOk {
+				vm.currentFrame.pushStack(object.NewPanic("Invalid function definition"))
+				vm.throw()
+				break
+			}
 
 			if codeBlock.Native {
-				var (
-					fn     object.Object
-					exists bool
-				)
+				var fn object.Object
 
 				if codeBlock.ClassMethod {
-					fn, exists = nativeMethods[codeBlock.Name]
-					if !exists {
+					method := vm.registry.NativeMethod(codeBlock.Name)
+					if method == nil {
 						ex := object.NewPanic("Native method not implemented %s", codeBlock.Name)
 						vm.currentFrame.pushStack(ex)
 						vm.throw()
 						break
 					}
+					fn = method
 				} else {
-					fn, exists = nativeFn[codeBlock.Name]
-					if !exists {
+					native := vm.registry.Native(codeBlock.Name)
+					if native == nil {
 						ex := object.NewPanic("Native function not implemented %s", codeBlock.Name)
 						vm.currentFrame.pushStack(ex)
 						vm.throw()
 						break
 					}
+					fn = native
 				}
 				vm.currentFrame.pushStack(fn)
 				break
@@ -546,13 +671,14 @@ mainLoop:
 
 			fn := &VMFunction{
 				Name:       fnName.String(),
-				Parameters: make([]string, len(params.Elements)),
+				Parameters: make([]string, 0, len(params.Elements)),
 				Body:       codeBlock,
 				Env:        object.NewEnclosedEnv(vm.currentFrame.env),
 			}
-
-			for i, p := range params.Elements {
-				fn.Parameters[i] = p.(*object.String).String()
+			if exc := setParameters(fn, params.Elements); exc != nil {
+				vm.currentFrame.pushStack(exc)
+				vm.throw()
+				break
 			}
 			vm.currentFrame.pushStack(fn)
 
@@ -567,6 +693,23 @@ mainLoop:
 			}
 			vm.currentFrame.pushStack(array)
 
+		case opcode.ArrayAppend:
+			depth := vm.getUint16()
+			val := vm.currentFrame.popStack()
+			array := vm.currentFrame.getStackAt(int(depth)).(*object.Array)
+			array.Elements = append(array.Elements, val)
+
+		case opcode.FormatValue:
+			spec := vm.currentFrame.code.Constants[vm.getUint16()].(*object.String)
+			res := vm.formatValue(vm.currentFrame.popStack(), spec.String())
+			vm.currentFrame.pushStack(res)
+			if object.ObjectIs(res, object.ExceptionObj) {
+				vm.throw()
+			}
+
+		case opcode.BuildString:
+			vm.currentFrame.pushStack(vm.buildString(vm.getUint16()))
+
 		case opcode.MakeMap:
 			l := vm.getUint16()
 			hash := &object.Hash{
@@ -629,6 +772,20 @@ mainLoop:
 				vm.currentFrame.popStack()
 			}
 
+		case opcode.JumpIfNil:
+			target := vm.getUint16()
+			if vm.currentFrame.getFrontStack() == object.NullConst {
+				vm.currentFrame.pc = int(target)
+			}
+
+		case opcode.JumpNotNilOrPop:
+			target := vm.getUint16()
+			if vm.currentFrame.getFrontStack() != object.NullConst {
+				vm.currentFrame.pc = int(target)
+			} else {
+				vm.currentFrame.popStack()
+			}
+
 		case opcode.StartBlock:
 			vm.currentFrame.pushBlock(&doBlock{})
 			vm.currentFrame.env = object.NewEnclosedEnv(vm.currentFrame.env)
@@ -641,6 +798,23 @@ mainLoop:
 			}
 			vm.currentFrame.pushBlock(tcb)
 			vm.currentFrame.env = object.NewEnclosedEnv(vm.currentFrame.env)
+			tcb.env = vm.currentFrame.env
+
+		case opcode.MapInsert:
+			depth := vm.getUint16()
+			key := vm.currentFrame.popStack()
+			val := vm.currentFrame.popStack()
+			hashKey, ok := key.(object.Hashable)
+			if !ok {
+				vm.currentFrame.pushStack(object.NewException("Map key %s not valid", key.Inspect()))
+				vm.throw()
+				break
+			}
+			hash := vm.currentFrame.getStackAt(int(depth)).(*object.Hash)
+			hash.Pairs[hashKey.HashKey()] = object.HashPair{
+				Key:   key,
+				Value: val,
+			}
 
 		case opcode.StartLoop:
 			loopEnd := vm.getUint16()
@@ -649,26 +823,66 @@ mainLoop:
 				start: vm.currentFrame.pc,
 				iter:  int(iter),
 				end:   int(loopEnd),
+				sp:    vm.currentFrame.sp,
 			}
 			vm.currentFrame.pushBlock(lb)
 			vm.currentFrame.env = object.NewEnclosedEnv(vm.currentFrame.env)
+			lb.env = vm.currentFrame.env
 
 		case opcode.EndBlock:
-			vm.currentFrame.popBlock()
+			exc := vm.exitBlocks([]block{vm.currentFrame.popBlock()})
 			vm.currentFrame.env = vm.currentFrame.env.Parent()
 			if vm.currentFrame.sp == 0 {
 				vm.currentFrame.pushStack(object.NullConst)
 			}
+			if exc != nil {
+				vm.currentFrame.pushStack(exc)
+				vm.throw()
+			}
 
-		case opcode.Continue:
-			vm.currentFrame.pc = vm.currentFrame.popBlockUntil(loopBlockT).(*forLoopBlock).iter
+		case opcode.StartWith:
+			if exc := vm.startWith(); exc != nil {
+				vm.currentFrame.pushStack(exc)
+				vm.throw()
+			}
 
-		case opcode.NextIter:
-			vm.currentFrame.pc = vm.currentFrame.popBlockUntil(loopBlockT).(*forLoopBlock).start
-			vm.currentFrame.env = object.NewEnclosedEnv(vm.currentFrame.env.Parent())
+		case opcode.Defer, opcode.DeferKw:
+			numargs := vm.getUint16()
+			var names []string
+			if code == opcode.DeferKw {
+				names = vm.argumentNames()
+			}
+			vm.deferCall(numargs, names, vm.currentFrame.popStack())
+
+		// Continue and break may jump out of nested blocks and loops, so the loop's
+		// environment and stack are restored
+		case opcode.Continue, opcode.Break:
+			depth := vm.getUint16()
+			b, exc := vm.unwindBlocks(loopBlockT, depth)
+			if b == nil {
+				vm.currentFrame.pushStack(object.NewException("%s depth %d is deeper than the enclosing loops", code, depth))
+				vm.throw()
+				break
+			}
+			lb := b.(*forLoopBlock)
+			if code == opcode.Continue {
+				vm.currentFrame.pc = lb.iter
+			} else {
+				vm.currentFrame.pc = lb.end
+			}
+			vm.currentFrame.env = lb.env
+			vm.currentFrame.sp = lb.sp
+			if exc != nil {
+				vm.currentFrame.pushStack(exc)
+				vm.throw()
+			}
 
-		case opcode.Break:
-			vm.currentFrame.pc = vm.currentFrame.popBlockUntil(loopBlockT).(*forLoopBlock).end
+		case opcode.NextIter:
+			lb := vm.currentFrame.popBlockUntil(loopBlockT).(*forLoopBlock)
+			vm.currentFrame.pc = lb.start
+			vm.currentFrame.sp = lb.sp
+			vm.currentFrame.env = object.NewEnclosedEnv(lb.env.Parent())
+			lb.env = vm.currentFrame.env
 
 		case opcode.Import:
 			path := vm.currentFrame.code.Constants[vm.getUint16()].(*object.String)
@@ -676,13 +890,28 @@ mainLoop:
 
 		case opcode.BuildClass:
 			methodNum := vm.getUint16()
-			class := &VMClass{}
-			class.Name = vm.currentFrame.module + "." + vm.currentFrame.popStack().(*object.String).String()
+			className, nameOk := vm.currentFrame.popStack().(*object.String)
 			parent := vm.currentFrame.popStack()
+			fields, fieldsOk := vm.currentFrame.popStack().(*compile.CodeBlock)
+			if !nameOk || !fieldsOk {
+				vm.currentFrame.pushStack(object.NewPanic("Invalid class definition"))
+				vm.throw()
+				break
+			}
+
+			class := &VMClass{
+				Name:   vm.currentFrame.module + "." + className.String(),
+				Fields: fields,
+			}
 			if parent != object.NullConst {
-				class.Parent = parent.(*VMClass)
+				parentClass, ok := parent.(*VMClass)
+				if !ok {
+					vm.currentFrame.pushStack(object.NewException("Class %s can't extend non-class object %s", className.String(), parent.Type().String()))
+					vm.throw()
+					break
+				}
+				class.Parent = parentClass
 			}
-			class.Fields = vm.currentFrame.popStack().(*compile.CodeBlock)
 			class.Methods = make(map[string]object.ClassMethod, methodNum)
 			for i := methodNum; i > 0; i-- {
 				method := vm.currentFrame.popStack()
@@ -696,14 +925,18 @@ mainLoop:
 			}
 			vm.currentFrame.pushStack(class)
 
-		case opcode.MakeInstance:
+		case opcode.MakeInstance, opcode.MakeInstanceKw:
 			argLen := vm.getUint16()
+			var names []string
+			if code == opcode.MakeInstanceKw {
+				names = vm.argumentNames()
+			}
 			class := vm.currentFrame.popStack()
 			if !object.ObjectIs(class, object.ClassObj) {
 				vm.currentFrame.pushStack(object.NewException("Cannot make instance from non-class object %s", class.Type().String()))
 				vm.throw()
 			}
-			vm.makeInstance(argLen, class)
+			vm.makeInstance(argLen, names, class)
 
 		case opcode.LoadAttribute:
 			name := vm.currentFrame.code.Names[vm.getUint16()]
@@ -831,6 +1064,17 @@ mainLoop:
 	}
 }
 
+// writeStackTrace writes a line for frame and each frame that called it to w.
+func writeStackTrace(w io.Writer, frame *Frame) {
+	for frame != nil {
+		fmt.Fprintf(w, "\t%s: %s:%d\n", frame.code.Filename, frame.code.Name, frame.lineno())
+		if frame.tailCalls > 0 {
+			fmt.Fprintf(w, "\t... tail calls elided: %d\n", frame.tailCalls)
+		}
+		frame = frame.lastFrame
+	}
+}
+
 func (vm *VirtualMachine) currentOpcode() opcode.Opcode {
 	b := vm.currentFrame.code.Code[vm.currentFrame.pc-1]
 	return opcode.Opcode(b)
@@ -870,18 +1114,27 @@ func (vm *VirtualMachine) throw() object.Object {
 
 	cframe := vm.currentFrame
 	for {
-		// Unwind block stack until there's a try block
-		catchBlock := vm.currentFrame.popBlockUntil(tryBlockT)
+		// Unwind block stack until there's a try block, an exception thrown while
+		// closing resources or running deferred calls replaces the current one
+		catchBlock, exc := vm.unwindBlocks(tryBlockT, 0)
+		if exc != nil {
+			exception = exc
+		}
+
 		if catchBlock != nil { // Try block found
 			tryBlockS := catchBlock.(*recoverBlock)
 			if !tryBlockS.caught {
 				tryBlockS.caught = true
 				vm.currentFrame.sp = tryBlockS.sp // Unwind data stack
 				vm.currentFrame.pc = tryBlockS.pc // Set program counter to catch block
+				vm.currentFrame.env = tryBlockS.env
 				(exception.(*object.Exception)).Caught = true
 				break
 			}
 		}
+		if exc := vm.leaveFrame(); exc != nil {
+			exception = exc
+		}
 		if !vm.currentFrame.unwind {
 			exc := object.NewException("%s", exception.Inspect())
 			exc.HasStackTrace = exception.(*object.Exception).HasStackTrace
@@ -906,7 +1159,50 @@ func (vm *VirtualMachine) throw() object.Object {
 	return nil
 }
 
-func (vm *VirtualMachine) makeInstance(argLen uint16, class object.Object) {
+// argumentNames pops the array of names pushed for a call with named arguments.
+func (vm *VirtualMachine) argumentNames() []string {
+	arr := vm.currentFrame.popStack().(*object.Array)
+	names := make([]string, len(arr.Elements))
+	for i, name := range arr.Elements {
+		names[i] = name.(*object.String).String()
+	}
+	return names
+}
+
+// setParameters sets the parameters of fn from the array built for MAKE_FUNCTION.
+// Parameters with a default value are a [name, value] pair, a rest parameter's
+// name starts with "...".
+func setParameters(fn *VMFunction, params []object.Object) *object.Exception {
+	for _, p := range params {
+		switch p := p.(type) {
+		case *object.String:
+			if name := p.String(); strings.HasPrefix(name, "...") {
+				fn.Rest = name[3:]
+				continue
+			}
+			fn.Parameters = append(fn.Parameters, p.String())
+		case *object.Array:
+			if len(p.Elements) != 2 {
+				return object.NewPanic("Invalid default parameter in function %s", fn.Name)
+			}
+			name, ok := p.Elements[0].(*object.String)
+			if !ok {
+				return object.NewPanic("Invalid default parameter in function %s", fn.Name)
+			}
+
+			if fn.Defaults == nil {
+				fn.Defaults = make([]object.Object, len(params))
+			}
+			fn.Defaults[len(fn.Parameters)] = p.Elements[1]
+			fn.Parameters = append(fn.Parameters, name.String())
+		default:
+			return object.NewPanic("Invalid parameter in function %s", fn.Name)
+		}
+	}
+	return nil
+}
+
+func (vm *VirtualMachine) makeInstance(argLen uint16, names []string, class object.Object) {
 	var instance *VMInstance
 
 	if class, ok := class.(*VMClass); ok {
@@ -964,8 +1260,25 @@ func (vm *VirtualMachine) makeInstance(argLen uint16, class object.Object) {
 		return
 	}
 
-	vm.CallFunction(argLen, init, true, nil, false)
-	ret := vm.currentFrame.popStack() // Pop return value of init function
+	var ret object.Object
+	if native, ok := init.Method.(*BuiltinMethod); ok {
+		// Call native constructors directly so an exception is only thrown once below
+		args := make([]object.Object, argLen)
+		for i := range args {
+			args[i] = vm.currentFrame.popStack()
+		}
+		if len(names) > 0 {
+			ret = object.NewException("Named arguments can't be passed to %s", native.Type())
+		} else {
+			ret = native.Fn(vm, instance, instance.Fields, args...)
+		}
+		if ret == nil {
+			ret = object.NullConst
+		}
+	} else {
+		vm.callFunction(argLen, names, init, true, nil, false)
+		ret = vm.currentFrame.popStack() // Pop return value of init function
+	}
 	if ret.Type() == object.ExceptionObj {
 		vm.currentFrame.pushStack(ret)
 		vm.throw()
@@ -981,6 +1294,25 @@ func (vm *VirtualMachine) makeInstance(argLen uint16, class object.Object) {
 }
 
 func (vm *VirtualMachine) CallFunction(argc uint16, fn object.Object, now bool, this *VMInstance, unwind bool) {
+	vm.callFunction(argc, nil, fn, now, this, unwind)
+}
+
+// callFunction calls fn with argc arguments from the stack. The last len(names)
+// arguments are passed by name to the parameters in names.
+func (vm *VirtualMachine) callFunction(argc uint16, names []string, fn object.Object, now bool, this *VMInstance, unwind bool) {
+	if len(names) > 0 {
+		switch fn.(type) {
+		case *VMFunction, *BoundMethod, *VMClass:
+		default:
+			for i := 0; i < int(argc); i++ {
+				vm.currentFrame.popStack()
+			}
+			vm.currentFrame.pushStack(object.NewException("Named arguments can't be passed to %s", fn.Type()))
+			vm.throw()
+			return
+		}
+	}
+
 	switch fn := fn.(type) {
 	case *object.Builtin:
 		if vm.Settings.Debug {
@@ -1035,19 +1367,9 @@ func (vm *VirtualMachine) CallFunction(argc uint16, fn object.Object, now bool,
 			fmt.Fprintf(vm.GetStdout(), "Calling function %s\n", fn.Name)
 		}
 
-		var env *object.Environment
-		env = object.NewEnclosedEnv(fn.Env)
-		if this != nil {
-			env.SetForce("this", this, true)
-			if fn.Class != nil && fn.Class.Parent != nil {
-				env.SetForce("parent", fn.Class.Parent, true)
-			}
-		}
-
-		paramLen := len(fn.Parameters)
-
-		if int(argc) < paramLen {
-			vm.currentFrame.pushStack(object.NewException("Func expected %d args but was given %d", paramLen, argc))
+		env, ex := vm.functionEnv(fn, argc, names, this)
+		if ex != nil {
+			vm.currentFrame.pushStack(ex)
 			vm.throw()
 			return
 		}
@@ -1056,21 +1378,6 @@ func (vm *VirtualMachine) CallFunction(argc uint16, fn object.Object, now bool,
 		newFrame.unwind = unwind
 		newFrame.lastFrame = vm.currentFrame
 
-		for i := 0; i < paramLen; i++ {
-			newFrame.env.SetForce(fn.Parameters[i], vm.currentFrame.popStack(), false)
-		}
-
-		if int(argc) > paramLen {
-			remaining := int(argc) - paramLen
-			rest := make([]object.Object, remaining)
-			for i := 0; i < remaining; i++ {
-				rest[i] = vm.currentFrame.popStack()
-			}
-			newFrame.env.SetForce("arguments", &object.Array{Elements: rest}, false)
-		} else {
-			newFrame.env.SetForce("arguments", &object.Array{Elements: []object.Object{}}, false)
-		}
-
 		if now {
 			val := vm.RunFrame(newFrame, true)
 			vm.currentFrame.pushStack(val)
@@ -1079,7 +1386,7 @@ func (vm *VirtualMachine) CallFunction(argc uint16, fn object.Object, now bool,
 			vm.callStack.Push(newFrame)
 		}
 	case *BoundMethod:
-		vm.CallFunction(argc, fn.Method, true, fn.Instance, unwind)
+		vm.callFunction(argc, names, fn.Method, true, fn.Instance, unwind)
 	case *VMClass:
 		if this == nil {
 			vm.currentFrame.pushStack(object.NewPanic("Can't call class method outside of object"))
@@ -1100,7 +1407,7 @@ func (vm *VirtualMachine) CallFunction(argc uint16, fn object.Object, now bool,
 			}
 			return
 		}
-		vm.CallFunction(argc, init, true, this, unwind)
+		vm.callFunction(argc, names, init, true, this, unwind)
 	default:
 		for i := 0; i < int(argc); i++ {
 			vm.currentFrame.popStack()
@@ -1110,6 +1417,81 @@ func (vm *VirtualMachine) CallFunction(argc uint16, fn object.Object, now bool,
 	}
 }
 
+// functionEnv makes the environment of a call to fn with argc arguments from the stack.
+func (vm *VirtualMachine) functionEnv(fn *VMFunction, argc uint16, names []string, this *VMInstance) (*object.Environment, *object.Exception) {
+	env := object.NewEnclosedEnv(fn.Env)
+	if this != nil {
+		env.SetForce("this", this, true)
+		if fn.Class != nil && fn.Class.Parent != nil {
+			env.SetForce("parent", fn.Class.Parent, true)
+		}
+	}
+
+	if ex := vm.bindArguments(fn, argc, names, env); ex != nil {
+		return nil, ex
+	}
+	return env, nil
+}
+
+// bindArguments pops argc arguments from the stack and defines them in env as
+// the parameters of fn. The last len(names) arguments are passed by name.
+func (vm *VirtualMachine) bindArguments(fn *VMFunction, argc uint16, names []string, env *object.Environment) *object.Exception {
+	positional := make([]object.Object, int(argc)-len(names))
+	for i := range positional {
+		positional[i] = vm.currentFrame.popStack()
+	}
+	named := make([]object.Object, len(names))
+	for i := range named {
+		named[i] = vm.currentFrame.popStack()
+	}
+
+	values := make([]object.Object, len(fn.Parameters))
+	extra := []object.Object{}
+	for i, arg := range positional {
+		if i < len(values) {
+			values[i] = arg
+		} else {
+			extra = append(extra, arg)
+		}
+	}
+
+	for i, name := range names {
+		param := -1
+		for j, p := range fn.Parameters {
+			if p == name {
+				param = j
+				break
+			}
+		}
+
+		if param == -1 {
+			return object.NewException("Func %s has no parameter named %s", fn.Name, name)
+		}
+		if values[param] != nil {
+			return object.NewException("Func %s was given parameter %s more than once", fn.Name, name)
+		}
+		values[param] = named[i]
+	}
+
+	for i, param := range fn.Parameters {
+		if values[i] == nil {
+			if fn.Defaults == nil || fn.Defaults[i] == nil {
+				return object.NewException("Func %s missing argument for parameter %s", fn.Name, param)
+			}
+			values[i] = fn.Defaults[i]
+		}
+		env.SetForce(param, values[i], false)
+	}
+
+	// `arguments` holds any extra arguments, a rest parameter is another name for it
+	rest := &object.Array{Elements: extra}
+	if fn.Rest != "" {
+		env.SetForce(fn.Rest, rest, false)
+	}
+	env.SetForce("arguments", rest, false)
+	return nil
+}
+
 func (vm *VirtualMachine) ImportPreamble(name string) error {
 	if name == "" {
 		name = "std/preamble/main"
@@ -0,0 +1,231 @@
+package lexer
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/nitrogen-lang/nitrogen/src/token"
+)
+
+// FormatSegment is a piece of a format string. It's either literal text, or
+// an interpolated expression with an optional format spec.
+type FormatSegment struct {
+	Text string // Literal text with escape sequences processed
+	Expr string // Source code of the expression, empty for literal text
+	Spec string // Format spec written after the expression, may be empty
+	Pos  token.Position
+}
+
+// FormatError is returned when a format string is malformed.
+type FormatError struct {
+	Pos token.Position
+	Msg string
+}
+
+func (e *FormatError) Error() string {
+	return e.Msg
+}
+
+// readFormatString reads an f"..." string. The literal is the raw source
+// between the quotes, it's split into segments by the parser with SplitFormatString.
+func (l *Lexer) readFormatString() token.Token {
+	var raw bytes.Buffer
+	pos := l.curPosition()
+	l.readRune() // Go past the f
+	l.readRune() // Go past the starting double quote
+
+	illegal := func(msg string) token.Token {
+		return token.Token{
+			Literal: msg,
+			Type:    token.Illegal,
+			Pos:     l.curPosition(),
+		}
+	}
+
+	depth := 0
+	for depth > 0 || l.curCh != '"' {
+		switch l.curCh {
+		case 0:
+			return illegal("Unterminated format string")
+		case '\n':
+			return illegal("Newline not allowed in string")
+		case '\\':
+			raw.WriteRune(l.curCh)
+			l.readRune()
+		case '{':
+			if depth == 0 && l.peekCh == '{' {
+				raw.WriteRune(l.curCh)
+				l.readRune()
+			} else {
+				depth++
+			}
+		case '}':
+			if depth == 0 && l.peekCh == '}' {
+				raw.WriteRune(l.curCh)
+				l.readRune()
+			} else if depth > 0 {
+				depth--
+			}
+		case '"', '\'':
+			// A string inside an interpolated expression
+			if depth == 0 {
+				break
+			}
+			quote := l.curCh
+			raw.WriteRune(l.curCh)
+			l.readRune()
+			for l.curCh != quote {
+				if l.curCh == 0 || l.curCh == '\n' {
+					return illegal("Unterminated string in format string")
+				}
+				if l.curCh == '\\' {
+					raw.WriteRune(l.curCh)
+					l.readRune()
+				}
+				raw.WriteRune(l.curCh)
+				l.readRune()
+			}
+		}
+
+		raw.WriteRune(l.curCh)
+		l.readRune()
+	}
+
+	return token.Token{
+		Literal: raw.String(),
+		Type:    token.FormatString,
+		Pos:     pos,
+	}
+}
+
+// SplitFormatString splits a format string token into literal text and
+// expressions. Doubled braces are literal braces.
+func SplitFormatString(tok token.Token) ([]FormatSegment, error) {
+	raw := []rune(tok.Literal)
+	start := tok.Pos.Col + 2 // Skip f"
+	posAt := func(i int) token.Position {
+		return makePos(tok.Pos.Line, start+uint(i), tok.Pos.Filename)
+	}
+
+	var segments []FormatSegment
+	var text bytes.Buffer
+	textStart := 0
+
+	flushText := func() error {
+		if text.Len() == 0 {
+			return nil
+		}
+		str := NewString(`"` + text.String() + `"`).readString()
+		if str.Type == token.Illegal {
+			return &FormatError{Pos: posAt(textStart), Msg: str.Literal}
+		}
+		segments = append(segments, FormatSegment{Text: str.Literal, Pos: posAt(textStart)})
+		text.Reset()
+		return nil
+	}
+
+	for i := 0; i < len(raw); i++ {
+		if text.Len() == 0 {
+			textStart = i
+		}
+
+		switch raw[i] {
+		case '\\':
+			text.WriteRune(raw[i])
+			if i+1 < len(raw) {
+				i++
+				text.WriteRune(raw[i])
+			}
+
+		case '{':
+			if i+1 < len(raw) && raw[i+1] == '{' {
+				text.WriteRune('{')
+				i++
+				continue
+			}
+			if err := flushText(); err != nil {
+				return nil, err
+			}
+
+			exprEnd, end := scanFormatExpr(raw, i+1)
+			if end < 0 {
+				return nil, &FormatError{Pos: posAt(i), Msg: "expected '}' after expression in format string"}
+			}
+			if strings.ContainsAny(string(raw[exprEnd:end]), "{") {
+				return nil, &FormatError{Pos: posAt(exprEnd), Msg: "format spec can't contain '{'"}
+			}
+
+			expr := string(raw[i+1 : exprEnd])
+			if strings.TrimSpace(expr) == "" {
+				return nil, &FormatError{Pos: posAt(i), Msg: "empty expression in format string"}
+			}
+
+			seg := FormatSegment{Expr: expr, Pos: posAt(i + 1)}
+			if exprEnd < end {
+				seg.Spec = string(raw[exprEnd+1 : end])
+			}
+			segments = append(segments, seg)
+			i = end
+
+		case '}':
+			if i+1 < len(raw) && raw[i+1] == '}' {
+				text.WriteRune('}')
+				i++
+				continue
+			}
+			return nil, &FormatError{Pos: posAt(i), Msg: "single '}' in format string, use '}}' for a literal brace"}
+
+		default:
+			text.WriteRune(raw[i])
+		}
+	}
+
+	if err := flushText(); err != nil {
+		return nil, err
+	}
+	return segments, nil
+}
+
+// scanFormatExpr finds the end of an expression starting at start. It returns
+// the index of the ':' starting the format spec, or the closing brace if
+// there isn't a spec, and the index of the closing brace. The closing brace
+// index is -1 if the expression isn't terminated.
+func scanFormatExpr(raw []rune, start int) (int, int) {
+	depth := 0
+	exprEnd := -1
+
+	for i := start; i < len(raw); i++ {
+		if exprEnd >= 0 {
+			// In the format spec
+			if raw[i] == '}' {
+				return exprEnd, i
+			}
+			continue
+		}
+
+		switch raw[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']':
+			depth--
+		case '}':
+			if depth == 0 {
+				return i, i
+			}
+			depth--
+		case ':':
+			if depth == 0 {
+				exprEnd = i
+			}
+		case '"', '\'':
+			quote := raw[i]
+			for i++; i < len(raw) && raw[i] != quote; i++ {
+				if raw[i] == '\\' {
+					i++
+				}
+			}
+		}
+	}
+
+	return exprEnd, -1
+}
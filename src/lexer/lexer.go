@@ -55,6 +55,15 @@ func NewString(input string) *Lexer {
 	return New(strings.NewReader(input))
 }
 
+// NewStringAt creates a lexer for input that's part of a larger file starting at pos.
+func NewStringAt(input string, pos token.Position) *Lexer {
+	l := NewString(input)
+	l.line = pos.Line
+	l.col = pos.Col
+	l.currentFile = pos.Filename
+	return l
+}
+
 func (l *Lexer) loadFile() error {
 	if len(l.fileList) == 0 {
 		panic("No more files to load")
@@ -277,9 +286,49 @@ func (l *Lexer) NextToken() token.Token {
 	case ':':
 		tok = l.newToken(token.Colon, l.curCh)
 	case '.':
-		tok = l.newToken(token.Dot, l.curCh)
+		if l.peekChar() == '.' {
+			tok = token.Token{
+				Type:    token.Range,
+				Literal: "..",
+				Pos:     l.curPosition(),
+			}
+			l.readRune()
+			if l.peekChar() == '.' {
+				tok.Type = token.Ellipsis
+				tok.Literal = "..."
+				l.readRune()
+			}
+		} else {
+			tok = l.newToken(token.Dot, l.curCh)
+		}
 	case '^':
 		tok = l.newToken(token.Carrot, l.curCh)
+	case '?':
+		switch l.peekChar() {
+		case '?':
+			tok = token.Token{
+				Type:    token.NilCoalesce,
+				Literal: "??",
+				Pos:     l.curPosition(),
+			}
+			l.readRune()
+		case '.':
+			tok = token.Token{
+				Type:    token.OptionalDot,
+				Literal: "?.",
+				Pos:     l.curPosition(),
+			}
+			l.readRune()
+		case '[':
+			tok = token.Token{
+				Type:    token.OptionalIndex,
+				Literal: "?[",
+				Pos:     l.curPosition(),
+			}
+			l.readRune()
+		default:
+			tok = l.newToken(token.Illegal, l.curCh)
+		}
 
 	// Groupings
 	case '(':
@@ -328,6 +377,13 @@ func (l *Lexer) NextToken() token.Token {
 			return tok
 		}
 
+		if l.curCh == 'f' && l.peekChar() == '"' {
+			tok = l.readFormatString()
+			l.readRune()
+			l.lastToken = tok
+			return tok
+		}
+
 		if isLetter(l.curCh) {
 			tok.Pos = l.curPosition()
 			tok.Literal = l.readIdentifier()
@@ -365,6 +421,7 @@ func (l *Lexer) needSemicolon() bool {
 		token.Integer,
 		token.Float,
 		token.String,
+		token.FormatString,
 		token.True,
 		token.False,
 		token.Nil,
@@ -529,6 +586,9 @@ func (l *Lexer) readNumber() token.Token {
 			l.readRune()
 			continue
 		} else if l.curCh == '.' {
+			if l.peekChar() == '.' { // Range operator, 1..5
+				break
+			}
 			if tokenType != token.Integer {
 				return token.Token{
 					Type:    token.Illegal,
@@ -54,6 +54,11 @@ or
 and
 
 for
+
+1..5
+[...rest]
+a ?? b?.c?[d]
+f"a {m["}"]:>5} {{b}}"
 `
 
 	tests := []struct {
@@ -200,7 +205,31 @@ for
 
 		{token.For, "for", makePos(47, 1, "")},
 
-		{token.EOF, "", makePos(48, 0, "")},
+		{token.Integer, "1", makePos(49, 1, "")},
+		{token.Range, "..", makePos(49, 2, "")},
+		{token.Integer, "5", makePos(49, 4, "")},
+		{token.Semicolon, ";", makePos(49, 5, "")},
+
+		{token.LSquare, "[", makePos(50, 1, "")},
+		{token.Ellipsis, "...", makePos(50, 2, "")},
+		{token.Identifier, "rest", makePos(50, 5, "")},
+		{token.RSquare, "]", makePos(50, 9, "")},
+		{token.Semicolon, ";", makePos(50, 10, "")},
+
+		{token.Identifier, "a", makePos(51, 1, "")},
+		{token.NilCoalesce, "??", makePos(51, 3, "")},
+		{token.Identifier, "b", makePos(51, 6, "")},
+		{token.OptionalDot, "?.", makePos(51, 7, "")},
+		{token.Identifier, "c", makePos(51, 9, "")},
+		{token.OptionalIndex, "?[", makePos(51, 10, "")},
+		{token.Identifier, "d", makePos(51, 12, "")},
+		{token.RSquare, "]", makePos(51, 13, "")},
+		{token.Semicolon, ";", makePos(51, 14, "")},
+
+		{token.FormatString, `a {m["}"]:>5} {{b}}`, makePos(52, 1, "")},
+		{token.Semicolon, ";", makePos(52, 22, "")},
+
+		{token.EOF, "", makePos(53, 0, "")},
 	}
 
 	l := NewString(input)
@@ -228,3 +257,69 @@ for
 		}
 	}
 }
+
+func TestSplitFormatString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []FormatSegment
+		err      string
+	}{
+		{
+			input:    `f"plain\ttext"`,
+			expected: []FormatSegment{{Text: "plain\ttext", Pos: makePos(1, 3, "")}},
+		},
+		{
+			input: `f"a {x} b {y + 1:>5.2f}"`,
+			expected: []FormatSegment{
+				{Text: "a ", Pos: makePos(1, 3, "")},
+				{Expr: "x", Pos: makePos(1, 6, "")},
+				{Text: " b ", Pos: makePos(1, 8, "")},
+				{Expr: "y + 1", Spec: ">5.2f", Pos: makePos(1, 12, "")},
+			},
+		},
+		{
+			input: `f"{{{m["k"]}}}"`,
+			expected: []FormatSegment{
+				{Text: "{", Pos: makePos(1, 3, "")},
+				{Expr: `m["k"]`, Pos: makePos(1, 6, "")},
+				{Text: "}", Pos: makePos(1, 13, "")},
+			},
+		},
+		{
+			input: `f"{ {"a": 1}["a"] }"`,
+			expected: []FormatSegment{
+				{Expr: ` {"a": 1}["a"] `, Pos: makePos(1, 4, "")},
+			},
+		},
+		{input: `f"{}"`, err: "empty expression in format string"},
+		{input: `f"a}"`, err: "single '}' in format string, use '}}' for a literal brace"},
+		{input: `f"{x:{y}}"`, err: "format spec can't contain '{'"},
+	}
+
+	for _, tt := range tests {
+		tok := NewString(tt.input).NextToken()
+		if tok.Type != token.FormatString {
+			t.Fatalf("%s: expected a format string token, got %s %q", tt.input, tok.Type, tok.Literal)
+		}
+
+		segments, err := SplitFormatString(tok)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("%s: expected error %q, got %v", tt.input, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error %s", tt.input, err)
+		}
+
+		if len(segments) != len(tt.expected) {
+			t.Fatalf("%s: expected %d segments, got %d: %#v", tt.input, len(tt.expected), len(segments), segments)
+		}
+		for i, seg := range segments {
+			if seg != tt.expected[i] {
+				t.Fatalf("%s: segment %d wrong. Expected %#v, got %#v", tt.input, i, tt.expected[i], seg)
+			}
+		}
+	}
+}
@@ -2,7 +2,10 @@ package moduleutils
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -10,12 +13,16 @@ import (
 	"github.com/nitrogen-lang/nitrogen/src/ast"
 	"github.com/nitrogen-lang/nitrogen/src/lexer"
 	"github.com/nitrogen-lang/nitrogen/src/parser"
+	"github.com/nitrogen-lang/nitrogen/src/token"
 )
 
 // ASTCache is a global cache of Program AST nodes keyed to a script filename
 var (
 	ASTCache       = newASTCache()
 	ParserSettings = &parser.Settings{}
+
+	// ParserWarnings receives warnings from parsing a file, they're only written when the file is parsed, not on a cache hit
+	ParserWarnings io.Writer = os.Stderr
 )
 
 type astCache struct {
@@ -49,22 +56,41 @@ func (c *astCache) GetTree(file string) (*ast.Program, error) {
 	}
 
 	// miss
-	l, err := lexer.NewFile(file)
+	src, err := os.ReadFile(file)
 	if err != nil {
 		return nil, err
 	}
+	return c.parse(file, src, fileinfo.ModTime())
+}
+
+// ParseSource parses src as the contents of file and caches the tree the same as GetTree.
+// It's used when the caller has already read the file so the tree is built from exactly
+// the same bytes.
+func (c *astCache) ParseSource(file string, src []byte, modTime time.Time) (*ast.Program, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.parse(file, src, modTime)
+}
+
+func (c *astCache) parse(file string, src []byte, modTime time.Time) (*ast.Program, error) {
+	filename, _ := filepath.Abs(file)
+	l := lexer.NewStringAt(string(src), token.Position{Line: 1, Col: 1, Filename: filename})
 
 	p := parser.New(l, ParserSettings)
 	program := p.ParseProgram()
 	if len(p.Errors()) != 0 {
 		return nil, errors.New(strings.Join(p.Errors(), "\n"))
 	}
+	for _, w := range p.Warnings() {
+		fmt.Fprintln(ParserWarnings, w)
+	}
 
-	if cachedItem == nil {
+	cachedItem, cached := c.cache[file]
+	if !cached {
 		cachedItem = &cacheItem{}
 	}
 	cachedItem.tree = program
-	cachedItem.modTime = fileinfo.ModTime()
+	cachedItem.modTime = modTime
 	c.cache[file] = cachedItem
 
 	return program, nil
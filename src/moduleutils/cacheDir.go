@@ -0,0 +1,146 @@
+package moduleutils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nitrogen-lang/nitrogen/src/compiler/marshal"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/compile"
+)
+
+var (
+	// CacheDir is a directory used to store compiled scripts. Files are named by a hash
+	// of the script's path, contents and the compiler version so the cache works for
+	// read-only source trees and isn't affected by modification times. If empty,
+	// compiled scripts are written next to their source file.
+	CacheDir string
+
+	// CompilerVersion is included in the cache key so upgrading the interpreter
+	// invalidates cached scripts.
+	CompilerVersion string
+)
+
+const cacheFileExt = ".nib"
+
+// DefaultCacheDir returns the user's Nitrogen cache directory, ex. $XDG_CACHE_HOME/nitrogen.
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "nitrogen-cache")
+	}
+	return filepath.Join(dir, "nitrogen")
+}
+
+// cachedBlockPath returns the path in CacheDir for a script with the given source.
+func cachedBlockPath(file, name string, src []byte) string {
+	h := sha256.New()
+	h.Write(marshal.VersionNumber)
+	h.Write([]byte(CompilerVersion))
+	h.Write([]byte{0})
+	h.Write([]byte(file))
+	h.Write([]byte{0})
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write(src)
+	return filepath.Join(CacheDir, hex.EncodeToString(h.Sum(nil))+cacheFileExt)
+}
+
+// writeCachedBlock atomically writes a compiled script to the cache directory.
+func writeCachedBlock(path string, cb *compile.CodeBlock, ts time.Time) error {
+	data, err := marshal.Encode(cb, ts, false)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// CacheStats describes the contents of a cache directory.
+type CacheStats struct {
+	Files  int
+	Size   int64
+	Oldest time.Time
+	Newest time.Time
+}
+
+// CacheDirStats returns statistics about the compiled scripts in dir.
+func CacheDirStats(dir string) (*CacheStats, error) {
+	stats := &CacheStats{}
+
+	err := walkCacheDir(dir, func(path string, info os.FileInfo) error {
+		stats.Files++
+		stats.Size += info.Size()
+		if stats.Oldest.IsZero() || info.ModTime().Before(stats.Oldest) {
+			stats.Oldest = info.ModTime()
+		}
+		if info.ModTime().After(stats.Newest) {
+			stats.Newest = info.ModTime()
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// CleanCacheDir removes all compiled scripts from dir and returns the number of files removed.
+func CleanCacheDir(dir string) (int, error) {
+	removed := 0
+	err := walkCacheDir(dir, func(path string, info os.FileInfo) error {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+func walkCacheDir(dir string, fn func(path string, info os.FileInfo) error) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !(strings.HasSuffix(name, cacheFileExt) || strings.HasPrefix(name, ".tmp-")) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if err := fn(filepath.Join(dir, name), info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package moduleutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+)
+
+func TestCacheDir(t *testing.T) {
+	dir := t.TempDir()
+	srcDir := t.TempDir()
+
+	CacheDir = dir
+	defer func() { CacheDir = "" }()
+	CodeBlockCache.ClearAll()
+	ASTCache.ClearAll()
+
+	script := filepath.Join(srcDir, "script.ni")
+	if err := os.WriteFile(script, []byte("const a = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CodeBlockCache.GetBlock(script, "script"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(srcDir, "script.nib")); !os.IsNotExist(err) {
+		t.Fatal("compiled script written next to source file")
+	}
+
+	stats, err := CacheDirStats(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Files != 1 || stats.Size == 0 {
+		t.Fatalf("expected 1 cached file, got %d (%d bytes)", stats.Files, stats.Size)
+	}
+
+	src, _ := os.ReadFile(script)
+	cachePath := cachedBlockPath(script, "script", src)
+	if _, err := os.Stat(cachePath); err != nil {
+		t.Fatalf("cache file missing: %s", err)
+	}
+
+	// Changing the contents must produce a new cache key
+	if cachedBlockPath(script, "script", []byte("const a = 2\n")) == cachePath {
+		t.Fatal("cache key didn't change with source")
+	}
+
+	CompilerVersion = "test"
+	defer func() { CompilerVersion = "" }()
+	if cachedBlockPath(script, "script", src) == cachePath {
+		t.Fatal("cache key didn't change with compiler version")
+	}
+
+	removed, err := CleanCacheDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Fatalf("expected 1 file removed, got %d", removed)
+	}
+
+	stats, err = CacheDirStats(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Files != 0 {
+		t.Fatalf("expected empty cache, got %d files", stats.Files)
+	}
+}
+
+func TestCacheDirParsesHashedSource(t *testing.T) {
+	CacheDir = t.TempDir()
+	defer func() { CacheDir = "" }()
+	CodeBlockCache.ClearAll()
+	ASTCache.ClearAll()
+
+	script := filepath.Join(t.TempDir(), "script.ni")
+	modTime := time.Now().Add(-time.Hour).Round(time.Second)
+	write := func(src string) {
+		if err := os.WriteFile(script, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(script, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("const a = 1\n")
+	if _, err := ASTCache.GetTree(script); err != nil {
+		t.Fatal(err)
+	}
+
+	// Change the contents without changing the modification time
+	write("const a = 12345\n")
+	code, err := CodeBlockCache.GetBlock(script, "script")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, c := range code.Constants {
+		if i, ok := c.(*object.Integer); ok && i.Value == 12345 {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("compiled script wasn't parsed from the hashed source")
+	}
+}
+
+func TestCacheDirMissing(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing")
+
+	stats, err := CacheDirStats(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Files != 0 {
+		t.Fatalf("expected no files, got %d", stats.Files)
+	}
+
+	if _, err := CleanCacheDir(dir); err != nil {
+		t.Fatal(err)
+	}
+}
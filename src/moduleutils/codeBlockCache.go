@@ -67,11 +67,9 @@ func (c *codeBlockCache) GetBlock(file, name string) (*compile.CodeBlock, error)
 
 		code, modinfo, err := marshal.ReadFile(file)
 		if err != nil {
+			// The compiled file is outdated or corrupt, fallback to the source file
 			c.m.Unlock()
-			if marshal.IsErrVersion(err) {
-				return c.GetBlock(srcfile, name)
-			}
-			return nil, err
+			return c.GetBlock(srcfile, name)
 		}
 
 		if !modinfo.ModTime.Equal(srcinfo.ModTime().Round(time.Second)) {
@@ -79,6 +77,30 @@ func (c *codeBlockCache) GetBlock(file, name string) (*compile.CodeBlock, error)
 			return c.GetBlock(srcfile, name)
 		}
 
+		cachedItem.block = code
+	} else if CacheDir != "" {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			c.m.Unlock()
+			return nil, err
+		}
+
+		// Parse the same bytes that were hashed. GetTree could return a tree for
+		// different contents with the same modification time.
+		cachePath := cachedBlockPath(file, name, src)
+		code, _, err := marshal.ReadFile(cachePath)
+		if err != nil {
+			program, err := ASTCache.ParseSource(file, src, fileinfo.ModTime())
+			if err != nil {
+				c.m.Unlock()
+				return nil, err
+			}
+			code = compiler.Compile(program, name)
+
+			if WriteCompiledScripts {
+				writeCachedBlock(cachePath, code, fileinfo.ModTime())
+			}
+		}
 		cachedItem.block = code
 	} else {
 		program, err := ASTCache.GetTree(file)
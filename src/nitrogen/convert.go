@@ -0,0 +1,539 @@
+package nitrogen
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm"
+)
+
+var (
+	objectType    = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType     = reflect.TypeOf((*error)(nil)).Elem()
+	byteSliceType = reflect.TypeOf([]byte(nil))
+)
+
+// ToObject converts a Go value to a Nitrogen object. Structs become maps keyed by
+// field name, or the name given in a `nitrogen:"name"` tag. Fields tagged with
+// `nitrogen:"-"` are skipped. Functions are wrapped as builtin functions.
+func (r *Runtime) ToObject(v interface{}) (object.Object, error) {
+	if v == nil {
+		return object.NullConst, nil
+	}
+	if obj, ok := v.(object.Object); ok {
+		return obj, nil
+	}
+	return r.toObject(reflect.ValueOf(v))
+}
+
+func (r *Runtime) toObject(v reflect.Value) (object.Object, error) {
+	if !v.IsValid() {
+		return object.NullConst, nil
+	}
+	if v.Type().Implements(objectType) {
+		if v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return object.NullConst, nil
+			}
+		}
+		return v.Interface().(object.Object), nil
+	}
+	if v.Type().Implements(errorType) {
+		if v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return object.NullConst, nil
+			}
+		}
+		return object.NewError("%s", v.Interface().(error).Error()), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return object.NativeBoolToBooleanObj(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return object.MakeIntObj(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return object.MakeIntObj(int64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return object.MakeFloatObj(v.Float()), nil
+	case reflect.String:
+		return object.MakeStringObj(v.String()), nil
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return object.NullConst, nil
+		}
+		return r.toObject(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() {
+				return object.NullConst, nil
+			}
+			if v.Type().Elem().Kind() == reflect.Uint8 {
+				return object.MakeByteStringObjBytes(v.Bytes()), nil
+			}
+		}
+
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			elem, err := r.toObject(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = elem
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return object.NullConst, nil
+		}
+
+		hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair, v.Len())}
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := r.toObject(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			hashKey, ok := key.(object.Hashable)
+			if !ok {
+				return nil, fmt.Errorf("cannot use %s as a map key", iter.Key().Type())
+			}
+			val, err := r.toObject(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			hash.Pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: val}
+		}
+		return hash, nil
+	case reflect.Struct:
+		hash := object.MakeEmptyHash()
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name, ok := fieldName(t.Field(i))
+			if !ok {
+				continue
+			}
+			val, err := r.toObject(v.Field(i))
+			if err != nil {
+				return nil, err
+			}
+			hash.SetKey(name, val)
+		}
+		return hash, nil
+	case reflect.Func:
+		if v.IsNil() {
+			return object.NullConst, nil
+		}
+		return r.wrapFunc(v), nil
+	}
+
+	return nil, fmt.Errorf("cannot convert %s to a Nitrogen object", v.Type())
+}
+
+func fieldName(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+
+	tag := f.Tag.Get("nitrogen")
+	if tag == "-" {
+		return "", false
+	}
+	if tag != "" {
+		return tag, true
+	}
+	return f.Name, true
+}
+
+// wrapFunc creates a builtin function that converts its arguments to the
+// parameter types of fn and the results of fn back to a Nitrogen object.
+// If the last result is a non-nil error, an exception is thrown.
+func (r *Runtime) wrapFunc(fn reflect.Value) *object.Builtin {
+	t := fn.Type()
+	numIn := t.NumIn()
+
+	return &object.Builtin{
+		Fn: func(interpreter object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+			if t.IsVariadic() {
+				if len(args) < numIn-1 {
+					return object.NewException("Func expected at least %d args but was given %d", numIn-1, len(args))
+				}
+			} else if len(args) != numIn {
+				return object.NewException("Func expected %d args but was given %d", numIn, len(args))
+			}
+
+			in := make([]reflect.Value, len(args))
+			for i, arg := range args {
+				var argType reflect.Type
+				if t.IsVariadic() && i >= numIn-1 {
+					argType = t.In(numIn - 1).Elem()
+				} else {
+					argType = t.In(i)
+				}
+
+				val, err := r.fromObject(arg, argType)
+				if err != nil {
+					return object.NewException("argument %d: %s", i+1, err.Error())
+				}
+				in[i] = val
+			}
+
+			out := fn.Call(in)
+
+			if len(out) > 0 && t.Out(len(out)-1) == errorType {
+				if err := out[len(out)-1]; !err.IsNil() {
+					return object.NewException("%s", err.Interface().(error).Error())
+				}
+				out = out[:len(out)-1]
+			}
+
+			switch len(out) {
+			case 0:
+				return object.NullConst
+			case 1:
+				ret, err := r.toObject(out[0])
+				if err != nil {
+					return object.NewException("%s", err.Error())
+				}
+				return ret
+			}
+
+			elements := make([]object.Object, len(out))
+			for i, o := range out {
+				ret, err := r.toObject(o)
+				if err != nil {
+					return object.NewException("%s", err.Error())
+				}
+				elements[i] = ret
+			}
+			return &object.Array{Elements: elements}
+		},
+	}
+}
+
+// FromObject converts obj into the value pointed to by ptr.
+func (r *Runtime) FromObject(obj object.Object, ptr interface{}) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("FromObject expected a non-nil pointer")
+	}
+
+	val, err := r.fromObject(obj, v.Type().Elem())
+	if err != nil {
+		return err
+	}
+	v.Elem().Set(val)
+	return nil
+}
+
+// ToGo converts obj to its natural Go representation. Integers become int64,
+// floats float64, strings string, byte strings []byte, arrays []interface{}
+// and maps map[string]interface{}. Other objects are returned unchanged.
+func (r *Runtime) ToGo(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil
+	case *object.Boolean:
+		return obj.Value
+	case *object.Integer:
+		return obj.Value
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.String()
+	case *object.ByteString:
+		return obj.Value
+	case *object.Error:
+		return errors.New(obj.Message)
+	case *object.Array:
+		s := make([]interface{}, len(obj.Elements))
+		for i, elem := range obj.Elements {
+			s[i] = r.ToGo(elem)
+		}
+		return s
+	case *object.Hash:
+		m := make(map[string]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			m[keyString(pair.Key)] = r.ToGo(pair.Value)
+		}
+		return m
+	}
+	return obj
+}
+
+func keyString(key object.Object) string {
+	if s, ok := key.(*object.String); ok {
+		return s.String()
+	}
+	return key.Inspect()
+}
+
+func (r *Runtime) fromObject(obj object.Object, t reflect.Type) (reflect.Value, error) {
+	if obj == nil {
+		obj = object.NullConst
+	}
+
+	if t == objectType {
+		return reflect.ValueOf(&obj).Elem(), nil
+	}
+	if reflect.TypeOf(obj).AssignableTo(t) && t.Kind() != reflect.Interface {
+		return reflect.ValueOf(obj), nil
+	}
+
+	if obj == object.NullConst {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map, reflect.Func:
+			return reflect.Zero(t), nil
+		}
+	}
+
+	mismatch := func() (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("cannot convert %s to %s", obj.Type(), t)
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		goVal := r.ToGo(obj)
+		if goVal == nil {
+			return reflect.Zero(t), nil
+		}
+		v := reflect.ValueOf(goVal)
+		if !v.Type().AssignableTo(t) {
+			return mismatch()
+		}
+		return v.Convert(t), nil
+	case reflect.Bool:
+		b, ok := obj.(*object.Boolean)
+		if !ok {
+			return mismatch()
+		}
+		return reflect.ValueOf(b.Value).Convert(t), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch()
+		}
+		v := reflect.New(t).Elem()
+		if v.OverflowInt(i.Value) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", i.Value, t)
+		}
+		v.SetInt(i.Value)
+		return v, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch()
+		}
+		v := reflect.New(t).Elem()
+		if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", i.Value, t)
+		}
+		v.SetUint(uint64(i.Value))
+		return v, nil
+	case reflect.Float32, reflect.Float64:
+		v := reflect.New(t).Elem()
+		switch n := obj.(type) {
+		case *object.Float:
+			v.SetFloat(n.Value)
+		case *object.Integer:
+			v.SetFloat(float64(n.Value))
+		default:
+			return mismatch()
+		}
+		return v, nil
+	case reflect.String:
+		switch s := obj.(type) {
+		case *object.String:
+			return reflect.ValueOf(s.String()).Convert(t), nil
+		case *object.ByteString:
+			return reflect.ValueOf(s.String()).Convert(t), nil
+		}
+		return mismatch()
+	case reflect.Ptr:
+		elem, err := r.fromObject(obj, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v := reflect.New(t.Elem())
+		v.Elem().Set(elem)
+		return v, nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			switch s := obj.(type) {
+			case *object.ByteString:
+				return reflect.ValueOf(append([]byte(nil), s.Value...)).Convert(t), nil
+			case *object.String:
+				return reflect.ValueOf([]byte(s.String())).Convert(t), nil
+			}
+		}
+
+		arr, ok := obj.(*object.Array)
+		if !ok {
+			return mismatch()
+		}
+		v := reflect.MakeSlice(t, len(arr.Elements), len(arr.Elements))
+		for i, elem := range arr.Elements {
+			ev, err := r.fromObject(elem, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("index %d: %s", i, err)
+			}
+			v.Index(i).Set(ev)
+		}
+		return v, nil
+	case reflect.Array:
+		arr, ok := obj.(*object.Array)
+		if !ok {
+			return mismatch()
+		}
+		if len(arr.Elements) != t.Len() {
+			return reflect.Value{}, fmt.Errorf("cannot convert array of length %d to %s", len(arr.Elements), t)
+		}
+		v := reflect.New(t).Elem()
+		for i, elem := range arr.Elements {
+			ev, err := r.fromObject(elem, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("index %d: %s", i, err)
+			}
+			v.Index(i).Set(ev)
+		}
+		return v, nil
+	case reflect.Map:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return mismatch()
+		}
+		v := reflect.MakeMapWithSize(t, len(hash.Pairs))
+		for _, pair := range hash.Pairs {
+			kv, err := r.fromObject(pair.Key, t.Key())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("key %s: %s", pair.Key.Inspect(), err)
+			}
+			ev, err := r.fromObject(pair.Value, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("key %s: %s", pair.Key.Inspect(), err)
+			}
+			v.SetMapIndex(kv, ev)
+		}
+		return v, nil
+	case reflect.Struct:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return mismatch()
+		}
+		v := reflect.New(t).Elem()
+		for i := 0; i < t.NumField(); i++ {
+			name, ok := fieldName(t.Field(i))
+			if !ok {
+				continue
+			}
+			fieldObj := lookupField(hash, name)
+			if fieldObj == nil {
+				continue
+			}
+			fv, err := r.fromObject(fieldObj, t.Field(i).Type)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("field %s: %s", name, err)
+			}
+			v.Field(i).Set(fv)
+		}
+		return v, nil
+	case reflect.Func:
+		switch obj.(type) {
+		case *vm.VMFunction, *vm.BoundMethod, *object.Builtin:
+		default:
+			return mismatch()
+		}
+		return r.makeFunc(obj, t), nil
+	}
+
+	return mismatch()
+}
+
+// lookupField finds a struct field in a map. An exact match is preferred, but
+// a field named Name will also match a key named name.
+func lookupField(hash *object.Hash, name string) object.Object {
+	if val := hash.LookupKey(name); val != nil {
+		return val
+	}
+	for _, pair := range hash.Pairs {
+		if key, ok := pair.Key.(*object.String); ok && strings.EqualFold(key.String(), name) {
+			return pair.Value
+		}
+	}
+	return nil
+}
+
+// makeFunc creates a Go function of type t that calls the Nitrogen function fn.
+// If t returns an error as its last result, exceptions thrown by fn are returned
+// as an *Exception error, otherwise they cause a panic.
+func (r *Runtime) makeFunc(fn object.Object, t reflect.Type) reflect.Value {
+	returnsErr := t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType
+
+	return reflect.MakeFunc(t, func(in []reflect.Value) []reflect.Value {
+		out := make([]reflect.Value, t.NumOut())
+		fail := func(err error) []reflect.Value {
+			if !returnsErr {
+				panic(err)
+			}
+			for i := 0; i < len(out)-1; i++ {
+				out[i] = reflect.Zero(t.Out(i))
+			}
+			out[len(out)-1] = reflect.ValueOf(&err).Elem()
+			return out
+		}
+
+		args := make([]object.Object, 0, len(in))
+		for i, v := range in {
+			if t.IsVariadic() && i == len(in)-1 {
+				for j := 0; j < v.Len(); j++ {
+					arg, err := r.toObject(v.Index(j))
+					if err != nil {
+						return fail(err)
+					}
+					args = append(args, arg)
+				}
+				break
+			}
+
+			arg, err := r.toObject(v)
+			if err != nil {
+				return fail(err)
+			}
+			args = append(args, arg)
+		}
+
+		ret := r.machine.Call(fn, args...)
+		if exc, ok := ret.(*object.Exception); ok {
+			return fail(&Exception{Message: exc.Message})
+		}
+
+		numOut := len(out)
+		if returnsErr {
+			numOut--
+			out[numOut] = reflect.Zero(errorType)
+		}
+		if numOut == 1 {
+			val, err := r.fromObject(ret, t.Out(0))
+			if err != nil {
+				return fail(err)
+			}
+			out[0] = val
+		} else if numOut > 1 {
+			arr, ok := ret.(*object.Array)
+			if !ok || len(arr.Elements) != numOut {
+				return fail(fmt.Errorf("expected function to return an array of %d values", numOut))
+			}
+			for i := 0; i < numOut; i++ {
+				val, err := r.fromObject(arr.Elements[i], t.Out(i))
+				if err != nil {
+					return fail(err)
+				}
+				out[i] = val
+			}
+		}
+		return out
+	})
+}
@@ -0,0 +1,265 @@
+// Package nitrogen provides an API for embedding the Nitrogen language in Go
+// host applications.
+package nitrogen
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nitrogen-lang/nitrogen/src/compiler"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/compile"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm"
+	"github.com/nitrogen-lang/nitrogen/src/lexer"
+	"github.com/nitrogen-lang/nitrogen/src/moduleutils"
+	"github.com/nitrogen-lang/nitrogen/src/parser"
+
+	_ "github.com/nitrogen-lang/nitrogen/src/builtins"
+)
+
+// Options configures a new Runtime. The zero value is usable.
+type Options struct {
+	// SearchPaths is the list of directories used to find imported modules.
+	// If empty, the paths in NITROGEN_MODULES and the working directory are used.
+	SearchPaths []string
+
+	// Env is exposed to scripts through std/os.env. If nil, the environment
+	// of the host process is used.
+	Env map[string]string
+
+	// Server populates the _SERVER global. If nil, an empty map is used.
+	Server map[string]string
+
+	// Registry provides the natives, builtins and modules available to scripts.
+	// Each Runtime gets its own registry layered on top of this one so natives
+	// can be changed per Runtime. The default is vm.DefaultRegistry.
+	Registry *vm.Registry
+
+	// Preamble is the module imported before any script is run. The default
+	// is std/preamble/main. NoPreamble disables importing it altogether.
+	Preamble   string
+	NoPreamble bool
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	Debug bool
+}
+
+// Runtime is a single embedded Nitrogen virtual machine. Scripts evaluated
+// in the same Runtime share a global scope. A Runtime must not be used
+// concurrently from multiple goroutines.
+type Runtime struct {
+	machine *vm.VirtualMachine
+	globals *object.Environment
+	scope   *object.Environment
+}
+
+// NewRuntime creates a Runtime configured with opts. A nil opts uses the defaults.
+func NewRuntime(opts *Options) (*Runtime, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	searchPaths := opts.SearchPaths
+	if len(searchPaths) == 0 {
+		searchPaths = defaultSearchPaths()
+	}
+
+	server := object.MakeEmptyHash()
+	if opts.Server != nil {
+		server = object.StringMapToHash(opts.Server)
+	}
+
+	globals := object.NewEnvironment()
+	globals.CreateConst("_SERVER", server)
+	globals.Create("_SEARCH_PATHS", object.MakeStringArray(searchPaths))
+
+	settings := vm.NewSettings()
+	settings.Debug = opts.Debug
+	if opts.Stdin != nil {
+		settings.Stdin = opts.Stdin
+	}
+	if opts.Stdout != nil {
+		settings.Stdout = opts.Stdout
+	}
+	if opts.Stderr != nil {
+		settings.Stderr = opts.Stderr
+	}
+
+	parent := opts.Registry
+	if parent == nil {
+		parent = vm.DefaultRegistry
+	}
+
+	machine := vm.NewVM(settings)
+	machine.SetGlobalEnv(globals)
+	machine.SetRegistry(vm.NewRegistry(parent))
+
+	env := opts.Env
+	if env == nil {
+		env = processEnv()
+	}
+	machine.SetInstanceVar("os.env", object.StringMapToHash(env))
+
+	if !opts.NoPreamble {
+		if err := machine.ImportPreamble(opts.Preamble); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Runtime{
+		machine: machine,
+		globals: globals,
+		scope:   object.NewEnclosedEnv(globals),
+	}, nil
+}
+
+// Machine returns the underlying virtual machine.
+func (r *Runtime) Machine() *vm.VirtualMachine { return r.machine }
+
+// Registry returns the registry used by this Runtime. Natives are bound when a
+// module is imported, so changes only affect modules imported afterwards.
+func (r *Runtime) Registry() *vm.Registry { return r.machine.Registry() }
+
+// Eval compiles and runs src. The returned object is the value of a top level
+// return statement, or nil. Uncaught exceptions are returned as an *Exception error.
+func (r *Runtime) Eval(src string) (object.Object, error) {
+	p := parser.New(lexer.NewString(src), moduleutils.ParserSettings)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+	for _, w := range p.Warnings() {
+		fmt.Fprintln(r.machine.GetStderr(), w)
+	}
+
+	return r.run(compiler.Compile(program, "__main"))
+}
+
+// RunFile compiles and runs the script at path.
+func (r *Runtime) RunFile(path string) (object.Object, error) {
+	code, err := moduleutils.CodeBlockCache.GetBlock(path, "__main")
+	if err != nil {
+		return nil, err
+	}
+
+	return r.run(code)
+}
+
+func (r *Runtime) run(code *compile.CodeBlock) (object.Object, error) {
+	r.globals.SetForce("_FILE", object.MakeStringObj(code.Filename), true)
+
+	ret, err := r.machine.Execute(code, r.scope, "__main")
+	if err != nil {
+		return ret, err
+	}
+	if exc, ok := ret.(*object.Exception); ok {
+		return nil, &Exception{Message: exc.Message}
+	}
+	return ret, nil
+}
+
+// Get returns the value of a variable defined by a script or registered by the host.
+func (r *Runtime) Get(name string) (object.Object, bool) {
+	return r.scope.Get(name)
+}
+
+// Set defines or overwrites a global variable visible to all scripts. The value
+// is converted with ToObject.
+func (r *Runtime) Set(name string, val interface{}) error {
+	obj, err := r.ToObject(val)
+	if err != nil {
+		return err
+	}
+	r.globals.SetForce(name, obj, false)
+	return nil
+}
+
+// Register makes fn available to scripts as a global function named name. fn
+// can be an object.BuiltinFunction or any Go function, in which case its arguments
+// and return values are converted automatically. Registered functions are local
+// to this Runtime.
+func (r *Runtime) Register(name string, fn interface{}) error {
+	var builtin *object.Builtin
+
+	switch fn := fn.(type) {
+	case object.BuiltinFunction:
+		builtin = &object.Builtin{Fn: fn}
+	case func(object.Interpreter, *object.Environment, ...object.Object) object.Object:
+		builtin = &object.Builtin{Fn: fn}
+	default:
+		obj, err := r.ToObject(fn)
+		if err != nil {
+			return err
+		}
+		b, ok := obj.(*object.Builtin)
+		if !ok {
+			return fmt.Errorf("Register expected a function, got %T", fn)
+		}
+		builtin = b
+	}
+
+	r.globals.SetForce(name, builtin, true)
+	return nil
+}
+
+// Call calls the function named fnName with args converted by ToObject.
+func (r *Runtime) Call(fnName string, args ...interface{}) (object.Object, error) {
+	fn, ok := r.scope.Get(fnName)
+	if !ok {
+		return nil, fmt.Errorf("function %s is not defined", fnName)
+	}
+
+	return r.CallValue(fn, args...)
+}
+
+// CallValue calls fn, which must be a callable Nitrogen object, with args converted by ToObject.
+func (r *Runtime) CallValue(fn object.Object, args ...interface{}) (object.Object, error) {
+	objArgs := make([]object.Object, len(args))
+	for i, arg := range args {
+		obj, err := r.ToObject(arg)
+		if err != nil {
+			return nil, err
+		}
+		objArgs[i] = obj
+	}
+
+	ret := r.machine.Call(fn, objArgs...)
+	if exc, ok := ret.(*object.Exception); ok {
+		return nil, &Exception{Message: exc.Message}
+	}
+	return ret, nil
+}
+
+// Exception is returned when a script raises an uncaught exception.
+type Exception struct {
+	Message string
+}
+
+func (e *Exception) Error() string { return e.Message }
+
+func defaultSearchPaths() []string {
+	var paths []string
+	if envModPath := os.Getenv("NITROGEN_MODULES"); envModPath != "" {
+		paths = append(paths, strings.Split(envModPath, ":")...)
+	}
+	if pwd, err := os.Getwd(); err == nil {
+		paths = append(paths, pwd)
+	}
+	return paths
+}
+
+func processEnv() map[string]string {
+	env := os.Environ()
+	m := make(map[string]string, len(env))
+	for _, v := range env {
+		val := strings.SplitN(v, "=", 2)
+		m[val[0]] = val[1]
+	}
+	return m
+}
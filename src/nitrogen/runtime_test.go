@@ -0,0 +1,284 @@
+package nitrogen
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/vm"
+	"github.com/nitrogen-lang/nitrogen/src/moduleutils"
+)
+
+func init() {
+	moduleutils.WriteCompiledScripts = false
+}
+
+func newTestRuntime(t *testing.T) (*Runtime, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	r, err := NewRuntime(&Options{
+		SearchPaths: []string{"../../nitrogen"},
+		Env:         map[string]string{"GREETING": "hello"},
+		Stdout:      out,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, out
+}
+
+func TestEvalAndCall(t *testing.T) {
+	r, out := newTestRuntime(t)
+
+	_, err := r.Eval(`
+import "std/os"
+fn add(a, b) { a + b }
+println(os.env()["GREETING"])
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "hello\n" {
+		t.Fatalf("Wrong output. Got %q", out.String())
+	}
+
+	ret, err := r.Call("add", 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ToGo(ret) != int64(5) {
+		t.Fatalf("Wrong return value. Got %s", ret.Inspect())
+	}
+
+	if _, err := r.Call("missing"); err == nil {
+		t.Fatal("Expected error calling undefined function")
+	}
+}
+
+func TestEvalException(t *testing.T) {
+	r, _ := newTestRuntime(t)
+
+	_, err := r.Eval(`oops()`)
+	var exc *Exception
+	if !errors.As(err, &exc) {
+		t.Fatalf("Expected an exception, got %v", err)
+	}
+	if !strings.Contains(exc.Message, "oops") {
+		t.Fatalf("Wrong exception message %q", exc.Message)
+	}
+
+	// The runtime is still usable after an exception
+	ret, err := r.Eval(`return 42`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ToGo(ret) != int64(42) {
+		t.Fatalf("Wrong return value. Got %s", ret.Inspect())
+	}
+}
+
+type person struct {
+	Name    string
+	Age     int
+	Tags    []string
+	private int
+	Skip    bool   `nitrogen:"-"`
+	Email   string `nitrogen:"email"`
+}
+
+func TestConversion(t *testing.T) {
+	r, _ := newTestRuntime(t)
+
+	p := person{Name: "Alice", Age: 30, Tags: []string{"a", "b"}, Email: "a@example.com"}
+	if err := r.Set("person", p); err != nil {
+		t.Fatal(err)
+	}
+
+	ret, err := r.Eval(`return person.email + " " + toString(person.Age) + " " + person.Tags[1]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ToGo(ret) != "a@example.com 30 b" {
+		t.Fatalf("Wrong return value. Got %s", ret.Inspect())
+	}
+
+	ret, err = r.Eval(`return {"Name": "Bob", "age": 41, "Tags": ["x"], "email": "b@example.com"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var p2 person
+	if err := r.FromObject(ret, &p2); err != nil {
+		t.Fatal(err)
+	}
+	if p2.Name != "Bob" || p2.Age != 41 || len(p2.Tags) != 1 || p2.Email != "b@example.com" {
+		t.Fatalf("Wrong struct value %#v", p2)
+	}
+
+	var m map[string]int
+	if err := r.FromObject(ret, &m); err == nil {
+		t.Fatal("Expected conversion error")
+	}
+}
+
+type structError struct {
+	msg string
+}
+
+func (e structError) Error() string { return e.msg }
+
+func TestConvertError(t *testing.T) {
+	r, _ := newTestRuntime(t)
+
+	obj, err := r.ToObject(structError{msg: "failed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Type() != object.ErrorObj || obj.(*object.Error).Message != "failed" {
+		t.Fatalf("Wrong error value. Got %s", obj.Inspect())
+	}
+
+	var nilErr *structError
+	obj, err = r.ToObject(nilErr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj != object.NullConst {
+		t.Fatalf("Expected nil, got %s", obj.Inspect())
+	}
+}
+
+func TestGetBeforeEval(t *testing.T) {
+	r, _ := newTestRuntime(t)
+
+	if err := r.Set("answer", 42); err != nil {
+		t.Fatal(err)
+	}
+
+	val, ok := r.Get("answer")
+	if !ok {
+		t.Fatal("Expected host global to be visible before Eval")
+	}
+	if r.ToGo(val) != int64(42) {
+		t.Fatalf("Wrong value. Got %s", val.Inspect())
+	}
+}
+
+func TestRegisterHostFunction(t *testing.T) {
+	r, _ := newTestRuntime(t)
+	r2, _ := newTestRuntime(t)
+
+	err := r.Register("sum", func(nums ...int) int {
+		total := 0
+		for _, n := range nums {
+			total += n
+		}
+		return total
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Register("fail", func() error { return errors.New("host failure") })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ret, err := r.Eval(`return sum(1, 2, 3)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ToGo(ret) != int64(6) {
+		t.Fatalf("Wrong return value. Got %s", ret.Inspect())
+	}
+
+	ret, err = r.Eval(`return "caught: " + toString(recover { fail() })`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.Inspect() != "caught: host failure" {
+		t.Fatalf("Wrong exception. Got %s", ret.Inspect())
+	}
+
+	// Functions are local to a runtime
+	if _, err := r2.Eval(`return sum(1, 2)`); err == nil {
+		t.Fatal("Expected error calling function registered in another runtime")
+	}
+}
+
+func TestGoFuncFromScript(t *testing.T) {
+	r, _ := newTestRuntime(t)
+
+	fnObj, err := r.Eval(`return fn(a, b) { a * b }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mul func(int, int) (int, error)
+	if err := r.FromObject(fnObj, &mul); err != nil {
+		t.Fatal(err)
+	}
+	n, err := mul(6, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 42 {
+		t.Fatalf("Wrong return value. Got %d", n)
+	}
+
+	err = r.Register("apply", func(f func(string) string, s string) string {
+		return f(s)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ret, err := r.Eval(`return apply(fn(s) { s + "!" }, "hi")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := ret.(*object.String); !ok || s.String() != "hi!" {
+		t.Fatalf("Wrong return value. Got %s", ret.Inspect())
+	}
+}
+
+func TestRuntimeRegistry(t *testing.T) {
+	reg := vm.NewRegistry(vm.DefaultRegistry)
+	reg.RegisterNative("std.runtime.osName", func(i object.Interpreter, env *object.Environment, args ...object.Object) object.Object {
+		return object.MakeStringObj("nitrogenOS")
+	})
+
+	r, err := NewRuntime(&Options{
+		SearchPaths: []string{"../../nitrogen"},
+		Registry:    reg,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := newTestRuntime(t)
+
+	ret, err := r.Eval(`import "std/runtime"
+return runtime.osName()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ToGo(ret) != "nitrogenOS" {
+		t.Fatalf("Wrong return value. Got %s", ret.Inspect())
+	}
+
+	ret, err = plain.Eval(`import "std/runtime"
+return runtime.osName()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain.ToGo(ret) == "nitrogenOS" {
+		t.Fatal("Registry override leaked into another runtime")
+	}
+
+	removed, _ := newTestRuntime(t)
+	removed.Registry().RemoveNative("std.runtime.osArch")
+	_, err = removed.Eval(`import "std/runtime"`)
+	if err == nil || !strings.Contains(err.Error(), "Native function not implemented std.runtime.osArch") {
+		t.Fatalf("Expected removed native to be unavailable, got %v", err)
+	}
+}
@@ -27,20 +27,25 @@ func (p *Parser) parseStatement() ast.Statement {
 	case token.Interface:
 		return p.parseInterfaceDefStatement()
 	case token.For:
-		return p.parseForLoop()
+		return p.parseForLoop(nil)
 	case token.While:
-		return p.parseWhileLoop()
+		return p.parseWhileLoop(nil)
 	case token.Loop:
-		return p.parseInfiniteLoop()
+		return p.parseInfiniteLoop(nil)
 	case token.Import:
 		return p.parseImport()
 	case token.Delete:
 		return p.parseDelete()
+	case token.Defer:
+		return p.parseDeferStatement()
+	case token.With:
+		return p.parseWithStatement()
 	case token.Use:
 		return p.parseUseStatement()
 	case token.Continue:
 		stat := &ast.ContinueStatement{
 			Token: p.curToken,
+			Label: p.parseLoopLabel("continue"),
 		}
 		if p.peekTokenIs(token.Semicolon) {
 			p.nextToken()
@@ -49,6 +54,7 @@ func (p *Parser) parseStatement() ast.Statement {
 	case token.Break:
 		stat := &ast.BreakStatement{
 			Token: p.curToken,
+			Label: p.parseLoopLabel("break"),
 		}
 		if p.peekTokenIs(token.Semicolon) {
 			p.nextToken()
@@ -70,6 +76,10 @@ func (p *Parser) parseStatement() ast.Statement {
 			p.nextToken()
 		}
 		return stat
+	case token.Identifier:
+		if p.peekTokenIs(token.Colon) {
+			return p.parseLabeledLoop()
+		}
 	case token.EOF:
 		panic("Something messed up big time")
 	}
@@ -202,6 +212,10 @@ func (p *Parser) parseDefStatement() ast.Statement {
 
 	stmt.Const = p.curTokenIs(token.Const)
 
+	if p.peekTokenIs(token.LSquare, token.LBrace) {
+		return p.parseDestructureDef(stmt)
+	}
+
 	if !p.expectPeek(token.Identifier) {
 		return nil
 	}
@@ -248,6 +262,40 @@ func (p *Parser) parseDefStatement() ast.Statement {
 	return stmt
 }
 
+// parseDestructureDef parses a let or const statement that unpacks an array
+// or map such as let [a, b] = value.
+func (p *Parser) parseDestructureDef(def *ast.DefStatement) ast.Statement {
+	stmt := &ast.DestructureStatement{
+		Token:  def.Token,
+		Define: true,
+		Const:  def.Const,
+		Export: def.Export,
+	}
+
+	p.nextToken()
+	stmt.Pattern = p.parseDestructurePattern()
+	if stmt.Pattern == nil || !p.checkBindings(patternBindings(stmt.Pattern, nil)) {
+		return nil
+	}
+
+	if !p.expectPeek(token.Assign) {
+		return nil
+	}
+	p.nextToken()
+
+	value := p.parseExpression(priLowest)
+	if value == nil {
+		return nil
+	}
+	stmt.Value = value.(ast.Expression)
+
+	if p.peekTokenIs(token.Semicolon) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseReturnStatement() ast.Statement {
 	if p.settings.Debug {
 		fmt.Println("parseReturnStatement")
@@ -278,6 +326,35 @@ func (p *Parser) parseReturnStatement() ast.Statement {
 	return stmt
 }
 
+func (p *Parser) parseDeferStatement() ast.Statement {
+	if p.settings.Debug {
+		fmt.Println("parseDeferStatement")
+	}
+	stmt := &ast.DeferStatement{Token: p.curToken}
+	p.nextToken()
+
+	exp, ok := p.parseExpression(priLowest).(ast.Expression)
+	if !ok {
+		return nil
+	}
+
+	call, ok := exp.(*ast.CallExpression)
+	if !ok {
+		p.addErrorWithPos(stmt.Token.Pos, "defer requires a function call, got %s", exp.String())
+		return nil
+	}
+	if ast.HasOptionalLink(call) {
+		p.addErrorWithPos(stmt.Token.Pos, "Can't defer an optional chain")
+		return nil
+	}
+	stmt.Call = call
+
+	if p.peekTokenIs(token.Semicolon) {
+		p.nextToken()
+	}
+	return stmt
+}
+
 func (p *Parser) parseFuncDefStatement() ast.Statement {
 	if p.settings.Debug {
 		fmt.Println("parseFuncDefStatement")
@@ -410,6 +487,11 @@ func (p *Parser) parseAssignmentStatement(left ast.Expression) ast.Node {
 		Left:  left,
 	}
 
+	if ast.HasOptionalLink(left) {
+		p.addErrorWithCurPos("Can't assign to an optional chain")
+		return nil
+	}
+
 	p.nextToken()
 
 	var ok bool
@@ -419,6 +501,27 @@ func (p *Parser) parseAssignmentStatement(left ast.Expression) ast.Node {
 		return nil
 	}
 
+	switch left.(type) {
+	case *ast.Array, *ast.HashLiteral:
+		pattern := exprToPattern(left)
+		if pattern == nil {
+			p.addErrorWithPos(stmt.Token.Pos, "Can't destructure into %s", left.String())
+			return nil
+		}
+		if !p.checkBindings(patternBindings(pattern, nil)) {
+			return nil
+		}
+
+		if p.peekTokenIs(token.Semicolon) {
+			p.nextToken()
+		}
+		return &ast.DestructureStatement{
+			Token:   stmt.Token,
+			Pattern: pattern,
+			Value:   stmt.Value,
+		}
+	}
+
 	if p.peekTokenIs(token.Semicolon) {
 		p.nextToken()
 	}
@@ -438,6 +541,11 @@ func (p *Parser) parseCompoundAssign(left ast.Expression) ast.Node {
 		Left:  left,
 	}
 
+	if ast.HasOptionalLink(left) {
+		p.addErrorWithCurPos("Can't assign to an optional chain")
+		return nil
+	}
+
 	p.nextToken()
 
 	right := p.parseExpression(priLowest).(ast.Expression)
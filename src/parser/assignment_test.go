@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/nitrogen-lang/nitrogen/src/ast"
@@ -243,3 +244,93 @@ func TestImportWithAs(t *testing.T) {
 		t.Fatalf("import name is not correct. Expected \"http2\", got %s", imp.Name.String())
 	}
 }
+
+func TestDestructureStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		define   bool
+	}{
+		{"let [a, b] = f();", "let [a, b] = f();", true},
+		{"const {name, age} = m", "const {name: name, age: age} = m;", true},
+		{"let [a, [b, _], ...c] = x", "let [a, [b, _], ...c] = x;", true},
+		{`let {"k": [a, b]} = x`, "let {k: [a, b]} = x;", true},
+		{"[a, b] = [b, a]", "[a, b] = [b, a];", false},
+		{`[a, {"k": b}] = x`, "[a, {k: b}] = x;", false},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewString(tt.input)
+		p := New(l, nil)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.DestructureStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.DestructureStatement. got=%T", program.Statements[0])
+		}
+
+		if stmt.Define != tt.define {
+			t.Errorf("%s: Define wrong. want=%t, got=%t", tt.input, tt.define, stmt.Define)
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("statement wrong. want=%q, got=%q", tt.expected, stmt.String())
+		}
+	}
+}
+
+func TestDestructureErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"let [1, a] = x", "1 can't be used in a destructuring pattern"},
+		{"let [a, 1 | 2] = x", "1 | 2 can't be used in a destructuring pattern"},
+		{"let [a, a] = x", "a is bound more than once"},
+		{"let [a, b]", `Expected "="`},
+		{"[a, 1] = x", "Can't destructure into [a, 1]"},
+		{"[a, a] = x", "a is bound more than once"},
+		{"for k, [k] in x { pass }", "k is bound more than once"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewString(tt.input)
+		p := New(l, nil)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if !strings.Contains(p.Errors()[0], tt.err) {
+			t.Errorf("%s: expected error %q, got %q", tt.input, tt.err, p.Errors()[0])
+		}
+	}
+}
+
+func TestOptionalChainAssignment(t *testing.T) {
+	tests := []string{
+		"a?.b = 1",
+		"a.b?[c].d = 1",
+		"a?.b += 1",
+	}
+
+	for _, input := range tests {
+		l := lexer.NewString(input)
+		p := New(l, nil)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("%s: expected an error", input)
+			continue
+		}
+		if !strings.Contains(p.Errors()[0], "Can't assign to an optional chain") {
+			t.Errorf("%s: wrong error, got %q", input, p.Errors()[0])
+		}
+	}
+}
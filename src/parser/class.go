@@ -31,7 +31,10 @@ func (p *Parser) parseClassLiteral() ast.Expression {
 		return nil
 	}
 
+	inClassBody, loops := p.inClassBody, p.loops
+	p.inClassBody, p.loops = true, nil
 	body := p.parseBlockStatements()
+	p.inClassBody, p.loops = inClassBody, loops
 
 	for _, statement := range body.Statements {
 		def, ok := statement.(*ast.DefStatement)
@@ -123,7 +126,7 @@ func (p *Parser) parseInterfaceLiteral() ast.Expression {
 			return nil
 		}
 
-		params := p.parseFunctionParameters()
+		params, _, _ := p.parseFunctionParameters()
 		ifaceMeth.Params = make([]string, len(params))
 		for i, p := range params {
 			ifaceMeth.Params[i] = p.String()
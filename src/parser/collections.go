@@ -12,10 +12,73 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 		fmt.Println("parseArrayLiteral")
 	}
 	array := &ast.Array{Token: p.curToken}
-	array.Elements = p.parseExpressionList(token.RSquare)
+	if p.peekTokenIs(token.RSquare) {
+		p.nextToken()
+		array.Elements = []ast.Expression{}
+		return array
+	}
+
+	p.nextToken()
+	first, ok := p.parseExpression(priLowest).(ast.Expression)
+	if !ok {
+		return nil
+	}
+
+	if p.skipNewlineBefore(token.For) {
+		clauses := p.parseComprehensionClauses(token.RSquare)
+		if clauses == nil {
+			return nil
+		}
+		return &ast.ArrayComprehension{
+			Token:   array.Token,
+			Element: first,
+			Clauses: clauses,
+		}
+	}
+
+	array.Elements = p.parseExpressionListRest(first, token.RSquare)
 	return array
 }
 
+// parseComprehensionClauses parses the for and if clauses of a comprehension
+// and its closing token. peekToken is the first 'for'.
+func (p *Parser) parseComprehensionClauses(end token.TokenType) []*ast.ComprehensionClause {
+	if p.settings.Debug {
+		fmt.Println("parseComprehensionClauses")
+	}
+	var clauses []*ast.ComprehensionClause
+
+	for p.skipNewlineBefore(token.For) {
+		p.nextToken()
+		clause := &ast.ComprehensionClause{Token: p.curToken}
+		p.nextToken()
+
+		var ok bool
+		clause.Key, clause.Value, clause.Iter, ok = p.parseIterHead()
+		if !ok {
+			return nil
+		}
+
+		for p.skipNewlineBefore(token.If) {
+			p.nextToken()
+			p.nextToken()
+			filter, ok := p.parseExpression(priLowest).(ast.Expression)
+			if !ok {
+				return nil
+			}
+			clause.Filters = append(clause.Filters, filter)
+		}
+
+		clauses = append(clauses, clause)
+	}
+
+	p.skipNewlineBefore(end)
+	if !p.expectPeek(end) {
+		return nil
+	}
+	return clauses
+}
+
 func (p *Parser) parseHashLiteral() ast.Expression {
 	if p.settings.Debug {
 		fmt.Println("parseHashLiteral")
@@ -55,6 +118,19 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 			return nil
 		}
 
+		if len(hash.Pairs) == 0 && p.skipNewlineBefore(token.For) {
+			clauses := p.parseComprehensionClauses(token.RBrace)
+			if clauses == nil {
+				return nil
+			}
+			return &ast.MapComprehension{
+				Token:   hash.Token,
+				Key:     keyExp,
+				Value:   valueExp,
+				Clauses: clauses,
+			}
+		}
+
 		hash.Pairs[keyExp] = valueExp
 
 		if p.peekToken.Type == token.Semicolon {
@@ -79,10 +155,67 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Node {
 	if p.settings.Debug {
 		fmt.Println("parseIndexExpression")
 	}
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	exp := &ast.IndexExpression{
+		Token:    p.curToken,
+		Left:     left,
+		Optional: p.curTokenIs(token.OptionalIndex),
+	}
 
 	p.nextToken()
-	exp.Index = p.parseExpression(priLowest).(ast.Expression)
+	if p.curTokenIs(token.Colon) {
+		return p.parseSliceExpression(exp)
+	}
+
+	index, ok := p.parseExpression(priLowest).(ast.Expression)
+	if !ok {
+		return nil
+	}
+	exp.Index = index
+
+	if p.peekTokenIs(token.Colon) {
+		p.nextToken()
+		return p.parseSliceExpression(exp)
+	}
+
+	if !p.expectPeek(token.RSquare) {
+		return nil
+	}
+	return exp
+}
+
+// parseSliceExpression parses the rest of a slice such as a[1:3] or a[::2].
+// curToken is the first colon, the start of the slice is index.Index.
+func (p *Parser) parseSliceExpression(index *ast.IndexExpression) ast.Node {
+	if p.settings.Debug {
+		fmt.Println("parseSliceExpression")
+	}
+	exp := &ast.SliceExpression{
+		Token:    index.Token,
+		Left:     index.Left,
+		Start:    index.Index,
+		Optional: index.Optional,
+	}
+
+	parseBound := func() (ast.Expression, bool) {
+		if p.peekTokenIs(token.Colon, token.RSquare) {
+			return nil, true
+		}
+		p.nextToken()
+		bound, ok := p.parseExpression(priLowest).(ast.Expression)
+		return bound, ok
+	}
+
+	var ok bool
+	if exp.Stop, ok = parseBound(); !ok {
+		return nil
+	}
+
+	if p.peekTokenIs(token.Colon) {
+		p.nextToken()
+		if exp.Step, ok = parseBound(); !ok {
+			return nil
+		}
+	}
 
 	if !p.expectPeek(token.RSquare) {
 		return nil
@@ -94,25 +227,58 @@ func (p *Parser) parseAttributeExpression(left ast.Expression) ast.Node {
 	if p.settings.Debug {
 		fmt.Println("parseAttributeExpression")
 	}
-	exp := &ast.AttributeExpression{Token: p.curToken, Left: left}
+	exp := &ast.AttributeExpression{
+		Token:    p.curToken,
+		Left:     left,
+		Optional: p.curTokenIs(token.OptionalDot),
+	}
 
 	p.nextToken()
-	i := p.parseExpression(priAssign)
 
-	ident, ok := i.(*ast.Identifier)
-	if !ok {
-		p.addErrorWithCurPos("Attribute operator requires an identifier")
-		return nil
+	var name string
+	if p.curToken.Type.IsKeyword() {
+		// Keywords are allowed as attribute names, ex. regex.match
+		name = p.curToken.Literal
+	} else {
+		i := p.parseExpression(priAssign)
+
+		ident, ok := i.(*ast.Identifier)
+		if !ok {
+			p.addErrorWithCurPos("Attribute operator requires an identifier")
+			return nil
+		}
+		name = ident.Value
 	}
+
 	// Convert identifier into a string for later lookup
 	exp.Index = &ast.StringLiteral{
 		Token: token.Token{
 			Type:    token.String,
-			Literal: ident.Value,
+			Literal: name,
 			Pos:     p.curToken.Pos,
 		},
-		Value: []rune(ident.Value),
+		Value: []rune(name),
 	}
 
 	return exp
 }
+
+// parseOptionalAttribute parses a?.b or an optional call a?.(), both evaluate
+// to nil without going further if a is nil.
+func (p *Parser) parseOptionalAttribute(left ast.Expression) ast.Node {
+	if p.settings.Debug {
+		fmt.Println("parseOptionalAttribute")
+	}
+
+	if !p.peekTokenIs(token.LParen) {
+		return p.parseAttributeExpression(left)
+	}
+	p.nextToken()
+
+	return &ast.CallExpression{
+		Token:     p.curToken,
+		Function:  left,
+		Arguments: p.parseCallArguments(),
+		Optional:  true,
+	}
+}
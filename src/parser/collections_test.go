@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/nitrogen-lang/nitrogen/src/ast"
@@ -51,6 +52,114 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[1:3]", "(a[1:3])"},
+		{"a[:-1]", "(a[:(-1)])"},
+		{"a[1:]", "(a[1:])"},
+		{"a[:]", "(a[:])"},
+		{"a[::2]", "(a[::2])"},
+		{"a[1:x + 1:-1]", "(a[1:(x + 1):(-1)])"},
+		{"a?[1:]", "(a?[1:])"},
+		{"a[1:][0]", "((a[1:])[0])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewString(tt.input)
+		p := New(l, nil)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestSliceAssignment(t *testing.T) {
+	input := "a[1:3] = [x]"
+	l := lexer.NewString(input)
+	p := New(l, nil)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.AssignStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.AssignStatement. got=%T", program.Statements[0])
+	}
+	slice, ok := stmt.Left.(*ast.SliceExpression)
+	if !ok {
+		t.Fatalf("left not *ast.SliceExpression. got=%T", stmt.Left)
+	}
+	testIntegerLiteral(t, slice.Start, 1)
+	testIntegerLiteral(t, slice.Stop, 3)
+	if slice.Step != nil {
+		t.Errorf("slice.Step not nil. got=%s", slice.Step)
+	}
+}
+
+func TestParsingComprehensions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[x * 2 for x in items]", "[(x * 2) for x in items]"},
+		{"[x for x in items if x > 0]", "[x for x in items if (x > 0)]"},
+		{"[v for i, v in items if i > 0 if v]", "[v for i, v in items if (i > 0) if v]"},
+		{"[y for x in a for y in x]", "[y for x in a for y in x]"},
+		{"[a for [a, _] in pairs]", "[a for [a, _] in pairs]"},
+		{"{k: v for k, v in pairs}", "{k: v for k, v in pairs}"},
+		{"[x\nfor x in items\nif x\n]", "[x for x in items if x]"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewString(tt.input)
+		p := New(l, nil)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q: expected 1 statement, got %d", tt.input, len(program.Statements))
+		}
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestComprehensionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[x for x items]", `Expected "in"`},
+		{"[x for x in items if]", `Invalid prefix: "]"`},
+		{"[x for [x, x] in items]", "x is bound more than once in the pattern"},
+		{"{k: 1, k2: 2 for k in items}", "Invalid hash literal"},
+		{"[x for x in items", `Expected "]"`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewString(tt.input)
+		p := New(l, nil)
+		p.ParseProgram()
+
+		found := false
+		for _, err := range p.Errors() {
+			if strings.Contains(err, tt.expected) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("%q: expected error containing %q, got %v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
 func TestParsingHashLiteralsStringKeys(t *testing.T) {
 	input := `{"one": 1, "two": 2, "three": 3}`
 
@@ -256,6 +365,36 @@ func TestHashDotOperator(t *testing.T) {
 	}
 }
 
+func TestKeywordAttribute(t *testing.T) {
+	input := `re.match(s);`
+
+	l := lexer.NewString(input)
+	p := New(l, nil)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	call, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("exp is not ast.CallExpression. got=%T", stmt.Expression)
+	}
+
+	exp, ok := call.Function.(*ast.AttributeExpression)
+	if !ok {
+		t.Fatalf("exp is not ast.AttributeExpression. got=%T", call.Function)
+	}
+
+	if exp.Index.String() != "match" {
+		t.Fatalf("exp index is not correct. expected=match, got=%q",
+			exp.Index.String())
+	}
+}
+
 func TestParsingHashLiteralsMultiLine(t *testing.T) {
 	input := `{
                 "one": 1,
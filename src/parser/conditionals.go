@@ -53,12 +53,13 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
-func (p *Parser) parseForLoop() ast.Statement {
+func (p *Parser) parseForLoop(label *ast.Identifier) ast.Statement {
 	if p.settings.Debug {
 		fmt.Println("parseForLoop")
 	}
 	loop := &ast.LoopStatement{
 		Token: p.curToken,
+		Label: label,
 	}
 	expectClosingParen := false
 
@@ -67,6 +68,11 @@ func (p *Parser) parseForLoop() ast.Statement {
 		p.nextToken()
 	}
 
+	if p.peekTokenIs(token.LSquare, token.LBrace, token.Underscore) {
+		p.nextToken()
+		return p.parseIterLoop(expectClosingParen, label)
+	}
+
 	if !p.peekTokenIs(token.Identifier) {
 		p.peekError(token.Identifier)
 		return nil
@@ -78,142 +84,161 @@ func (p *Parser) parseForLoop() ast.Statement {
 	p.insertToken(token.Token{Type: token.Let, Literal: "let"})
 	p.nextToken()
 
-	if p.peekTokenIs(token.Comma) {
+	if p.peekTokenIs(token.Comma, token.In) {
 		p.curToken = peekTok
-		loop := &ast.IterLoopStatement{Token: p.curToken}
-
-		if !p.curTokenIs(token.Identifier) {
-			p.addErrorWithCurPos("expected an ident, got %s", p.curToken.Type.String())
-			return nil
-		}
+		return p.parseIterLoop(expectClosingParen, label)
+	}
 
-		loop.Key = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		p.nextToken() // Skip comma
-		p.nextToken()
+	p.insertToken(peekTok)
 
-		if !p.curTokenIs(token.Identifier) {
-			p.addErrorWithCurPos("expected an ident, got %s", p.curToken.Type.String())
-			return nil
-		}
+	loop.Init = p.parseDefStatement().(*ast.DefStatement)
+	if !p.curTokenIs(token.Semicolon) {
+		p.addErrorWithCurPos("expected semicolon, got %s", p.curToken.Type.String())
+		return nil
+	}
+	p.nextToken()
 
-		loop.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	loop.Condition = p.parseExpression(priLowest).(ast.Expression)
+	p.nextToken()
+	if !p.curTokenIs(token.Semicolon) {
+		p.addErrorWithCurPos("expected semicolon, got %s", p.curToken.Type.String())
+		return nil
+	}
+	p.nextToken()
 
-		if !p.expectPeek(token.In) {
-			return nil
-		}
-		p.nextToken()
+	loop.Iter = p.parseExpression(priLowest)
 
-		val, ok := p.parseExpression(priLowest).(ast.Expression)
-		if !ok {
-			return nil
-		}
-		loop.Iter = val
+	if expectClosingParen && !p.expectPeek(token.RParen) {
+		return nil
+	}
 
-		if expectClosingParen && !p.expectPeek(token.RParen) {
-			return nil
-		}
+	if !p.peekTokenIs(token.LBrace) {
+		p.peekError(token.LBrace)
+		return nil
+	}
 
-		if !p.peekTokenIs(token.LBrace) {
-			p.peekError(token.LBrace)
-			return nil
-		}
+	p.nextToken()
+	loop.Body = p.parseLoopBody(label)
+	p.nextToken()
 
+	if p.peekTokenIs(token.Semicolon) {
 		p.nextToken()
-		loop.Body = p.parseBlockStatements()
-		p.nextToken()
+	}
 
-		if p.peekTokenIs(token.Semicolon) {
-			p.nextToken()
-		}
+	return loop
+}
 
-		return loop
-	} else if p.peekTokenIs(token.In) {
-		p.curToken = peekTok
-		loop := &ast.IterLoopStatement{Token: p.curToken}
+// parseIterLoop parses a for loop over an iterator such as for k, v in map. The
+// key and value may be destructured. curToken is the first token of the key or value.
+func (p *Parser) parseIterLoop(expectClosingParen bool, label *ast.Identifier) ast.Statement {
+	loop := &ast.IterLoopStatement{Token: p.curToken, Label: label}
 
-		if !p.curTokenIs(token.Identifier) {
-			p.addErrorWithCurPos("expected an ident, got %s", p.curToken.Type.String())
-			return nil
-		}
+	var ok bool
+	loop.Key, loop.Value, loop.Iter, ok = p.parseIterHead()
+	if !ok {
+		return nil
+	}
+
+	if expectClosingParen && !p.expectPeek(token.RParen) {
+		return nil
+	}
 
-		loop.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.peekTokenIs(token.LBrace) {
+		p.peekError(token.LBrace)
+		return nil
+	}
 
-		if !p.expectPeek(token.In) {
-			return nil
-		}
-		p.nextToken()
+	p.nextToken()
+	loop.Body = p.parseLoopBody(label)
+	p.nextToken()
 
-		val, ok := p.parseExpression(priLowest).(ast.Expression)
-		if !ok {
-			return nil
-		}
-		loop.Iter = val
+	if p.peekTokenIs(token.Semicolon) {
+		p.nextToken()
+	}
 
-		if expectClosingParen && !p.expectPeek(token.RParen) {
-			return nil
-		}
+	return loop
+}
 
-		if !p.peekTokenIs(token.LBrace) {
-			p.peekError(token.LBrace)
-			return nil
-		}
+// parseIterHead parses the key and value patterns and the iterable of a for loop
+// or comprehension, the key is nil if it isn't given. curToken is the first
+// token of the key or value.
+func (p *Parser) parseIterHead() (ast.Pattern, ast.Pattern, ast.Expression, bool) {
+	var key ast.Pattern
+	value := p.parseDestructurePattern()
+	if value == nil {
+		return nil, nil, nil, false
+	}
 
+	if p.peekTokenIs(token.Comma) {
 		p.nextToken()
-		loop.Body = p.parseBlockStatements()
 		p.nextToken()
 
-		if p.peekTokenIs(token.Semicolon) {
-			p.nextToken()
+		key = value
+		value = p.parseDestructurePattern()
+		if value == nil {
+			return nil, nil, nil, false
 		}
+	}
 
-		return loop
-	} else {
-		p.insertToken(peekTok)
+	var bindings []*ast.Identifier
+	if key != nil {
+		bindings = patternBindings(key, bindings)
+	}
+	if !p.checkBindings(patternBindings(value, bindings)) {
+		return nil, nil, nil, false
+	}
 
-		loop.Init = p.parseDefStatement().(*ast.DefStatement)
-		if !p.curTokenIs(token.Semicolon) {
-			p.addErrorWithCurPos("expected semicolon, got %s", p.curToken.Type.String())
-			return nil
-		}
-		p.nextToken()
+	if !p.expectPeek(token.In) {
+		return nil, nil, nil, false
+	}
+	p.nextToken()
 
-		loop.Condition = p.parseExpression(priLowest).(ast.Expression)
-		p.nextToken()
-		if !p.curTokenIs(token.Semicolon) {
-			p.addErrorWithCurPos("expected semicolon, got %s", p.curToken.Type.String())
-			return nil
-		}
-		p.nextToken()
+	iter, ok := p.parseExpression(priLowest).(ast.Expression)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	return key, value, iter, true
+}
 
-		loop.Iter = p.parseExpression(priLowest)
+func (p *Parser) parseWithStatement() ast.Statement {
+	if p.settings.Debug {
+		fmt.Println("parseWithStatement")
 	}
+	stmt := &ast.WithStatement{Token: p.curToken}
+	p.nextToken()
 
-	if expectClosingParen && !p.expectPeek(token.RParen) {
+	resource, ok := p.parseExpression(priLowest).(ast.Expression)
+	if !ok {
 		return nil
 	}
+	stmt.Resource = resource
 
-	if !p.peekTokenIs(token.LBrace) {
-		p.peekError(token.LBrace)
-		return nil
+	if p.peekTokenIs(token.As) {
+		p.nextToken()
+		if !p.expectPeek(token.Identifier) {
+			return nil
+		}
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	}
 
-	p.nextToken()
-	loop.Body = p.parseBlockStatements()
-	p.nextToken()
+	if !p.expectPeek(token.LBrace) {
+		return nil
+	}
+	stmt.Body = p.parseBlockStatements()
 
 	if p.peekTokenIs(token.Semicolon) {
 		p.nextToken()
 	}
-
-	return loop
+	return stmt
 }
 
-func (p *Parser) parseWhileLoop() ast.Statement {
+func (p *Parser) parseWhileLoop(label *ast.Identifier) ast.Statement {
 	if p.settings.Debug {
 		fmt.Println("parseWhileLoop")
 	}
 	loop := &ast.LoopStatement{
 		Token: p.curToken,
+		Label: label,
 	}
 	expectClosingParen := false
 
@@ -239,7 +264,7 @@ func (p *Parser) parseWhileLoop() ast.Statement {
 	}
 
 	p.nextToken()
-	loop.Body = p.parseBlockStatements()
+	loop.Body = p.parseLoopBody(label)
 	p.nextToken()
 
 	if p.peekTokenIs(token.Semicolon) {
@@ -249,13 +274,14 @@ func (p *Parser) parseWhileLoop() ast.Statement {
 	return loop
 }
 
-func (p *Parser) parseInfiniteLoop() ast.Statement {
+func (p *Parser) parseInfiniteLoop(label *ast.Identifier) ast.Statement {
 	if p.settings.Debug {
 		fmt.Println("parseInfiniteLoop")
 	}
 
 	loop := &ast.LoopStatement{
 		Token:     p.curToken,
+		Label:     label,
 		Init:      nil,
 		Condition: nil,
 		Iter:      nil,
@@ -267,7 +293,7 @@ func (p *Parser) parseInfiniteLoop() ast.Statement {
 	}
 
 	p.nextToken()
-	loop.Body = p.parseBlockStatements()
+	loop.Body = p.parseLoopBody(label)
 	p.nextToken()
 
 	if p.peekTokenIs(token.Semicolon) {
@@ -277,6 +303,71 @@ func (p *Parser) parseInfiniteLoop() ast.Statement {
 	return loop
 }
 
+// parseLoopBody parses the block of a loop with label. curToken is the opening brace.
+func (p *Parser) parseLoopBody(label *ast.Identifier) *ast.BlockStatement {
+	name := ""
+	if label != nil {
+		name = label.Value
+	}
+
+	p.loops = append(p.loops, name)
+	body := p.parseBlockStatements()
+	p.loops = p.loops[:len(p.loops)-1]
+	return body
+}
+
+// parseLabeledLoop parses a loop with a label that break and continue can
+// refer to. curToken is the label.
+func (p *Parser) parseLabeledLoop() ast.Statement {
+	label := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if p.inLoop(label.Value) {
+		p.addErrorWithCurPos("loop label %s is already in use", label.Value)
+	}
+	p.nextToken()
+	p.nextToken()
+
+	switch p.curToken.Type {
+	case token.For:
+		return p.parseForLoop(label)
+	case token.While:
+		return p.parseWhileLoop(label)
+	case token.Loop:
+		return p.parseInfiniteLoop(label)
+	}
+
+	p.addErrorWithCurPos("label %s must be followed by a loop, got %s", label.Value, p.curToken.Type.String())
+	return nil
+}
+
+// parseLoopLabel parses the optional label after break or continue. curToken is
+// the keyword.
+func (p *Parser) parseLoopLabel(keyword string) *ast.Identifier {
+	if len(p.loops) == 0 {
+		p.addErrorWithCurPos("%s used outside a loop", keyword)
+	}
+
+	if !p.peekTokenIs(token.Identifier) {
+		return nil
+	}
+	p.nextToken()
+
+	label := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if len(p.loops) > 0 && !p.inLoop(label.Value) {
+		p.addErrorWithCurPos("%s to unknown loop label %s", keyword, label.Value)
+	}
+	return label
+}
+
+// inLoop returns if a loop being parsed has label.
+func (p *Parser) inLoop(label string) bool {
+	for _, l := range p.loops {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *Parser) parseCompareExpression(left ast.Expression) ast.Node {
 	if p.settings.Debug {
 		fmt.Println("parseCompareExpression")
@@ -291,20 +382,35 @@ func (p *Parser) parseCompareExpression(left ast.Expression) ast.Node {
 	}
 }
 
+// parseCoalesceExpression parses a ?? b which evaluates to b only if a is nil.
+func (p *Parser) parseCoalesceExpression(left ast.Expression) ast.Node {
+	if p.settings.Debug {
+		fmt.Println("parseCoalesceExpression")
+	}
+	c := p.curToken
+	p.nextToken()
+
+	right, ok := p.parseExpression(priCoalesce).(ast.Expression)
+	if !ok {
+		return nil
+	}
+
+	return &ast.CompareExpression{
+		Token: c,
+		Left:  left,
+		Right: right,
+	}
+}
+
 func (p *Parser) parseMatchExpression() ast.Expression {
 	if p.settings.Debug {
 		fmt.Println("parseMatchExpression")
 	}
+	match := &ast.MatchExpression{Token: p.curToken}
 
-	var matchExp ast.Expression
-
-	if p.curTokenIs(token.LParen) {
-		matchExp = p.parseGroupedExpressionE()
-		if !p.expectPeek(token.RParen) {
-			return nil
-		}
-	} else {
-		matchExp = p.parseGroupedExpressionE()
+	match.Subject = p.parseGroupedExpressionE()
+	if match.Subject == nil {
+		return nil
 	}
 
 	if !p.expectPeek(token.LBrace) {
@@ -312,77 +418,110 @@ func (p *Parser) parseMatchExpression() ast.Expression {
 	}
 	p.nextToken()
 
-	cases := make([]*ast.IfExpression, 0, 5)
-
-	for {
-		var caseLiteral ast.Expression
-		if !p.curTokenIs(token.Underscore) {
-			caseLiteral = p.parseBaseLiteral()
-			if caseLiteral == nil {
-				p.addErrorWithCurPos("expected a literal, got %s", p.curToken.Type.String())
-				return nil
-			}
+	for !p.curTokenIs(token.RBrace) {
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
 		}
+		match.Arms = append(match.Arms, arm)
 
-		if !p.expectPeek(token.Fatarrow) {
+		// A newline after the last arm inserts a semicolon before the closing brace
+		if p.peekTokenIs(token.Semicolon) {
+			p.nextToken()
+		}
+		if p.peekTokenIs(token.Comma) {
+			p.nextToken()
+		} else if !p.peekTokenIs(token.RBrace) {
+			p.peekError(token.Comma)
 			return nil
 		}
 		p.nextToken()
+	}
 
-		caseConsequence := p.parseSingleOrBlockStatements()
-		if caseConsequence == nil {
-			p.addErrorWithCurPos("expected a statement, got %s", p.curToken.Type.String())
-			return nil
-		}
+	if len(match.Arms) == 0 {
+		p.addErrorWithPos(match.Token.Pos, "match expression has no arms")
+		return nil
+	}
 
-		if !p.expectPeek(token.Comma) {
-			return nil
-		}
+	p.checkMatchArms(match)
+	return match
+}
 
-		theCase := &ast.IfExpression{
-			Token:       p.curToken,
-			Consequence: caseConsequence,
-		}
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	if p.settings.Debug {
+		fmt.Println("parseMatchArm")
+	}
+	arm := &ast.MatchArm{Token: p.curToken}
 
-		if caseLiteral != nil {
-			theCase.Condition = &ast.InfixExpression{
-				Token:    token.Token{Type: token.Equal, Literal: "=="},
-				Left:     matchExp,
-				Operator: "==",
-				Right:    caseLiteral,
-			}
-		}
+	arm.Pattern = p.parsePattern()
+	if arm.Pattern == nil {
+		return nil
+	}
 
-		cases = append(cases, theCase)
+	if !p.checkBindings(patternBindings(arm.Pattern, nil)) {
+		return nil
+	}
 
-		if p.peekTokenIs(token.RBrace) {
-			p.nextToken()
-			break
-		}
+	if p.peekTokenIs(token.If) {
 		p.nextToken()
+		p.nextToken()
+		guard := p.parseExpression(priLowest)
+		if guard == nil {
+			return nil
+		}
+		arm.Guard = guard.(ast.Expression)
 	}
 
-	var defaultCase *ast.BlockStatement
+	if !p.expectPeek(token.Fatarrow) {
+		return nil
+	}
 
-	caseChain := cases[0]
-	currCase := caseChain
+	block := p.peekTokenIs(token.LBrace)
+	arm.Body = p.parseSingleOrBlockStatements()
+	if len(arm.Body.Statements) == 0 && !block {
+		p.addErrorWithCurPos("expected a statement, got %s", p.curToken.Type.String())
+		return nil
+	}
+
+	return arm
+}
 
-	for _, c := range cases[1:] {
-		if c.Condition == nil {
-			defaultCase = c.Consequence
+// checkMatchArms adds a warning for every arm that can never be reached because
+// an earlier arm without a guard already matches everything it could.
+func (p *Parser) checkMatchArms(match *ast.MatchExpression) {
+	var catchAll *ast.MatchArm
+	seen := make(map[string]bool)
+
+	for _, arm := range match.Arms {
+		if catchAll != nil {
+			p.addWarningWithPos(arm.Token.Pos, "unreachable match arm, %s on line %d matches everything",
+				catchAll.Pattern.String(), catchAll.Token.Pos.Line)
 			continue
 		}
 
-		currCase.Alternative = &ast.BlockStatement{
-			Token:      c.Token,
-			Statements: []ast.Statement{&ast.ExpressionStatement{Token: c.Token, Expression: c}},
+		literals := patternLiterals(arm.Pattern)
+		if literals != nil {
+			unseen := false
+			for _, l := range literals {
+				if !seen[literalKey(l)] {
+					unseen = true
+					break
+				}
+			}
+			if !unseen {
+				p.addWarningWithPos(arm.Token.Pos, "unreachable match arm, %s is matched by an earlier arm", arm.Pattern.String())
+				continue
+			}
 		}
-		currCase = c
-	}
 
-	if defaultCase != nil {
-		currCase.Alternative = defaultCase
+		if arm.Guard != nil {
+			continue
+		}
+		if patternIrrefutable(arm.Pattern) {
+			catchAll = arm
+		}
+		for _, l := range literals {
+			seen[literalKey(l)] = true
+		}
 	}
-
-	return caseChain
 }
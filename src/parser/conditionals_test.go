@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/nitrogen-lang/nitrogen/src/ast"
@@ -366,3 +367,287 @@ func TestInfiniteForLoop(t *testing.T) {
 		t.Fatalf("Incorrect number of body statements. Expected 1, got %d", len(fl.Body.Statements))
 	}
 }
+
+func TestMatchPatterns(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`match x { 1 => a }`, `1`},
+		{`match x { -1 => a }`, `-1`},
+		{`match x { _ => a }`, `_`},
+		{`match x { y => a }`, `y`},
+		{`match x { 1 | 2 | "3" => a }`, `1 | 2 | 3`},
+		{`match x { 1..5 => a }`, `1..5`},
+		{`match x { -5.. => a }`, `-5..`},
+		{`match x { .."m" => a }`, `..m`},
+		{`match x { [] => a }`, `[]`},
+		{`match x { [a, _, ...rest] => a }`, `[a, _, ...rest]`},
+		{`match x { [..., last] => a }`, `[..., last]`},
+		{`match x { {"a": 1, b} => a }`, `{a: 1, b: b}`},
+		{`match x { Point{x, y: 0} => a }`, `Point{x: x, y: 0}`},
+		{`match x { geo.Point{} => a }`, `(geo.Point){}`},
+		{`match x { Color.RED => a }`, `(Color.RED)`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewString(tt.input)
+		p := New(l, nil)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		match, ok := stmt.Expression.(*ast.MatchExpression)
+		if !ok {
+			t.Fatalf("Expression is not a match expression. Got %T", stmt.Expression)
+		}
+
+		if len(match.Arms) != 1 {
+			t.Fatalf("Incorrect number of arms. Expected 1, got %d", len(match.Arms))
+		}
+
+		if match.Arms[0].Pattern.String() != tt.expected {
+			t.Errorf("Incorrect pattern. Expected %q, got %q", tt.expected, match.Arms[0].Pattern.String())
+		}
+	}
+}
+
+func TestMatchArms(t *testing.T) {
+	input := `match (x) {
+    [a, b] if a > b => a,
+    1 => {
+        println(1)
+        2
+    },
+    _ => nil
+}`
+
+	l := lexer.NewString(input)
+	p := New(l, nil)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Body does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	match := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression)
+	if match.Subject.String() != "x" {
+		t.Fatalf("Incorrect subject. Got %s", match.Subject.String())
+	}
+
+	if len(match.Arms) != 3 {
+		t.Fatalf("Incorrect number of arms. Expected 3, got %d", len(match.Arms))
+	}
+
+	if match.Arms[0].Guard == nil || match.Arms[0].Guard.String() != "(a > b)" {
+		t.Fatalf("Incorrect guard. Got %v", match.Arms[0].Guard)
+	}
+
+	if len(match.Arms[1].Body.Statements) != 2 {
+		t.Fatalf("Incorrect number of body statements. Expected 2, got %d", len(match.Arms[1].Body.Statements))
+	}
+}
+
+func TestMatchErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{`match x { [a, a] => 1 }`, "a is bound more than once"},
+		{`match x { a | 1 => 1 }`, "alternative patterns can't bind variables"},
+		{`match x { [...a, ...b] => 1 }`, "only have one rest element"},
+		{`match x { 1..2.5 => 1 }`, "are different types"},
+		{`match x { true..false => 1 }`, "must be numbers or strings"},
+		{`match x { }`, "match expression has no arms"},
+		{`match x { 1 => 1 2 => 2 }`, `Expected ","`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewString(tt.input)
+		p := New(l, nil)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if !strings.Contains(p.Errors()[0], tt.err) {
+			t.Errorf("%s: expected error %q, got %q", tt.input, tt.err, p.Errors()[0])
+		}
+	}
+}
+
+func TestMatchUnreachableWarnings(t *testing.T) {
+	input := `match x {
+    1 | 2 => "a",
+    2 => "b",
+    3 if y => "c",
+    3 => "d",
+    n if n > 5 => "e",
+    _ => "f",
+    4 => "g",
+}`
+
+	l := lexer.NewString(input)
+	p := New(l, nil)
+	p.ParseProgram()
+	checkParserErrors(t, p)
+
+	warnings := p.Warnings()
+	if len(warnings) != 2 {
+		t.Fatalf("Expected 2 warnings, got %d: %v", len(warnings), warnings)
+	}
+
+	if !strings.Contains(warnings[0], "line 3") || !strings.Contains(warnings[0], "2 is matched by an earlier arm") {
+		t.Errorf("Incorrect warning. Got %q", warnings[0])
+	}
+	if !strings.Contains(warnings[1], "line 8") || !strings.Contains(warnings[1], "_ on line 7 matches everything") {
+		t.Errorf("Incorrect warning. Got %q", warnings[1])
+	}
+}
+
+func TestIterLoopDestructuring(t *testing.T) {
+	tests := []struct {
+		input string
+		key   string
+		value string
+	}{
+		{"for v in x { pass }", "", "v"},
+		{"for k, v in x { pass }", "k", "v"},
+		{"for _, [a, b] in x { pass }", "_", "[a, b]"},
+		{"for (k, {name} in x) { pass }", "k", "{name: name}"},
+		{"for [a, ...b] in x { pass }", "", "[a, ...b]"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewString(tt.input)
+		p := New(l, nil)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		loop, ok := program.Statements[0].(*ast.IterLoopStatement)
+		if !ok {
+			t.Fatalf("Statement is not an iter loop. Got %T", program.Statements[0])
+		}
+
+		key := ""
+		if loop.Key != nil {
+			key = loop.Key.String()
+		}
+		if key != tt.key {
+			t.Errorf("%s: key wrong. want=%q, got=%q", tt.input, tt.key, key)
+		}
+		if loop.Value.String() != tt.value {
+			t.Errorf("%s: value wrong. want=%q, got=%q", tt.input, tt.value, loop.Value.String())
+		}
+	}
+}
+
+func TestDeferAndWithStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`defer f(1)`, `defer f(1);`},
+		{`defer file.close()`, `defer (file.close)();`},
+		{`with open("a") as f { f.read() }`, `with open(a) as f { (f.read)(); }`},
+		{`with r {}`, `with r {  }`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewString(tt.input)
+		p := New(l, nil)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%s: expected 1 statement, got %d", tt.input, len(program.Statements))
+		}
+		if program.String() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestDeferAndWithErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{`defer 5`, "defer requires a function call, got 5"},
+		{`defer a?.b()`, "Can't defer an optional chain"},
+		{`with r as 5 {}`, `Expected "IDENT"`},
+		{`with r`, `Expected "{"`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewString(tt.input)
+		p := New(l, nil)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if !strings.Contains(p.Errors()[0], tt.err) {
+			t.Errorf("%s: expected error %q, got %q", tt.input, tt.err, p.Errors()[0])
+		}
+	}
+}
+
+func TestLabeledLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`outer: for x in a { break outer }`, `outer: for x in a { break outer; }`},
+		{`outer: while x { continue outer }`, `outer: while x { continue outer; }`},
+		{`outer: for i = 0; i < 3; i += 1 { continue }`, `outer: for let i = 0;; (i < 3); i = (i + 1); { continue; }`},
+		{`for x in a { break }`, `for x in a { break; }`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewString(tt.input)
+		p := New(l, nil)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%s: expected 1 statement, got %d", tt.input, len(program.Statements))
+		}
+		if program.String() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	errTests := []struct {
+		input string
+		err   string
+	}{
+		{`outer: println(1)`, "label outer must be followed by a loop"},
+		{`for i in range(2) { break nope }`, "break to unknown loop label nope"},
+		{`a: for i in x { continue b }`, "continue to unknown loop label b"},
+		{`a: for i in x { a: for j in y {} }`, "loop label a is already in use"},
+		{`a: while x { loop { a: loop {} } }`, "loop label a is already in use"},
+		{`break`, "break used outside a loop"},
+		{`for i in x { let f = fn() { continue } }`, "continue used outside a loop"},
+		{`a: for i in x { let f = fn() { for j in y { break a } } }`, "break to unknown loop label a"},
+	}
+
+	for _, tt := range errTests {
+		l := lexer.NewString(tt.input)
+		p := New(l, nil)
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || !strings.Contains(p.Errors()[0], tt.err) {
+			t.Errorf("%s: expected error %q, got %v", tt.input, tt.err, p.Errors())
+		}
+	}
+
+	// A function can reuse the label of a loop it's defined in
+	l := lexer.NewString(`a: for i in x { let f = fn() { a: for j in y { break a } } }`)
+	p := New(l, nil)
+	p.ParseProgram()
+	checkParserErrors(t, p)
+}
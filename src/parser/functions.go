@@ -23,7 +23,8 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		p.nextToken()
 	}
 
-	if p.curTokenIs(token.Identifier) {
+	// Keywords can be used as method names so classes can define methods like match
+	if p.curTokenIs(token.Identifier) || (p.inClassBody && p.curToken.Type.IsKeyword()) {
 		lit.Name = p.curToken.Literal
 		lit.FQName = p.curToken.Literal
 		p.nextToken()
@@ -33,9 +34,13 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	lit.Parameters, lit.Defaults, lit.Rest = p.parseFunctionParameters()
 
 	if lit.Native {
+		if lit.Defaults != nil || lit.Rest != nil {
+			p.addErrorWithPos(lit.Token.Pos, "native functions can't have default or rest parameters")
+			return nil
+		}
 		return lit
 	}
 
@@ -48,39 +53,81 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
+	// Loops outside the function can't be left from inside it
+	inClassBody, loops := p.inClassBody, p.loops
+	p.inClassBody, p.loops = false, nil
 	lit.Body = p.parseBlockStatements()
+	p.inClassBody, p.loops = inClassBody, loops
 
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+// parseFunctionParameters parses a parameter list. defaults has an entry for
+// each parameter which is nil if it doesn't have a default value, defaults is
+// nil if no parameter has one. The last parameter may be a rest parameter
+// written ...name which collects any extra arguments.
+func (p *Parser) parseFunctionParameters() (idents []*ast.Identifier, defaults []ast.Expression, rest *ast.Identifier) {
 	if p.settings.Debug {
 		fmt.Println("parseFunctionParameters")
 	}
-	idents := []*ast.Identifier{}
+	idents = []*ast.Identifier{}
+	hasDefaults := false
 
 	if p.peekTokenIs(token.RParen) {
 		p.nextToken()
-		return idents
+		return idents, nil, nil
 	}
 
-	p.nextToken()
+	for {
+		p.nextToken()
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	idents = append(idents, ident)
+		if p.curTokenIs(token.Ellipsis) {
+			if !p.expectPeek(token.Identifier) {
+				return nil, nil, nil
+			}
+			rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.peekTokenIs(token.RParen) {
+				p.addErrorWithCurPos("rest parameter %s must be the last parameter", rest.Value)
+				return nil, nil, nil
+			}
+			break
+		}
 
-	for p.peekTokenIs(token.Comma) {
-		p.nextToken()
-		p.nextToken()
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		var def ast.Expression
+
+		if p.peekTokenIs(token.Assign) {
+			p.nextToken()
+			p.nextToken()
+			exp, ok := p.parseExpression(priLowest).(ast.Expression)
+			if !ok {
+				p.addErrorWithPos(ident.Token.Pos, "default value of parameter %s must be an expression", ident.Value)
+				return nil, nil, nil
+			}
+			def = exp
+			hasDefaults = true
+		} else if hasDefaults {
+			p.addErrorWithPos(ident.Token.Pos, "parameter %s without a default value can't follow parameters with defaults", ident.Value)
+			return nil, nil, nil
+		}
+
 		idents = append(idents, ident)
+		defaults = append(defaults, def)
+
+		if !p.peekTokenIs(token.Comma) {
+			break
+		}
+		p.nextToken()
 	}
 
 	if !p.expectPeek(token.RParen) {
-		return nil
+		return nil, nil, nil
 	}
 
-	return idents
+	if !hasDefaults {
+		defaults = nil
+	}
+	return idents, defaults, rest
 }
 
 func (p *Parser) parseCallExpression(left ast.Expression) ast.Node {
@@ -90,8 +137,71 @@ func (p *Parser) parseCallExpression(left ast.Expression) ast.Node {
 	return &ast.CallExpression{
 		Token:     p.curToken,
 		Function:  left,
-		Arguments: p.parseExpressionList(token.RParen),
+		Arguments: p.parseCallArguments(),
+	}
+}
+
+// parseCallArguments parses the arguments of a call. Named arguments are
+// written name: value and must come after all positional arguments.
+func (p *Parser) parseCallArguments() []ast.Expression {
+	if p.settings.Debug {
+		fmt.Println("parseCallArguments")
 	}
+	args := []ast.Expression{}
+	named := map[string]bool{}
+
+	if p.peekTokenIs(token.RParen) {
+		p.nextToken()
+		return args
+	}
+
+	for {
+		p.nextToken()
+		if p.curTokenIs(token.RParen) {
+			return args
+		}
+
+		if p.curTokenIs(token.Identifier) && p.peekTokenIs(token.Colon) {
+			name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if named[name.Value] {
+				p.addErrorWithCurPos("argument %s is given more than once", name.Value)
+				return nil
+			}
+			named[name.Value] = true
+
+			p.nextToken()
+			p.nextToken()
+			value, ok := p.parseExpression(priLowest).(ast.Expression)
+			if !ok {
+				return nil
+			}
+			args = append(args, &ast.NamedArgument{Token: name.Token, Name: name, Value: value})
+		} else {
+			if len(named) > 0 {
+				p.addErrorWithCurPos("positional arguments can't follow named arguments")
+				return nil
+			}
+
+			arg, ok := p.parseExpression(priLowest).(ast.Expression)
+			if !ok {
+				return nil
+			}
+			args = append(args, arg)
+		}
+
+		if !p.peekTokenIs(token.Comma) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.peekTokenIs(token.RParen) {
+		p.addErrorWithCurPos("I was expecting %q, but instead I got %q. Did you forget a comma?", token.RParen.String(), p.peekToken.Literal)
+		return nil
+	}
+
+	p.nextToken()
+	return args
 }
 
 func (p *Parser) parseDoExpression() ast.Expression {
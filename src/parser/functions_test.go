@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/nitrogen-lang/nitrogen/src/ast"
@@ -175,3 +176,135 @@ func TestCallExpressionParameterParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestFunctionDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		rest     string
+	}{
+		{"let a = fn(x, y = 2) {};", "fn a(x, y = 2) {}", ""},
+		{"let a = fn(x = 1 + 2, y = [1]) {};", "fn a(x = (1 + 2), y = [1]) {}", ""},
+		{"let a = fn(...args) {};", "fn a(...args) {}", "args"},
+		{"let a = fn(x, y = nil, ...args) {};", "fn a(x, y = nil, ...args) {}", "args"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewString(tt.input)
+		p := New(l, nil)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.DefStatement)
+		function := stmt.Value.(*ast.FunctionLiteral)
+
+		if function.String() != tt.expected {
+			t.Errorf("function wrong. want=%q, got=%q", tt.expected, function.String())
+		}
+
+		if tt.rest == "" {
+			if function.Rest != nil {
+				t.Errorf("expected no rest parameter, got %s", function.Rest.Value)
+			}
+		} else if function.Rest == nil || function.Rest.Value != tt.rest {
+			t.Errorf("rest parameter wrong. want=%s, got=%v", tt.rest, function.Rest)
+		}
+	}
+}
+
+func TestCallNamedArguments(t *testing.T) {
+	input := "add(1, y: 2, z: 3 * 4);"
+
+	l := lexer.NewString(input)
+	p := New(l, nil)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+	}
+
+	expected := []string{"1", "y: 2", "z: (3 * 4)"}
+	if len(exp.Arguments) != len(expected) {
+		t.Fatalf("wrong number of arguments. want=%d, got=%d", len(expected), len(exp.Arguments))
+	}
+
+	for i, arg := range expected {
+		if exp.Arguments[i].String() != arg {
+			t.Errorf("argument %d wrong. want=%q, got=%q", i, arg, exp.Arguments[i].String())
+		}
+	}
+
+	if _, ok := exp.Arguments[1].(*ast.NamedArgument); !ok {
+		t.Errorf("argument 1 is not ast.NamedArgument. got=%T", exp.Arguments[1])
+	}
+}
+
+func TestFunctionParameterErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"let a = fn(x = 1, y) {};", "parameter y without a default value can't follow parameters with defaults"},
+		{"let a = fn(...x, y) {};", "rest parameter"},
+		{"add(x: 1, 2);", "positional arguments can't follow named arguments"},
+		{"add(x: 1, x: 2);", "argument x is given more than once"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewString(tt.input)
+		p := New(l, nil)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if !strings.Contains(p.Errors()[0], tt.err) {
+			t.Errorf("%s: expected error %q, got %q", tt.input, tt.err, p.Errors()[0])
+		}
+	}
+}
+
+func TestKeywordMethodNames(t *testing.T) {
+	input := `class Regex { fn match(s) { s; }; fn native if(); };`
+
+	l := lexer.NewString(input)
+	p := New(l, nil)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.DefStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.DefStatement. got=%T",
+			program.Statements[0])
+	}
+
+	class, ok := stmt.Value.(*ast.ClassLiteral)
+	if !ok {
+		t.Fatalf("stmt.Value is not ast.ClassLiteral. got=%T", stmt.Value)
+	}
+	for _, name := range []string{"match", "if"} {
+		if _, exists := class.Methods[name]; !exists {
+			t.Errorf("class is missing method %s", name)
+		}
+	}
+
+	invalid := []string{
+		"fn while() {};",
+		"let a = fn match() {};",
+		"class A { fn f() { fn while() {}; }; };",
+	}
+
+	for _, input := range invalid {
+		l := lexer.NewString(input)
+		p := New(l, nil)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"strings"
 
 	"github.com/nitrogen-lang/nitrogen/src/ast"
+	"github.com/nitrogen-lang/nitrogen/src/elemental/object"
+	"github.com/nitrogen-lang/nitrogen/src/lexer"
 	"github.com/nitrogen-lang/nitrogen/src/token"
 )
 
@@ -85,6 +87,75 @@ func (p *Parser) parseByteStringLiteral() ast.Expression {
 	}
 }
 
+func (p *Parser) parseFormatString() ast.Expression {
+	if p.settings.Debug {
+		fmt.Println("parseFormatString")
+	}
+	fstr := &ast.FormatString{Token: p.curToken}
+
+	segments, err := lexer.SplitFormatString(p.curToken)
+	if err != nil {
+		ferr := err.(*lexer.FormatError)
+		p.addErrorWithPos(ferr.Pos, "%s", ferr.Msg)
+		return nil
+	}
+
+	for _, seg := range segments {
+		if seg.Expr == "" {
+			fstr.Parts = append(fstr.Parts, ast.MakeStringLiteral(seg.Text, seg.Pos))
+			continue
+		}
+
+		if _, err := object.ParseFormatSpec(seg.Spec); err != nil {
+			p.addErrorWithPos(seg.Pos, "%s", err)
+			return nil
+		}
+
+		value := p.parseFormattedValue(seg)
+		if value == nil {
+			return nil
+		}
+		fstr.Parts = append(fstr.Parts, value)
+	}
+
+	return fstr
+}
+
+// parseFormattedValue parses the expression in a format string segment with a separate parser.
+func (p *Parser) parseFormattedValue(seg lexer.FormatSegment) *ast.FormattedValue {
+	// The lexer doesn't advance the column when reading the last rune of its
+	// input, the trailing space keeps the positions of every token correct.
+	sub := New(lexer.NewStringAt(seg.Expr+" ", seg.Pos), p.settings)
+	tok := sub.curToken
+	node := sub.parseExpression(priLowest)
+
+	// A semicolon is inserted at the end of the source after most expressions
+	if sub.peekTokenIs(token.Semicolon) {
+		sub.nextToken()
+	}
+	if len(sub.errors) == 0 && !sub.peekTokenIs(token.EOF) {
+		sub.addErrorWithPos(sub.peekToken.Pos, "unexpected %s in format string expression", sub.peekToken.Type.String())
+	}
+
+	p.warnings = append(p.warnings, sub.warnings...)
+	if len(sub.errors) > 0 {
+		p.errors = append(p.errors, sub.errors...)
+		return nil
+	}
+
+	value, ok := node.(ast.Expression)
+	if !ok {
+		p.addErrorWithPos(tok.Pos, "format string values must be expressions")
+		return nil
+	}
+
+	return &ast.FormattedValue{
+		Token: tok,
+		Value: value,
+		Spec:  seg.Spec,
+	}
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	if p.settings.Debug {
 		fmt.Println("parseBoolean")
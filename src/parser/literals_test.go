@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/nitrogen-lang/nitrogen/src/ast"
@@ -119,6 +120,63 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFormatStringExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`f"hello world"`, `f"hello world"`},
+		{`f"a {b} c"`, `f"a {b} c"`},
+		{`f"{a + b:>5.2f}"`, `f"{(a + b):>5.2f}"`},
+		{`f"{{{len(items)}}}"`, `f"{{{len(items)}}}"`},
+		{`f""`, `f""`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewString(tt.input)
+		p := New(l, nil)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		fstr, ok := stmt.Expression.(*ast.FormatString)
+		if !ok {
+			t.Fatalf("exp not *ast.FormatString. got=%T", stmt.Expression)
+		}
+
+		if fstr.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, fstr.String())
+		}
+	}
+}
+
+func TestFormatStringErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{`f"{}"`, "line 1, col 3:\n    empty expression in format string"},
+		{`f"{a b}"`, "line 1, col 6:\n    unexpected IDENT in format string expression"},
+		{`f"{a:q}"`, `format spec "q" has unknown type 'q'`},
+		{`f"{a:.2d}"`, "can't have a precision with an integer type"},
+		{`f"{a = 1}"`, "format string values must be expressions"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewString(tt.input)
+		p := New(l, nil)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if !strings.Contains(p.Errors()[0], tt.err) {
+			t.Errorf("%s: expected error %q, got %q", tt.input, tt.err, p.Errors()[0])
+		}
+	}
+}
+
 func TestNullLiteral(t *testing.T) {
 	input := "nil"
 
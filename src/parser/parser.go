@@ -11,6 +11,7 @@ import (
 const (
 	priLowest      int = iota
 	priCompare         // and, or
+	priCoalesce        // ??
 	priEquals          // ==
 	priLessGreater     // > or <
 	priSum             // +, -
@@ -24,6 +25,7 @@ const (
 var precedences = map[token.TokenType]int{
 	token.LAnd:          priCompare,
 	token.LOr:           priCompare,
+	token.NilCoalesce:   priCoalesce,
 	token.Equal:         priEquals,
 	token.NotEqual:      priEquals,
 	token.LessThanEq:    priEquals,
@@ -45,6 +47,8 @@ var precedences = map[token.TokenType]int{
 	token.Implements:    priCall,
 	token.LSquare:       priIndex,
 	token.Dot:           priIndex,
+	token.OptionalDot:   priIndex,
+	token.OptionalIndex: priIndex,
 	token.Assign:        priAssign,
 	token.PlusAssign:    priAssign,
 	token.MinusAssign:   priAssign,
@@ -64,6 +68,7 @@ type (
 type Parser struct {
 	l        *lexer.Lexer
 	errors   []string
+	warnings []string
 	settings *Settings
 
 	lastToken token.Token
@@ -72,6 +77,13 @@ type Parser struct {
 
 	insertedTokens []token.Token
 
+	// inClassBody is set while parsing the statements directly in a class body
+	inClassBody bool
+
+	// loops are the labels of the loops being parsed in the current function,
+	// innermost last. Unlabeled loops are empty.
+	loops []string
+
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
@@ -96,6 +108,7 @@ func New(l *lexer.Lexer, settings *Settings) *Parser {
 	p.registerPrefix(token.Nil, p.parseNullLiteral)
 	p.registerPrefix(token.String, p.parseStringLiteral)
 	p.registerPrefix(token.ByteString, p.parseByteStringLiteral)
+	p.registerPrefix(token.FormatString, p.parseFormatString)
 	p.registerPrefix(token.True, p.parseBoolean)
 	p.registerPrefix(token.False, p.parseBoolean)
 	p.registerPrefix(token.LSquare, p.parseArrayLiteral)
@@ -130,6 +143,9 @@ func New(l *lexer.Lexer, settings *Settings) *Parser {
 	p.registerInfix(token.LParen, p.parseCallExpression)
 	p.registerInfix(token.LSquare, p.parseIndexExpression)
 	p.registerInfix(token.Dot, p.parseAttributeExpression)
+	p.registerInfix(token.OptionalDot, p.parseOptionalAttribute)
+	p.registerInfix(token.OptionalIndex, p.parseIndexExpression)
+	p.registerInfix(token.NilCoalesce, p.parseCoalesceExpression)
 	p.registerInfix(token.Assign, p.parseAssignmentStatement)
 	p.registerInfix(token.PlusAssign, p.parseCompoundAssign)
 	p.registerInfix(token.MinusAssign, p.parseCompoundAssign)
@@ -155,6 +171,11 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// Warnings returns problems that don't stop the program from compiling such as unreachable match arms.
+func (p *Parser) Warnings() []string {
+	return p.warnings
+}
+
 func (p *Parser) registerPrefix(tt token.TokenType, fn prefixParseFn) {
 	p.prefixParseFns[tt] = fn
 }
@@ -177,6 +198,12 @@ func (p *Parser) addErrorWithPos(pos token.Position, format string, args ...inte
 	p.errors = append(p.errors, msg)
 }
 
+func (p *Parser) addWarningWithPos(pos token.Position, format string, args ...interface{}) {
+	args = append([]interface{}{pos.Filename, pos.Line, pos.Col}, args...)
+	msg := fmt.Sprintf("%s:\n  line %d, col %d:\n    warning: "+format, args...)
+	p.warnings = append(p.warnings, msg)
+}
+
 func (p *Parser) peekError(t token.TokenType) {
 	p.addErrorWithPos(p.peekToken.Pos, "Incorrect next token. Expected %q, got %q", t.String(),
 		p.peekToken.Type.String())
@@ -224,6 +251,24 @@ func (p *Parser) advancePeekToken() {
 	}
 }
 
+// skipNewlineBefore skips a semicolon, usually inserted at the end of a line,
+// if it's followed by one of the token types. It returns if peekToken is one of the types.
+func (p *Parser) skipNewlineBefore(t ...token.TokenType) bool {
+	if p.peekTokenIs(token.Semicolon) {
+		semicolon := p.peekToken
+		p.advancePeekToken()
+		for p.peekTokenIs(token.Comment) {
+			p.advancePeekToken()
+		}
+
+		if !p.peekTokenIs(t...) {
+			p.insertToken(semicolon)
+			return false
+		}
+	}
+	return p.peekTokenIs(t...)
+}
+
 func (p *Parser) insertToken(t token.Token) {
 	p.insertedTokens = append(p.insertedTokens, p.peekToken)
 	p.peekToken = t
@@ -401,6 +446,7 @@ func (p *Parser) parseSingleOrBlockStatements() *ast.BlockStatement {
 		p.nextToken()
 		block.Statements = append(block.Statements, p.parseBlockStatements().Statements...)
 	} else {
+		p.nextToken()
 		stmt := p.parseStatement()
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
@@ -426,7 +472,12 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	if expr == nil {
 		return nil
 	}
-	list = append(list, expr.(ast.Expression))
+	return p.parseExpressionListRest(expr.(ast.Expression), end)
+}
+
+// parseExpressionListRest parses the rest of an expression list after its first expression.
+func (p *Parser) parseExpressionListRest(first ast.Expression, end token.TokenType) []ast.Expression {
+	list := []ast.Expression{first}
 
 	for p.peekTokenIs(token.Comma) {
 		p.nextToken()
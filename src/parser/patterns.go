@@ -0,0 +1,498 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/nitrogen-lang/nitrogen/src/ast"
+	"github.com/nitrogen-lang/nitrogen/src/token"
+)
+
+func (p *Parser) parsePattern() ast.Pattern {
+	if p.settings.Debug {
+		fmt.Println("parsePattern")
+	}
+	tok := p.curToken
+
+	pattern := p.parseSinglePattern()
+	if pattern == nil || !p.peekTokenIs(token.BitwiseOr) {
+		return pattern
+	}
+
+	alt := &ast.AlternativePattern{
+		Token:        tok,
+		Alternatives: []ast.Pattern{pattern},
+	}
+
+	for p.peekTokenIs(token.BitwiseOr) {
+		p.nextToken()
+		p.nextToken()
+
+		pattern := p.parseSinglePattern()
+		if pattern == nil {
+			return nil
+		}
+		alt.Alternatives = append(alt.Alternatives, pattern)
+	}
+
+	if names := patternBindings(alt, nil); len(names) > 0 {
+		p.addErrorWithPos(names[0].Token.Pos, "alternative patterns can't bind variables, found %s", names[0].Value)
+		return nil
+	}
+
+	return alt
+}
+
+func (p *Parser) parseSinglePattern() ast.Pattern {
+	tok := p.curToken
+
+	switch tok.Type {
+	case token.Underscore:
+		return &ast.WildcardPattern{Token: tok}
+	case token.Identifier:
+		if tok.Literal == "_" {
+			return &ast.WildcardPattern{Token: tok}
+		}
+		if p.peekTokenIs(token.Dot) || p.peekTokenIs(token.LBrace) {
+			return p.parseNamedPattern()
+		}
+		return &ast.BindingPattern{
+			Token: tok,
+			Name:  &ast.Identifier{Token: tok, Value: tok.Literal},
+		}
+	case token.LSquare:
+		return p.parseArrayPattern()
+	case token.LBrace:
+		return p.parseMapPattern()
+	case token.Range:
+		return p.parseRangePattern(nil)
+	}
+
+	lit := p.parsePatternLiteral()
+	if lit == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.Range) {
+		p.nextToken()
+		return p.parseRangePattern(lit)
+	}
+	return &ast.ValuePattern{Token: tok, Value: lit}
+}
+
+// parsePatternLiteral parses a base literal or a negative number.
+func (p *Parser) parsePatternLiteral() ast.Expression {
+	if !p.curTokenIs(token.Dash) {
+		if lit := p.parseBaseLiteral(); lit != nil {
+			return lit
+		}
+		p.addErrorWithCurPos("expected a pattern, got %s", p.curToken.Type.String())
+		return nil
+	}
+
+	dash := p.curToken
+	p.nextToken()
+
+	switch p.curToken.Type {
+	case token.Integer:
+		lit, ok := p.parseIntegerLiteral().(*ast.IntegerLiteral)
+		if !ok {
+			return nil
+		}
+		lit.Value = -lit.Value
+		lit.Token.Literal = "-" + lit.Token.Literal
+		lit.Token.Pos = dash.Pos
+		return lit
+	case token.Float:
+		lit, ok := p.parseFloatLiteral().(*ast.FloatLiteral)
+		if !ok {
+			return nil
+		}
+		lit.Value = -lit.Value
+		lit.Token.Literal = "-" + lit.Token.Literal
+		lit.Token.Pos = dash.Pos
+		return lit
+	}
+
+	p.addErrorWithCurPos("expected a number after -, got %s", p.curToken.Type.String())
+	return nil
+}
+
+func (p *Parser) parseRangePattern(low ast.Expression) ast.Pattern {
+	rng := &ast.RangePattern{Token: p.curToken, Low: low}
+
+	switch p.peekToken.Type {
+	case token.Integer, token.Float, token.String, token.Dash:
+		p.nextToken()
+		rng.High = p.parsePatternLiteral()
+		if rng.High == nil {
+			return nil
+		}
+	}
+
+	if rng.Low == nil && rng.High == nil {
+		p.addErrorWithPos(rng.Token.Pos, "range pattern needs at least one bound")
+		return nil
+	}
+
+	for _, bound := range []ast.Expression{rng.Low, rng.High} {
+		switch bound.(type) {
+		case nil, *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral:
+		default:
+			p.addErrorWithPos(rng.Token.Pos, "range bounds must be numbers or strings, got %s", bound.String())
+			return nil
+		}
+	}
+
+	if rng.Low != nil && rng.High != nil && fmt.Sprintf("%T", rng.Low) != fmt.Sprintf("%T", rng.High) {
+		p.addErrorWithPos(rng.Token.Pos, "range bounds %s and %s are different types", rng.Low.String(), rng.High.String())
+		return nil
+	}
+
+	return rng
+}
+
+// parseNamedPattern parses a dotted name compared by value such as Color.RED,
+// or a class pattern such as Point{x, y: 0}.
+func (p *Parser) parseNamedPattern() ast.Pattern {
+	tok := p.curToken
+	var name ast.Expression = &ast.Identifier{Token: tok, Value: tok.Literal}
+
+	for p.peekTokenIs(token.Dot) {
+		p.nextToken()
+		dot := p.curToken
+		if !p.expectPeek(token.Identifier) {
+			return nil
+		}
+		name = &ast.AttributeExpression{
+			Token: dot,
+			Left:  name,
+			Index: ast.MakeStringLiteral(p.curToken.Literal, p.curToken.Pos),
+		}
+	}
+
+	if !p.peekTokenIs(token.LBrace) {
+		return &ast.ValuePattern{Token: tok, Value: name}
+	}
+	p.nextToken()
+
+	class := &ast.ClassPattern{Token: tok, Class: name}
+	ok := p.parsePatternList(token.RBrace, func() bool {
+		if !p.curTokenIs(token.Identifier) {
+			p.addErrorWithCurPos("expected a field name, got %s", p.curToken.Type.String())
+			return false
+		}
+		field := p.curToken
+
+		var pattern ast.Pattern = &ast.BindingPattern{
+			Token: field,
+			Name:  &ast.Identifier{Token: field, Value: field.Literal},
+		}
+		if p.peekTokenIs(token.Colon) {
+			p.nextToken()
+			p.nextToken()
+			if pattern = p.parsePattern(); pattern == nil {
+				return false
+			}
+		}
+
+		class.Fields = append(class.Fields, field.Literal)
+		class.Patterns = append(class.Patterns, pattern)
+		return true
+	})
+	if !ok {
+		return nil
+	}
+	return class
+}
+
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	arr := &ast.ArrayPattern{Token: p.curToken}
+
+	ok := p.parsePatternList(token.RSquare, func() bool {
+		if p.curTokenIs(token.Ellipsis) {
+			if arr.HasRest {
+				p.addErrorWithCurPos("array pattern can only have one rest element")
+				return false
+			}
+			arr.HasRest = true
+
+			if p.peekTokenIs(token.Identifier) {
+				p.nextToken()
+				if p.curToken.Literal != "_" {
+					arr.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+				}
+			}
+			return true
+		}
+
+		pattern := p.parsePattern()
+		if pattern == nil {
+			return false
+		}
+		if arr.HasRest {
+			arr.Tail = append(arr.Tail, pattern)
+		} else {
+			arr.Head = append(arr.Head, pattern)
+		}
+		return true
+	})
+	if !ok {
+		return nil
+	}
+	return arr
+}
+
+func (p *Parser) parseMapPattern() ast.Pattern {
+	m := &ast.MapPattern{Token: p.curToken}
+
+	ok := p.parsePatternList(token.RBrace, func() bool {
+		// {name} is short for {"name": name}
+		if p.curTokenIs(token.Identifier) {
+			m.Keys = append(m.Keys, ast.MakeStringLiteral(p.curToken.Literal, p.curToken.Pos))
+			m.Values = append(m.Values, &ast.BindingPattern{
+				Token: p.curToken,
+				Name:  &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal},
+			})
+			return true
+		}
+
+		key := p.parseBaseLiteral()
+		if key == nil {
+			p.addErrorWithCurPos("expected a map key, got %s", p.curToken.Type.String())
+			return false
+		}
+		if !p.expectPeek(token.Colon) {
+			return false
+		}
+		p.nextToken()
+
+		pattern := p.parsePattern()
+		if pattern == nil {
+			return false
+		}
+		m.Keys = append(m.Keys, key)
+		m.Values = append(m.Values, pattern)
+		return true
+	})
+	if !ok {
+		return nil
+	}
+	return m
+}
+
+// parsePatternList calls parseElem for each comma separated element until the
+// end token. curToken is the opening token when called and the end token on return.
+func (p *Parser) parsePatternList(end token.TokenType, parseElem func() bool) bool {
+	p.nextToken()
+
+	for !p.curTokenIs(end) {
+		if p.curTokenIs(token.EOF) {
+			p.addErrorWithCurPos("unexpected end of file in pattern, expected %q", end.String())
+			return false
+		}
+
+		if !parseElem() {
+			return false
+		}
+
+		// A newline after the last element inserts a semicolon before the end token
+		if p.peekTokenIs(token.Semicolon) {
+			p.nextToken()
+		}
+		if p.peekTokenIs(token.Comma) {
+			p.nextToken()
+		} else if !p.peekTokenIs(end) {
+			p.peekError(end)
+			return false
+		}
+		p.nextToken()
+	}
+
+	return true
+}
+
+// parseDestructurePattern parses the left side of a destructuring let, const
+// or for loop. Only names, wildcards, arrays and maps can be destructured.
+func (p *Parser) parseDestructurePattern() ast.Pattern {
+	pattern := p.parsePattern()
+	if pattern == nil || !p.checkDestructurePattern(pattern) {
+		return nil
+	}
+	return pattern
+}
+
+func (p *Parser) checkDestructurePattern(pattern ast.Pattern) bool {
+	var tok token.Token
+
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern, *ast.BindingPattern:
+		return true
+	case *ast.ArrayPattern:
+		for _, elem := range pattern.Head {
+			if !p.checkDestructurePattern(elem) {
+				return false
+			}
+		}
+		for _, elem := range pattern.Tail {
+			if !p.checkDestructurePattern(elem) {
+				return false
+			}
+		}
+		return true
+	case *ast.MapPattern:
+		for _, value := range pattern.Values {
+			if !p.checkDestructurePattern(value) {
+				return false
+			}
+		}
+		return true
+	case *ast.ValuePattern:
+		tok = pattern.Token
+	case *ast.RangePattern:
+		tok = pattern.Token
+	case *ast.AlternativePattern:
+		tok = pattern.Token
+	case *ast.ClassPattern:
+		tok = pattern.Token
+	}
+
+	p.addErrorWithPos(tok.Pos, "%s can't be used in a destructuring pattern", pattern.String())
+	return false
+}
+
+// exprToPattern converts the left side of an assignment such as [a, b] = [b, a]
+// into a pattern. It returns nil if the expression can't be destructured.
+func exprToPattern(exp ast.Expression) ast.Pattern {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		if exp.Value == "_" {
+			return &ast.WildcardPattern{Token: exp.Token}
+		}
+		return &ast.BindingPattern{Token: exp.Token, Name: exp}
+
+	case *ast.Array:
+		arr := &ast.ArrayPattern{Token: exp.Token}
+		for _, elem := range exp.Elements {
+			pattern := exprToPattern(elem)
+			if pattern == nil {
+				return nil
+			}
+			arr.Head = append(arr.Head, pattern)
+		}
+		return arr
+
+	case *ast.HashLiteral:
+		m := &ast.MapPattern{Token: exp.Token}
+		for key, value := range exp.Pairs {
+			if _, ok := key.(ast.BaseLiteral); !ok {
+				return nil
+			}
+			pattern := exprToPattern(value)
+			if pattern == nil {
+				return nil
+			}
+			m.Keys = append(m.Keys, key)
+			m.Values = append(m.Values, pattern)
+		}
+		// Pairs is a map so keep the order stable
+		sort.Sort(mapPatternByKey{m})
+		return m
+	}
+	return nil
+}
+
+type mapPatternByKey struct{ *ast.MapPattern }
+
+func (m mapPatternByKey) Len() int           { return len(m.Keys) }
+func (m mapPatternByKey) Less(i, j int) bool { return m.Keys[i].String() < m.Keys[j].String() }
+func (m mapPatternByKey) Swap(i, j int) {
+	m.Keys[i], m.Keys[j] = m.Keys[j], m.Keys[i]
+	m.Values[i], m.Values[j] = m.Values[j], m.Values[i]
+}
+
+// checkBindings adds an error if a name is bound more than once.
+func (p *Parser) checkBindings(names []*ast.Identifier) bool {
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name.Value] {
+			p.addErrorWithPos(name.Token.Pos, "%s is bound more than once in the pattern", name.Value)
+			return false
+		}
+		seen[name.Value] = true
+	}
+	return true
+}
+
+// patternBindings appends the names bound by pattern to names.
+func patternBindings(pattern ast.Pattern, names []*ast.Identifier) []*ast.Identifier {
+	switch pattern := pattern.(type) {
+	case *ast.BindingPattern:
+		names = append(names, pattern.Name)
+	case *ast.AlternativePattern:
+		for _, alt := range pattern.Alternatives {
+			names = patternBindings(alt, names)
+		}
+	case *ast.ArrayPattern:
+		for _, elem := range pattern.Head {
+			names = patternBindings(elem, names)
+		}
+		if pattern.Rest != nil {
+			names = append(names, pattern.Rest)
+		}
+		for _, elem := range pattern.Tail {
+			names = patternBindings(elem, names)
+		}
+	case *ast.MapPattern:
+		for _, value := range pattern.Values {
+			names = patternBindings(value, names)
+		}
+	case *ast.ClassPattern:
+		for _, field := range pattern.Patterns {
+			names = patternBindings(field, names)
+		}
+	}
+	return names
+}
+
+// patternIrrefutable returns if pattern matches any value.
+func patternIrrefutable(pattern ast.Pattern) bool {
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern, *ast.BindingPattern:
+		return true
+	case *ast.AlternativePattern:
+		for _, alt := range pattern.Alternatives {
+			if patternIrrefutable(alt) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// patternLiterals returns the literals pattern compares against if it's made
+// up of only literal values. Otherwise it returns nil.
+func patternLiterals(pattern ast.Pattern) []ast.Expression {
+	switch pattern := pattern.(type) {
+	case *ast.ValuePattern:
+		if _, ok := pattern.Value.(ast.BaseLiteral); ok {
+			return []ast.Expression{pattern.Value}
+		}
+	case *ast.AlternativePattern:
+		var literals []ast.Expression
+		for _, alt := range pattern.Alternatives {
+			l := patternLiterals(alt)
+			if l == nil {
+				return nil
+			}
+			literals = append(literals, l...)
+		}
+		return literals
+	}
+	return nil
+}
+
+// literalKey identifies a literal by type and value, 1 and 1.0 are different values.
+func literalKey(lit ast.Expression) string {
+	return fmt.Sprintf("%T %s", lit, lit.String())
+}
@@ -230,6 +230,26 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"6 % 3 * 4",
 			"((6 % 3) * 4)",
 		},
+		{
+			"a ?? b + c",
+			"(a ?? (b + c))",
+		},
+		{
+			"a == b ?? c",
+			"((a == b) ?? c)",
+		},
+		{
+			"a ?? b ?? c",
+			"((a ?? b) ?? c)",
+		},
+		{
+			"a ?? b or c",
+			"((a ?? b) or c)",
+		},
+		{
+			"a?.b.c?[d]?.(e)",
+			"(((a?.b).c)?[d])?.(e)",
+		},
 	}
 
 	for _, tt := range tests {
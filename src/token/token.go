@@ -30,6 +30,7 @@ const (
 	Float
 	String
 	ByteString
+	FormatString
 
 	// Operators
 	Assign
@@ -40,6 +41,11 @@ const (
 	Slash
 	Modulo
 	Dot
+	Range
+	Ellipsis
+	NilCoalesce
+	OptionalDot
+	OptionalIndex
 
 	PlusAssign
 	MinusAssign
@@ -113,6 +119,8 @@ const (
 	Breakpoint
 	Match
 	Export
+	Defer
+	With
 	keywordEnd
 )
 
@@ -123,11 +131,12 @@ var tokens = [...]string{
 	EOL:     "EOL",
 
 	// Identifiers & literals
-	Identifier: "IDENT",
-	Integer:    "INT",
-	Float:      "FLOAT",
-	String:     "STRING",
-	ByteString: "BYTES",
+	Identifier:   "IDENT",
+	Integer:      "INT",
+	Float:        "FLOAT",
+	String:       "STRING",
+	ByteString:   "BYTES",
+	FormatString: "FSTRING",
 
 	// Operators
 	Assign:   "=",
@@ -137,6 +146,12 @@ var tokens = [...]string{
 	Asterisk: "*",
 	Slash:    "/",
 	Modulo:   "%",
+	Range:    "..",
+	Ellipsis: "...",
+
+	NilCoalesce:   "??",
+	OptionalDot:   "?.",
+	OptionalIndex: "?[",
 
 	PlusAssign:  "+=",
 	MinusAssign: "-=",
@@ -208,6 +223,8 @@ var tokens = [...]string{
 	Breakpoint: "breakpoint",
 	Match:      "match",
 	Export:     "export",
+	Defer:      "defer",
+	With:       "with",
 }
 
 var keywords map[string]TokenType
@@ -226,6 +243,11 @@ func LookupIdent(ident string) TokenType {
 	return Identifier
 }
 
+// IsKeyword returns if t is a reserved keyword.
+func (t TokenType) IsKeyword() bool {
+	return keywordBeg < t && t < keywordEnd
+}
+
 func (t TokenType) String() string {
 	if 0 <= t && t < TokenType(len(tokens)) {
 		return tokens[t]